@@ -0,0 +1,210 @@
+package admission
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"chatServer/src/config"
+	"chatServer/src/logging"
+	"chatServer/src/ratelimit"
+)
+
+// Errors returned for the rejected connections, they are shown to the client
+var (
+	ErrServerFull     = errors.New("Server is full, try again later")
+	ErrTooManyFromIP  = errors.New("Too many connections from your address")
+	ErrDenied         = errors.New("Connections from your address are not allowed")
+	ErrConnectionRate = errors.New("Too many new connections, try again later")
+	ErrInvalidAddress = errors.New("Address must be an IP or a CIDR")
+)
+
+// maxAcceptDelay is the longest backoff after temporary Accept errors
+const maxAcceptDelay = time.Second
+
+// Controller decides which connections the listeners accept, it is shared by the telnet and IRC listeners
+// so the limits apply to all the connections of the server, a nil Controller admits everything
+type Controller struct {
+	maxConnections int
+	maxPerIP       int
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	limits         *ratelimit.Guard
+	logger         *logging.Logger
+	total          int
+	perIP          map[string]int
+	sync.Mutex
+}
+
+// NewController returns a Controller for the connection settings of the config, a nil logger discards the server log
+func NewController(connections config.ConnectionsConfig, limits *ratelimit.Guard, logger *logging.Logger) (*Controller, error) {
+	allow, err := parseNetworks(connections.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseNetworks(connections.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &Controller{
+		maxConnections: connections.MaxConnections,
+		maxPerIP:       connections.MaxPerIP,
+		allow:          allow,
+		deny:           deny,
+		limits:         limits,
+		logger:         logger.With("component", "admission"),
+		perIP:          make(map[string]int),
+	}, nil
+}
+
+// Reconfigure checks new connection settings and returns the function applying them, the open connections stay open
+// even when they are over the new limits or denied by the new lists
+func (controller *Controller) Reconfigure(connections config.ConnectionsConfig) (func(), error) {
+	allow, err := parseNetworks(connections.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseNetworks(connections.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if controller == nil {
+			return
+		}
+		controller.Lock()
+		defer controller.Unlock()
+		controller.maxConnections = connections.MaxConnections
+		controller.maxPerIP = connections.MaxPerIP
+		controller.allow = allow
+		controller.deny = deny
+	}, nil
+}
+
+// Admit checks a new connection from the IP, the release function must be called once the connection is closed
+func (controller *Controller) Admit(ip string) (func(), error) {
+	if controller == nil {
+		return func() {}, nil
+	}
+	address := net.ParseIP(ip)
+	controller.Lock()
+	allow, deny := controller.allow, controller.deny
+	controller.Unlock()
+	if contains(deny, address) || (len(allow) > 0 && !contains(allow, address)) {
+		return nil, ErrDenied
+	}
+	if !controller.limits.AllowConnection(ip) {
+		return nil, ErrConnectionRate
+	}
+
+	controller.Lock()
+	defer controller.Unlock()
+	if controller.maxConnections > 0 && controller.total >= controller.maxConnections {
+		return nil, ErrServerFull
+	}
+	if controller.maxPerIP > 0 && controller.perIP[ip] >= controller.maxPerIP {
+		return nil, ErrTooManyFromIP
+	}
+	controller.total++
+	controller.perIP[ip]++
+
+	var once sync.Once
+	return func() { once.Do(func() { controller.release(ip) }) }, nil
+}
+
+// Connections returns the number of admitted connections that are still open
+func (controller *Controller) Connections() int {
+	if controller == nil {
+		return 0
+	}
+	controller.Lock()
+	defer controller.Unlock()
+	return controller.total
+}
+
+// release frees the slot of a closed connection
+func (controller *Controller) release(ip string) {
+	controller.Lock()
+	defer controller.Unlock()
+	controller.total--
+	if controller.perIP[ip]--; controller.perIP[ip] <= 0 {
+		delete(controller.perIP, ip)
+	}
+}
+
+// Serve accepts the connections of the listener until it is closed, admitted connections are handled in their own
+// goroutine and rejected ones are passed to reject before they are closed, temporary Accept errors are retried with a backoff
+func (controller *Controller) Serve(ln net.Listener, reject func(conn net.Conn, err error), handle func(conn net.Conn)) error {
+	var logger *logging.Logger
+	if controller != nil {
+		logger = controller.logger.With("address", ln.Addr().String())
+	}
+	var delay time.Duration
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > maxAcceptDelay {
+					delay = maxAcceptDelay
+				}
+				logger.Warn("Error accepting a connection", "error", err, "retryIn", delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+
+		release, err := controller.Admit(ratelimit.HostIP(conn.RemoteAddr().String()))
+		if err != nil {
+			logger.Info("Rejected a connection", "remote", conn.RemoteAddr().String(), "reason", err)
+			reject(conn, err)
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer release()
+			handle(conn)
+		}()
+	}
+}
+
+// parseNetworks parses IPs and CIDRs, single IPs match only themselves
+func parseNetworks(addresses []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, address := range addresses {
+		if !strings.Contains(address, "/") {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, ErrInvalidAddress
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, ErrInvalidAddress
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// contains checks if one of the networks contains the IP
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
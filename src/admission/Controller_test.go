@@ -0,0 +1,144 @@
+package admission
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/config"
+)
+
+func TestController(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Admission Controller unit Test Suite")
+}
+
+func createController(connections config.ConnectionsConfig) *Controller {
+	controller, err := NewController(connections, nil, nil)
+	gomega.Expect(err).To(gomega.BeNil())
+	return controller
+}
+
+// temporaryError is an Accept error the listener recovers from
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// flakyListener fails Accept with the errors before it accepts from the wrapped listener
+type flakyListener struct {
+	net.Listener
+	errors []error
+}
+
+func (ln *flakyListener) Accept() (net.Conn, error) {
+	if len(ln.errors) > 0 {
+		err := ln.errors[0]
+		ln.errors = ln.errors[1:]
+		return nil, err
+	}
+	return ln.Listener.Accept()
+}
+
+var _ = ginkgo.Describe("Controller", func() {
+
+	ginkgo.Context("Admit", func() {
+		ginkgo.It("should reject connections over the limits until a connection is released", func() {
+			controller := createController(config.ConnectionsConfig{MaxConnections: 2, MaxPerIP: 1})
+			release, err := controller.Admit("10.0.0.1")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = controller.Admit("10.0.0.1")
+			gomega.Expect(err).To(gomega.Equal(ErrTooManyFromIP))
+			_, err = controller.Admit("10.0.0.2")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = controller.Admit("10.0.0.3")
+			gomega.Expect(err).To(gomega.Equal(ErrServerFull))
+			gomega.Expect(controller.Connections()).To(gomega.Equal(2))
+
+			release()
+			release() // releasing twice frees a single slot
+			gomega.Expect(controller.Connections()).To(gomega.Equal(1))
+			_, err = controller.Admit("10.0.0.1")
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("should apply the allow and deny lists", func() {
+			controller := createController(config.ConnectionsConfig{Allow: []string{"10.0.0.0/8", "::1"}, Deny: []string{"10.0.0.66"}})
+			_, err := controller.Admit("10.1.2.3")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = controller.Admit("::1")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = controller.Admit("10.0.0.66")
+			gomega.Expect(err).To(gomega.Equal(ErrDenied))
+			_, err = controller.Admit("192.168.0.1")
+			gomega.Expect(err).To(gomega.Equal(ErrDenied))
+		})
+
+		ginkgo.It("should reject invalid addresses in the lists", func() {
+			_, err := NewController(config.ConnectionsConfig{Deny: []string{"10.0.0.300"}}, nil, nil)
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidAddress))
+		})
+
+		ginkgo.It("should admit everything when it is nil", func() {
+			var controller *Controller
+			release, err := controller.Admit("10.0.0.1")
+			gomega.Expect(err).To(gomega.BeNil())
+			release()
+		})
+	})
+
+	ginkgo.Context("Reconfigure", func() {
+		ginkgo.It("should apply the new lists and limits to new connections only", func() {
+			controller := createController(config.ConnectionsConfig{})
+			_, err := controller.Admit("10.0.0.1")
+			gomega.Expect(err).To(gomega.BeNil())
+
+			_, err = controller.Reconfigure(config.ConnectionsConfig{Deny: []string{"10.0.0.300"}})
+			gomega.Expect(err).To(gomega.Equal(ErrInvalidAddress))
+			apply, err := controller.Reconfigure(config.ConnectionsConfig{MaxConnections: 2, Deny: []string{"10.0.0.2"}})
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = controller.Admit("10.0.0.2")
+			gomega.Expect(err).To(gomega.BeNil())
+
+			apply()
+			_, err = controller.Admit("10.0.0.2")
+			gomega.Expect(err).To(gomega.Equal(ErrDenied))
+			_, err = controller.Admit("10.0.0.3")
+			gomega.Expect(err).To(gomega.Equal(ErrServerFull))
+			gomega.Expect(controller.Connections()).To(gomega.Equal(2))
+		})
+	})
+
+	ginkgo.Context("Serve", func() {
+		ginkgo.It("should tell rejected clients why and keep accepting after temporary errors", func() {
+			controller := createController(config.ConnectionsConfig{MaxConnections: 1})
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			gomega.Expect(err).To(gomega.BeNil())
+			handled := make(chan net.Conn, 2)
+			stopped := make(chan error)
+			go func() {
+				stopped <- controller.Serve(&flakyListener{Listener: ln, errors: []error{temporaryError{}, temporaryError{}}},
+					func(conn net.Conn, err error) { conn.Write([]byte(err.Error() + "\n")) },
+					func(conn net.Conn) { handled <- conn; time.Sleep(time.Second) })
+			}()
+
+			first, _ := net.Dial("tcp", ln.Addr().String())
+			defer first.Close()
+			gomega.Eventually(handled).Should(gomega.Receive())
+			second, _ := net.Dial("tcp", ln.Addr().String())
+			defer second.Close()
+			line, _ := bufio.NewReader(second).ReadString('\n')
+			gomega.Expect(line).To(gomega.Equal(ErrServerFull.Error() + "\n"))
+
+			ln.Close()
+			var stopErr error
+			gomega.Eventually(stopped).Should(gomega.Receive(&stopErr))
+			gomega.Expect(stopErr).ToNot(gomega.BeNil())
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"chatServer/src/logging"
+	"chatServer/src/ratelimit"
+)
+
+// HeaderRequestID carries the id correlating the log entries of a request, it is taken from the request when valid
+// and always returned with the response
+const HeaderRequestID = "X-Request-ID"
+
+// quietPaths are the probes and scrapes, they are logged at the debug level
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// accessLog gives every request an id and a logger and logs the request once it has been answered
+func (controller *ControllerImpl) accessLog(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(HeaderRequestID)
+		if !isRequestID(requestID) {
+			requestID = logging.NewID()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+		logger := controller.logger.With("request", requestID)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r.WithContext(logging.NewContext(r.Context(), logger)))
+
+		log := logger.Info
+		switch {
+		case recorder.status >= http.StatusInternalServerError:
+			log = logger.Error
+		case quietPaths[r.URL.Path]:
+			log = logger.Debug
+		}
+		log("Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"duration", time.Since(start).Round(time.Microsecond),
+			"remote", ratelimit.HostIP(r.RemoteAddr),
+			"userAgent", r.UserAgent())
+	})
+}
+
+// isRequestID checks if the request id sent by a client can be logged as it is
+func isRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c == '-' || c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
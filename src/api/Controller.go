@@ -1,12 +1,41 @@
 package api
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // Controller interface for api
 type Controller interface {
 	Register()
+	Shutdown(ctx context.Context) error
+	Healthz(w http.ResponseWriter, r *http.Request)
+	Readyz(w http.ResponseWriter, r *http.Request)
+	AdminStatus(w http.ResponseWriter, r *http.Request)
+	AdminHandler(w http.ResponseWriter, r *http.Request)
+	GetConnectedUsers(w http.ResponseWriter, r *http.Request)
+	GetUserDetails(w http.ResponseWriter, r *http.Request)
+	KillUser(w http.ResponseWriter, r *http.Request)
+	SetUserAdmin(w http.ResponseWriter, r *http.Request)
+	PostBroadcast(w http.ResponseWriter, r *http.Request)
 	APIHandler(w http.ResponseWriter, r *http.Request)
 	PostMessage(w http.ResponseWriter, r *http.Request)
 	GetMessages(w http.ResponseWriter, r *http.Request)
+	RoomsHandler(w http.ResponseWriter, r *http.Request)
+	GetRooms(w http.ResponseWriter, r *http.Request)
+	RoomHandler(w http.ResponseWriter, r *http.Request)
+	GetRoom(w http.ResponseWriter, r *http.Request)
+	UpdateRoom(w http.ResponseWriter, r *http.Request)
+	DeleteRoom(w http.ResponseWriter, r *http.Request)
+	WebhooksHandler(w http.ResponseWriter, r *http.Request)
+	WebhookHandler(w http.ResponseWriter, r *http.Request)
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhooks(w http.ResponseWriter, r *http.Request)
+	RevokeWebhook(w http.ResponseWriter, r *http.Request)
+	PostWebhookMessage(w http.ResponseWriter, r *http.Request)
+	UsersHandler(w http.ResponseWriter, r *http.Request)
+	GetPresence(w http.ResponseWriter, r *http.Request)
+	GetProfile(w http.ResponseWriter, r *http.Request)
+	UpdateProfile(w http.ResponseWriter, r *http.Request)
 }
 
@@ -1,32 +1,289 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"chatServer/src/chatserver"
 	"chatServer/src/chatserver/data"
+	"chatServer/src/health"
+	"chatServer/src/logging"
+	"chatServer/src/metrics"
+	"chatServer/src/ratelimit"
 )
 
+// BadResponse is the response body sent when a request fails
+type BadResponse struct {
+	StatusCode int       `json:"statusCode"`
+	Message    string    `json:"message"`
+}
+
+// WebhookPath is the path of the incoming webhooks, it is followed by the token of a webhook
+const WebhookPath = "/rest/v1/hooks/"
+
+// AdminPath is the prefix of the admin endpoints, they require the admin token
+const AdminPath = "/rest/v1/admin/"
+
+// BroadcastRequest is the request body of a broadcast
+type BroadcastRequest struct {
+	Text string `json:"text"`
+}
+
 // ControllerImpl struct for api controller
 type ControllerImpl struct {
-	service Service
+	service    Service
+	limits     *ratelimit.Guard
+	monitor    *health.Monitor
+	adminToken string
+	logger     *logging.Logger
+	server     *http.Server
 }
 
 
-// NewControllerImpl returns ControllerImpl
-func NewControllerImpl(service Service) *ControllerImpl {
+// NewControllerImpl returns ControllerImpl serving on the address, the limits are shared with the listeners and nil disables them,
+// the admin endpoints require the admin token and are disabled when it is empty, a nil logger discards the access log
+func NewControllerImpl(service Service, limits *ratelimit.Guard, monitor *health.Monitor, address string, adminToken string, logger *logging.Logger) *ControllerImpl {
+	logger = logger.With("component", "api")
 	return &ControllerImpl{
 		service:	service,
+		limits:	limits,
+		monitor:	monitor,
+		adminToken:	adminToken,
+		logger:	logger,
+		server:	&http.Server{Addr: address, ErrorLog: log.New(logger.Writer(logging.LevelWarn), "", 0)},
 	}
 }
 
 
-// Register the endpoints this controller handles
+// Register the endpoints this controller handles and serves them until Shutdown is called
 func (controller *ControllerImpl) Register() {
-	http.HandleFunc("/rest/v1/messages", controller.APIHandler)
-	http.ListenAndServe(":3000", nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/v1/messages", instrument("messages", controller.limit(controller.APIHandler)))
+	mux.HandleFunc("/rest/v1/rooms", instrument("rooms", controller.limit(controller.RoomsHandler)))
+	mux.HandleFunc("/rest/v1/rooms/", instrument("room", controller.limit(controller.RoomHandler)))
+	mux.HandleFunc("/rest/v1/users/", instrument("users", controller.limit(controller.UsersHandler)))
+	mux.HandleFunc(WebhookPath, instrument("hooks", controller.limit(controller.WebhookHandler)))
+	mux.HandleFunc("/metrics", metrics.Default.Handler()) // scrapes and probes are neither limited nor counted
+	mux.HandleFunc("/healthz", controller.Healthz)
+	mux.HandleFunc("/readyz", controller.Readyz)
+	mux.HandleFunc("/admin/status", instrument("admin", controller.limit(controller.admin(controller.AdminStatus))))
+	mux.HandleFunc(AdminPath, instrument("admin", controller.limit(controller.admin(controller.AdminHandler))))
+	controller.server.Handler = controller.accessLog(mux)
+	controller.logger.Info("Serving the API", "address", controller.server.Addr)
+	if err := controller.server.ListenAndServe(); err != http.ErrServerClosed {
+		controller.logger.Error("Error serving the API", "address", controller.server.Addr, "error", err)
+	}
+}
+
+
+// Shutdown stops the API server, it waits for the active requests until the context is done
+func (controller *ControllerImpl) Shutdown(ctx context.Context) error {
+	return controller.server.Shutdown(ctx)
+}
+
+
+// limit rejects the requests of the IPs that are over the request rate limit
+func (controller *ControllerImpl) limit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !controller.limits.AllowRequest(ratelimit.HostIP(r.RemoteAddr)) {
+			writeTooManyRequests(w, "Too many requests", time.Second)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+
+// admin rejects the requests without the admin token, the endpoints are not found when no token is configured
+func (controller *ControllerImpl) admin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if controller.adminToken == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(controller.adminToken)) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(BadResponse{
+				StatusCode: http.StatusUnauthorized,
+				Message: "Admin token is missing or not valid",
+			})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+
+// Healthz answers as long as the server is running
+func (controller *ControllerImpl) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+
+// Readyz tells whether the listeners accept connections and the storage can be written
+func (controller *ControllerImpl) Readyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ready, checks := controller.monitor.Ready()
+	status := "ok"
+	statusCode := http.StatusOK
+	if !ready {
+		status = "unavailable"
+		statusCode = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+
+// AdminStatus controller is for describing the running server to the operators
+func (controller *ControllerImpl) AdminStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(controller.monitor.Status())
+}
+
+
+// AdminHandler handles the endpoints of the server admins
+func (controller *ControllerImpl) AdminHandler(w http.ResponseWriter, r *http.Request) {
+	resource := adminResource(r.URL.Path)
+	switch {
+	case resource == "users" && r.Method == http.MethodGet:
+		controller.GetConnectedUsers(w, r)
+	case resource == "stats" && r.Method == http.MethodGet:
+		controller.AdminStatus(w, r)
+	case resource == "broadcast" && r.Method == http.MethodPost:
+		controller.PostBroadcast(w, r)
+	case strings.HasPrefix(resource, "users/") && strings.HasSuffix(resource, "/admin") &&
+		(r.Method == http.MethodPut || r.Method == http.MethodDelete):
+		controller.SetUserAdmin(w, r)
+	case strings.HasPrefix(resource, "users/") && r.Method == http.MethodGet:
+		controller.GetUserDetails(w, r)
+	case strings.HasPrefix(resource, "users/") && r.Method == http.MethodDelete:
+		controller.KillUser(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+
+// GetConnectedUsers controller is for listing the users connected to the listeners
+func (controller *ControllerImpl) GetConnectedUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(controller.monitor.Who())
+}
+
+
+// GetUserDetails controller is for describing a user with its connection and its rooms
+func (controller *ControllerImpl) GetUserDetails(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := getAdminUserID(w, r)
+	if !ok {
+		return
+	}
+	details, found := controller.monitor.Whois(userID)
+	if !found {
+		writeError(w, ErrUserNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(details)
+}
+
+
+// KillUser controller is for disconnecting a user, the optional reason is shown to the user
+func (controller *ControllerImpl) KillUser(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := getAdminUserID(w, r)
+	if !ok {
+		return
+	}
+	reason := r.URL.Query().Get("reason")
+	if err := controller.service.KillUser(userID, reason); err != nil {
+		writeError(w, err)
+		return
+	}
+	logging.FromContext(r.Context()).Warn("Disconnected a user", "targetId", userID, "reason", reason)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+
+// SetUserAdmin controller is for granting the admin role with PUT and revoking it with DELETE
+func (controller *ControllerImpl) SetUserAdmin(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := getAdminUserID(w, r)
+	if !ok {
+		return
+	}
+	admin := r.Method == http.MethodPut
+	user, err := controller.service.SetAdmin(userID, admin)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	logging.FromContext(r.Context()).Info("Changed the admin role of a user", "targetId", userID, "admin", admin)
+
+	w.WriteHeader(http.StatusOK)
+	if details, found := controller.monitor.Whois(userID); found {
+		json.NewEncoder(w).Encode(details)
+	} else {
+		json.NewEncoder(w).Encode(data.UserDetails{ID: user.ID, Name: user.Name, Online: !user.Dead, Bot: user.Bot,
+			Admin: user.Admin, Rooms: []string{}})
+	}
+}
+
+
+// PostBroadcast controller is for sending a System message to every room
+func (controller *ControllerImpl) PostBroadcast(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request BroadcastRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	defer r.Body.Close()
+	if err != nil || strings.TrimSpace(request.Text) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "Text is empty",
+		})
+		return
+	}
+
+	messages := controller.service.Broadcast(request.Text)
+	logging.FromContext(r.Context()).Info("Broadcast a message", "rooms", len(messages))
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(messages)
 }
 
 
@@ -43,13 +300,62 @@ func (controller *ControllerImpl) APIHandler(w http.ResponseWriter, r *http.Requ
 }
 
 
-// PostMessage controller is for posting a message
-func (controller *ControllerImpl) PostMessage(w http.ResponseWriter, r *http.Request) {
+// RoomsHandler handles the rooms endpoints
+func (controller *ControllerImpl) RoomsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		controller.GetRooms(w, r)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+
+// RoomHandler handles the endpoints of a single room
+func (controller *ControllerImpl) RoomHandler(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.URL.Path, "/webhooks") {
+		controller.WebhooksHandler(w, r)
+	} else if r.Method == http.MethodGet {
+		controller.GetRoom(w, r)
+	} else if r.Method == http.MethodPatch {
+		controller.UpdateRoom(w, r)
+	} else if r.Method == http.MethodDelete {
+		controller.DeleteRoom(w, r)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
 
-	type BadResponse struct {
-		StatusCode int       `json:"statusCode"`
-		Message    string    `json:"message"`
+
+// WebhooksHandler handles the endpoints managing the incoming webhooks of a room
+func (controller *ControllerImpl) WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		controller.CreateWebhook(w, r)
+	} else if r.Method == http.MethodGet {
+		controller.GetWebhooks(w, r)
+	} else if r.Method == http.MethodDelete {
+		controller.RevokeWebhook(w, r)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
+}
+
+
+// WebhookHandler handles the incoming webhooks
+func (controller *ControllerImpl) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		controller.PostWebhookMessage(w, r)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+
+// PostMessage controller is for posting a message
+func (controller *ControllerImpl) PostMessage(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -86,13 +392,15 @@ func (controller *ControllerImpl) PostMessage(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	decision, remaining := controller.limits.CheckMessage(strconv.Itoa(message.UserID), ratelimit.HostIP(r.RemoteAddr))
+	if decision != ratelimit.Allow {
+		writeTooManyRequests(w, decision.Notice(remaining), remaining)
+		return
+	}
+
 	resp, err := controller.service.PostMessage(message)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(BadResponse{
-			StatusCode: http.StatusInternalServerError,
-			Message: err.Error(),
-		})
+		writeError(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -103,22 +411,526 @@ func (controller *ControllerImpl) PostMessage(w http.ResponseWriter, r *http.Req
 // GetMessages controller is for retrieving the messages
 func (controller *ControllerImpl) GetMessages(w http.ResponseWriter, r *http.Request) {
 
+	viewerIDKey, _ := r.URL.Query()["viewerId"]
 	userIDKey, _ := r.URL.Query()["userId"]
 	roomIDKey, _ := r.URL.Query()["roomId"]
 
-	userID := 9223372036854775807 // int64 max value
-	roomID := 9223372036854775807 // int64 max value
+	w.Header().Set("Content-Type", "application/json")
+
+	viewerID := noID // only the messages of the public rooms are returned
+	userID := noID
+	roomID := noID
+	var viewerErr, userErr, roomErr error
+	if viewerIDKey != nil {
+		viewerID, viewerErr = strconv.Atoi(viewerIDKey[0])
+	}
 	if userIDKey != nil {
-		userID, _ = strconv.Atoi(userIDKey[0])
+		userID, userErr = strconv.Atoi(userIDKey[0])
 	}
 	if roomIDKey != nil {
-		roomID, _ = strconv.Atoi(roomIDKey[0])
+		roomID, roomErr = strconv.Atoi(roomIDKey[0])
+	}
+	if viewerErr != nil || userErr != nil || roomErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "ViewerId, UserId or RoomId is not valid",
+		})
+		return
 	}
 
-	messages := controller.service.GetMessages(userID, roomID)
+	messages := controller.service.GetMessages(viewerID, userID, roomID)
 
-	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(messages)
 }
 
+
+// GetRooms controller is for retrieving the rooms visible to a user
+func (controller *ControllerImpl) GetRooms(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userIDKey, _ := r.URL.Query()["userId"]
+
+	userID := noID // only rooms that are not private are returned
+	if userIDKey != nil {
+		var err error
+		userID, err = strconv.Atoi(userIDKey[0])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(BadResponse{
+				StatusCode: http.StatusBadRequest,
+				Message: "UserId is not valid",
+			})
+			return
+		}
+	}
+
+	rooms := controller.service.GetRooms(userID)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rooms)
+}
+
+
+// GetRoom controller is for retrieving the details of a room
+func (controller *ControllerImpl) GetRoom(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	roomID, err := getPathID(r.URL.Path, "/rest/v1/rooms/")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "RoomId is not valid",
+		})
+		return
+	}
+
+	userIDKey, _ := r.URL.Query()["userId"]
+
+	userID := noID // private rooms are not returned
+	if userIDKey != nil {
+		userID, err = strconv.Atoi(userIDKey[0])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(BadResponse{
+				StatusCode: http.StatusBadRequest,
+				Message: "UserId is not valid",
+			})
+			return
+		}
+	}
+
+	room, err := controller.service.GetRoom(roomID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(room)
+}
+
+
+// UpdateRoom controller is for renaming, archiving and restoring a room
+func (controller *ControllerImpl) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	roomID, err := getPathID(r.URL.Path, "/rest/v1/rooms/")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "RoomId is not valid",
+		})
+		return
+	}
+
+	var update data.RoomUpdate
+	err = json.NewDecoder(r.Body).Decode(&update)
+	defer r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	//validate request body
+	if update.UserID == 0 || (update.Name == nil && update.Archived == nil && update.Welcome == nil) || (update.Name != nil && *update.Name == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is empty or nothing to update",
+		})
+		return
+	}
+
+	room, err := controller.service.UpdateRoom(roomID, update)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(room)
+}
+
+
+// DeleteRoom controller is for deleting a room
+func (controller *ControllerImpl) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	roomID, err := getPathID(r.URL.Path, "/rest/v1/rooms/")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "RoomId is not valid",
+		})
+		return
+	}
+
+	userID, err := strconv.Atoi(r.URL.Query().Get("userId"))
+	if err != nil || userID == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is not valid",
+		})
+		return
+	}
+
+	err = controller.service.DeleteRoom(roomID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+
+// CreateWebhook controller is for creating an incoming webhook of a room
+func (controller *ControllerImpl) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	roomID, _, err := getWebhookPathIDs(r.URL.Path)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "RoomId is not valid",
+		})
+		return
+	}
+
+	var request data.WebhookRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	defer r.Body.Close()
+	if err != nil || request.UserID == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is empty",
+		})
+		return
+	}
+
+	webhook, err := controller.service.CreateWebhook(roomID, request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+
+// GetWebhooks controller is for listing the incoming webhooks of a room
+func (controller *ControllerImpl) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	roomID, _, err := getWebhookPathIDs(r.URL.Path)
+	userID, userErr := strconv.Atoi(r.URL.Query().Get("userId"))
+	if err != nil || userErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "RoomId or UserId is not valid",
+		})
+		return
+	}
+
+	webhooks, err := controller.service.GetWebhooks(roomID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+
+// RevokeWebhook controller is for revoking an incoming webhook
+func (controller *ControllerImpl) RevokeWebhook(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	roomID, webhookID, err := getWebhookPathIDs(r.URL.Path)
+	userID, userErr := strconv.Atoi(r.URL.Query().Get("userId"))
+	if err != nil || webhookID < 0 || userErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "RoomId, WebhookId or UserId is not valid",
+		})
+		return
+	}
+
+	err = controller.service.RevokeWebhook(roomID, webhookID, userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+
+// PostWebhookMessage controller is for posting the message sent to an incoming webhook
+func (controller *ControllerImpl) PostWebhookMessage(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	token := ""
+	if index := strings.Index(r.URL.Path, WebhookPath); index != -1 {
+		token = strings.Trim(r.URL.Path[index+len(WebhookPath):], "/")
+	}
+
+	var message data.WebhookMessage
+	err := json.NewDecoder(r.Body).Decode(&message)
+	defer r.Body.Close()
+	if err != nil || strings.TrimSpace(message.Text) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "Text is empty",
+		})
+		return
+	}
+
+	decision, remaining := controller.limits.CheckMessage("hook:"+token, ratelimit.HostIP(r.RemoteAddr))
+	if decision != ratelimit.Allow {
+		writeTooManyRequests(w, decision.Notice(remaining), remaining)
+		return
+	}
+
+	resp, err := controller.service.PostWebhookMessage(token, message)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+
+// UsersHandler handles the endpoints of a single user
+func (controller *ControllerImpl) UsersHandler(w http.ResponseWriter, r *http.Request) {
+	urlPath := strings.TrimSuffix(r.URL.Path, "/")
+	if strings.HasSuffix(urlPath, "/presence") && r.Method == http.MethodGet {
+		controller.GetPresence(w, r)
+	} else if strings.HasSuffix(urlPath, "/profile") && r.Method == http.MethodGet {
+		controller.GetProfile(w, r)
+	} else if strings.HasSuffix(urlPath, "/profile") && r.Method == http.MethodPatch {
+		controller.UpdateProfile(w, r)
+	} else {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+}
+
+
+// GetPresence controller is for getting the presence of a user
+func (controller *ControllerImpl) GetPresence(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := getPathID(strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/presence"), "/rest/v1/users/")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is not valid",
+		})
+		return
+	}
+
+	presence, err := controller.service.GetPresence(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(presence)
+}
+
+
+// GetProfile controller is for getting the profile of a user
+func (controller *ControllerImpl) GetProfile(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := getPathID(strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/profile"), "/rest/v1/users/")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is not valid",
+		})
+		return
+	}
+
+	profile, err := controller.service.GetProfile(userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profile)
+}
+
+
+// UpdateProfile controller is for changing the display name, the bio or the timezone of a user
+func (controller *ControllerImpl) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, err := getPathID(strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/profile"), "/rest/v1/users/")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is not valid",
+		})
+		return
+	}
+
+	var update data.ProfileUpdate
+	err = json.NewDecoder(r.Body).Decode(&update)
+	defer r.Body.Close()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	//validate request body
+	if update.UserID == 0 || (update.DisplayName == nil && update.Bio == nil && update.Timezone == nil) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is empty or nothing to update",
+		})
+		return
+	}
+
+	profile, err := controller.service.UpdateProfile(userID, update)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profile)
+}
+
+
+// writeError writes the error response with the status code matching the error
+func writeError(w http.ResponseWriter, err error) {
+	statusCode := http.StatusInternalServerError
+	switch err {
+	case ErrUserNotFound, ErrRoomNotFound, ErrWebhookNotFound:
+		statusCode = http.StatusNotFound
+	case ErrForbidden, ErrNotSubscribed, ErrNotProfileOwner, chatserver.ErrDefaultRoom:
+		statusCode = http.StatusForbidden
+	case ErrRoomNameInvalid, ErrProfileInvalid, ErrTimezoneInvalid:
+		statusCode = http.StatusBadRequest
+	case ErrRoomExists, ErrRoomArchived, chatserver.ErrRoomNotArchived, ErrNotConnected:
+		statusCode = http.StatusConflict
+	}
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(BadResponse{
+		StatusCode: statusCode,
+		Message: err.Error(),
+	})
+}
+
+
+// writeTooManyRequests writes the 429 response telling the client when to retry
+func writeTooManyRequests(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(BadResponse{
+		StatusCode: http.StatusTooManyRequests,
+		Message: message,
+	})
+}
+
+
+// adminResource returns the part of the url path following the admin prefix, e.g. users/3/admin
+func adminResource(urlPath string) string {
+	if index := strings.Index(urlPath, AdminPath); index != -1 {
+		urlPath = urlPath[index+len(AdminPath):]
+	}
+	return strings.Trim(urlPath, "/")
+}
+
+
+// getAdminUserID parses the id of the user in the path of an admin endpoint and writes the bad request when it is not valid
+func getAdminUserID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	parts := strings.Split(adminResource(r.URL.Path), "/")
+	userID, err := -1, errors.New("Id not found in path")
+	if len(parts) >= 2 {
+		userID, err = strconv.Atoi(parts[1])
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(BadResponse{
+			StatusCode: http.StatusBadRequest,
+			Message: "UserId is not valid",
+		})
+		return 0, false
+	}
+	return userID, true
+}
+
+
+// getPathID parses the id that follows the prefix in the url path
+func getPathID(urlPath string, prefix string) (int, error) {
+	index := strings.Index(urlPath, prefix)
+	if index == -1 {
+		return 0, errors.New("Id not found in path")
+	}
+	return strconv.Atoi(strings.Trim(urlPath[index+len(prefix):], "/"))
+}
+
+
+// getWebhookPathIDs parses the room id and the webhook id, -1 when not given, of /rest/v1/rooms/{id}/webhooks/{id}
+func getWebhookPathIDs(urlPath string) (int, int, error) {
+	index := strings.Index(urlPath, "/rest/v1/rooms/")
+	if index == -1 {
+		return 0, 0, errors.New("Id not found in path")
+	}
+	parts := strings.Split(strings.Trim(urlPath[index+len("/rest/v1/rooms/"):], "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] != "webhooks" {
+		return 0, 0, errors.New("Path is not valid")
+	}
+	roomID, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) == 2 {
+		return roomID, -1, err
+	}
+	webhookID, err := strconv.Atoi(parts[2])
+	return roomID, webhookID, err
+}
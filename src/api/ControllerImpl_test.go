@@ -3,13 +3,20 @@ package api
 import (
 	"bytes"
 	"errors"
+	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 
+	"chatServer/src/chatserver"
 	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/src/health"
+	"chatServer/src/logging"
+	"chatServer/src/metrics"
+	"chatServer/src/ratelimit"
 )
 
 func TestControllerImpl(t *testing.T) {
@@ -19,7 +26,7 @@ func TestControllerImpl(t *testing.T) {
 }
 
 func createController(service Service) Controller {
-	return NewControllerImpl(service)
+	return NewControllerImpl(service, nil, nil, ":3000", "", nil)
 }
 
 var _ = ginkgo.Describe("ControllerImpl", func() {
@@ -37,10 +44,172 @@ var _ = ginkgo.Describe("ControllerImpl", func() {
 			r := httptest.NewRequest("GET", url, nil)
 
 			apiServiceMock.On("GetMessages",
-				9223372036854775807, 9223372036854775807).Return(messages)
+				noID, noID, noID).Return(messages)
 			controller.GetMessages(w, r)
 			gomega.Expect(w.Code).To(gomega.Equal(200))
 		})
+
+		ginkgo.It("should check the rooms for the viewer and filter by the user", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/messages?viewerId=2&roomId=1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			apiServiceMock.On("GetMessages", 2, noID, 1).Return(messages)
+			controller.GetMessages(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			apiServiceMock.AssertExpectations(ginkgo.GinkgoT())
+		})
+
+		ginkgo.It("should return bad request when the ids are not numbers", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/messages?userId=1&roomId=abc"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			controller.GetMessages(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+	})
+
+	ginkgo.Context("GetRooms", func() {
+		ginkgo.It("should get the rooms visible to the user", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms?userId=1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			apiServiceMock.On("GetRooms", 1).Return([]data.Room{{ID: 0, Name: "Default"}})
+			controller.GetRooms(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring("Default"))
+		})
+
+		ginkgo.It("should return bad request when userId is not a number", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms?userId=abc"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			controller.GetRooms(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+	})
+
+	ginkgo.Context("GetRoom", func() {
+		ginkgo.It("should get the room details", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1?userId=2"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			apiServiceMock.On("GetRoom", 1, 2).Return(data.Room{ID: 1, Name: "Tech", Topic: "Go"})
+			controller.GetRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"topic":"Go"`))
+		})
+
+		ginkgo.It("should return 404 when the room is not visible", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/5"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			apiServiceMock.On("GetRoom", 5, noID).Return(data.Room{})
+			controller.GetRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(404))
+		})
+	})
+
+	ginkgo.Context("UpdateRoom", func() {
+		ginkgo.It("should update the room and return 200", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PATCH", url, bytes.NewReader([]byte(`{"userId": 1, "name": "Tech"}`)))
+
+			apiServiceMock.On("UpdateRoom", 1, 1).Return(data.Room{ID: 1, Name: "Tech"}, nil)
+			controller.UpdateRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+		})
+
+		ginkgo.It("should return 403 when the user is not the creator", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PATCH", url, bytes.NewReader([]byte(`{"userId": 2, "archived": true}`)))
+
+			apiServiceMock.On("UpdateRoom", 1, 2).Return(data.Room{}, ErrForbidden)
+			controller.UpdateRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(403))
+		})
+
+		ginkgo.It("should return bad request when there is nothing to update", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("PATCH", url, bytes.NewReader([]byte(`{"userId": 1}`)))
+
+			controller.UpdateRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+	})
+
+	ginkgo.Context("DeleteRoom", func() {
+		ginkgo.It("should delete the room and return 204", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1?userId=1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", url, nil)
+
+			apiServiceMock.On("DeleteRoom", 1, 1).Return(nil)
+			controller.DeleteRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(204))
+		})
+
+		ginkgo.It("should return 404 when the room does not exist", func() {
+
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/9?userId=1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", url, nil)
+
+			apiServiceMock.On("DeleteRoom", 9, 1).Return(ErrRoomNotFound)
+			controller.DeleteRoom(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(404))
+		})
 	})
 
 	ginkgo.Context("PostMessage", func() {
@@ -84,6 +253,354 @@ var _ = ginkgo.Describe("ControllerImpl", func() {
 			controller.PostMessage(w, r)
 			gomega.Expect(w.Code).To(gomega.Equal(500))
 		})
+
+		ginkgo.It("should return 403 when the user is not a member of the room", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/messages"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", url, bytes.NewReader([]byte(`{"Text":"hello","userId": 2,"roomId": 1}`)))
+
+			newMessage := data.Message{UserID: 2, Text: "hello", RoomID: 1}
+			apiServiceMock.On("PostMessage", newMessage).Return(data.Message{}, ErrNotSubscribed)
+			controller.PostMessage(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(403))
+		})
+	})
+
+	ginkgo.Context("Webhooks", func() {
+		ginkgo.It("should create an incoming webhook of a room", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1/webhooks"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", url, bytes.NewReader([]byte(`{"userId": 1, "name": "CI"}`)))
+
+			apiServiceMock.On("CreateWebhook", 1, 1).Return(data.IncomingWebhook{ID: 0, Name: "CI", URL: WebhookPath + "abc"}, nil)
+			controller.RoomHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(201))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"url":"/rest/v1/hooks/abc"`))
+		})
+
+		ginkgo.It("should return 403 when the user is not subscribed to the room", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1/webhooks?userId=2"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", url, nil)
+
+			apiServiceMock.On("GetWebhooks", 1, 2).Return([]data.IncomingWebhook{}, ErrNotSubscribed)
+			controller.RoomHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(403))
+		})
+
+		ginkgo.It("should revoke an incoming webhook", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1/webhooks/3?userId=1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", url, nil)
+
+			apiServiceMock.On("RevokeWebhook", 1, 3, 1).Return(nil)
+			controller.RoomHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(204))
+		})
+
+		ginkgo.It("should return bad request when the webhook id is missing", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + "/rest/v1/rooms/1/webhooks?userId=1"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", url, nil)
+
+			controller.RoomHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+
+		ginkgo.It("should post the message sent to an incoming webhook", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			url := routeName + WebhookPath + "abc"
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", url, bytes.NewReader([]byte(`{"text": "Build passed", "username": "Jenkins"}`)))
+
+			apiServiceMock.On("PostWebhookMessage", "abc", "Build passed").Return(data.Message{Text: "Build passed"}, nil)
+			controller.WebhookHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(201))
+		})
+
+		ginkgo.It("should return 404 for an unknown token and 400 without text", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := createController(apiServiceMock)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", routeName+WebhookPath+"wrong", bytes.NewReader([]byte(`{"text": "hello"}`)))
+			apiServiceMock.On("PostWebhookMessage", "wrong", "hello").Return(data.Message{}, ErrWebhookNotFound)
+			controller.WebhookHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(404))
+
+			w = httptest.NewRecorder()
+			r = httptest.NewRequest("POST", routeName+WebhookPath+"abc", bytes.NewReader([]byte(`{"username": "Jenkins"}`)))
+			controller.WebhookHandler(w, r)
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+	})
+
+	ginkgo.Context("Rate limits", func() {
+		ginkgo.It("should return 429 when an IP sends too many requests", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := NewControllerImpl(apiServiceMock, ratelimit.NewGuard(config.RateLimitConfig{APIRequestsPerSecond: 1, APIBurst: 1}), nil, ":3000", "", nil)
+			apiServiceMock.On("GetRooms", noID).Return(nil)
+			handler := controller.limit(controller.RoomsHandler)
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", routeName+"/rest/v1/rooms", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+
+			w = httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", routeName+"/rest/v1/rooms", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(429))
+			gomega.Expect(w.Header().Get("Retry-After")).To(gomega.Equal("1"))
+		})
+
+		ginkgo.It("should return 429 when a user posts too many messages", func() {
+			apiServiceMock := &ServiceMock{}
+			controller := NewControllerImpl(apiServiceMock, ratelimit.NewGuard(config.RateLimitConfig{MessagesPerSecond: 1, MessageBurst: 1}), nil, ":3000", "", nil)
+			newMessage := data.Message{UserID: 1, Text: "hello", RoomID: 0}
+			apiServiceMock.On("PostMessage", newMessage).Return(newMessage, nil)
+
+			w := httptest.NewRecorder()
+			controller.PostMessage(w, httptest.NewRequest("POST", routeName+"/rest/v1/messages", bytes.NewReader([]byte(`{"Text":"hello","userId": 1}`))))
+			gomega.Expect(w.Code).To(gomega.Equal(201))
+
+			w = httptest.NewRecorder()
+			controller.PostMessage(w, httptest.NewRequest("POST", routeName+"/rest/v1/messages", bytes.NewReader([]byte(`{"Text":"hello","userId": 1}`))))
+			gomega.Expect(w.Code).To(gomega.Equal(429))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring("too fast"))
+		})
+	})
+
+	ginkgo.Context("Metrics", func() {
+		ginkgo.It("should count the requests by handler, method and status code", func() {
+			handler := instrument("metrics-test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			handler(httptest.NewRecorder(), httptest.NewRequest("DELETE", routeName+"/rest/v1/rooms", nil))
+
+			var out bytes.Buffer
+			metrics.Default.Write(&out)
+			gomega.Expect(out.String()).To(gomega.MatchRegexp(`chat_http_requests_total{handler="metrics-test",method="DELETE",code="418"} \d+\n`))
+			gomega.Expect(out.String()).To(gomega.MatchRegexp(`chat_http_request_duration_seconds_count{handler="metrics-test"} \d+\n`))
+		})
+
+		ginkgo.It("should count the unknown methods as other", func() {
+			handler := instrument("methods-test", func(w http.ResponseWriter, r *http.Request) {})
+			handler(httptest.NewRecorder(), httptest.NewRequest("BREW", routeName+"/rest/v1/rooms", nil))
+
+			var out bytes.Buffer
+			metrics.Default.Write(&out)
+			gomega.Expect(out.String()).To(gomega.MatchRegexp(`chat_http_requests_total{handler="methods-test",method="other",code="200"} \d+\n`))
+			gomega.Expect(out.String()).NotTo(gomega.ContainSubstring(`BREW`))
+		})
+	})
+
+	ginkgo.Context("Access log", func() {
+		ginkgo.It("should log the requests with their id", func() {
+			var out bytes.Buffer
+			logger, _ := logging.New(&out, config.LogConfig{Level: "info"})
+			controller := NewControllerImpl(&ServiceMock{}, nil, nil, ":3000", "", logger)
+			handler := controller.accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				logging.FromContext(r.Context()).Info("Inside the handler")
+				w.WriteHeader(http.StatusTeapot)
+				w.Write([]byte("short"))
+			}))
+
+			request := httptest.NewRequest("GET", "/rest/v1/rooms", nil)
+			request.Header.Set(HeaderRequestID, "abc-123")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+			gomega.Expect(w.Header().Get(HeaderRequestID)).To(gomega.Equal("abc-123"))
+			gomega.Expect(out.String()).To(gomega.ContainSubstring("Inside the handler component=api request=abc-123\n"))
+			gomega.Expect(out.String()).To(gomega.MatchRegexp(`INFO Request component=api request=abc-123 method=GET path=/rest/v1/rooms status=418 bytes=5 duration=\S+ remote=192.0.2.1`))
+
+			request = httptest.NewRequest("GET", "/healthz", nil)
+			request.Header.Set(HeaderRequestID, "not valid")
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+			gomega.Expect(w.Header().Get(HeaderRequestID)).To(gomega.MatchRegexp(`^[0-9a-f]{8}$`))
+			gomega.Expect(out.String()).NotTo(gomega.ContainSubstring("/healthz")) // probes are logged at the debug level
+		})
+	})
+
+	ginkgo.Context("Health", func() {
+		ginkgo.It("should answer the liveness probe", func() {
+			w := httptest.NewRecorder()
+			createController(&ServiceMock{}).Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+		})
+
+		ginkgo.It("should return 503 until every check passes", func() {
+			monitor := health.NewMonitor("test", &chatserver.ServiceMock{})
+			monitor.AddCheck("storage", func() error { return errors.New("read-only file system") })
+			controller := NewControllerImpl(&ServiceMock{}, nil, monitor, ":3000", "", nil)
+
+			w := httptest.NewRecorder()
+			controller.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(503))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"storage":"read-only file system"`))
+
+			monitor.AddCheck("storage", func() error { return nil })
+			w = httptest.NewRecorder()
+			controller.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+		})
+
+		ginkgo.It("should require the admin token for the status", func() {
+			controller := NewControllerImpl(&ServiceMock{}, nil, health.NewMonitor("1.2.3", &chatserver.ServiceMock{}), ":3000", "secret", nil)
+			handler := controller.admin(controller.AdminStatus)
+
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", "/admin/status", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(401))
+
+			request := httptest.NewRequest("GET", "/admin/status", nil)
+			request.Header.Set("Authorization", "Bearer secret")
+			w = httptest.NewRecorder()
+			handler(w, request)
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"version":"1.2.3"`))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`{"id":1,"name":"Tech","members":0,"archived":false}`))
+		})
+
+		ginkgo.It("should hide the admin endpoints without a token", func() {
+			controller := createController(&ServiceMock{}).(*ControllerImpl)
+			w := httptest.NewRecorder()
+			controller.admin(controller.AdminStatus)(w, httptest.NewRequest("GET", "/admin/status", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(404))
+		})
+	})
+
+	ginkgo.Context("GetPresence", func() {
+		ginkgo.It("should get the presence of a user", func() {
+			apiServiceMock := &ServiceMock{}
+			apiServiceMock.On("GetPresence", 3).Return(data.Presence{UserID: 3, Name: "ann", State: "away", Status: "lunch"}, nil)
+			apiServiceMock.On("GetPresence", 9).Return(data.Presence{}, ErrUserNotFound)
+			controller := createController(apiServiceMock)
+
+			w := httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("GET", "/rest/v1/users/3/presence", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"state":"away","status":"lunch"`))
+
+			w = httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("GET", "/rest/v1/users/9/presence", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(404))
+
+			w = httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("GET", "/rest/v1/users/ann/presence", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+	})
+
+	ginkgo.Context("Profile", func() {
+		ginkgo.It("should get and update the profile of a user", func() {
+			timezone := "Mars/Olympus"
+			apiServiceMock := &ServiceMock{}
+			apiServiceMock.On("GetProfile", 3).Return(data.Profile{UserID: 3, Name: "ann", Bio: "Gopher"}, nil)
+			apiServiceMock.On("UpdateProfile", 3, data.ProfileUpdate{UserID: 3, Timezone: &timezone}).Return(data.Profile{}, ErrTimezoneInvalid)
+			apiServiceMock.On("UpdateProfile", 3, data.ProfileUpdate{UserID: 2, Timezone: &timezone}).Return(data.Profile{}, ErrNotProfileOwner)
+			controller := createController(apiServiceMock)
+
+			w := httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("GET", "/rest/v1/users/3/profile", nil))
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"name":"ann","displayName":"","bio":"Gopher"`))
+
+			w = httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("PATCH", "/rest/v1/users/3/profile", bytes.NewBufferString(`{"userId": 3, "timezone": "Mars/Olympus"}`)))
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring("Timezone must be a name such as Europe/Paris"))
+
+			w = httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("PATCH", "/rest/v1/users/3/profile", bytes.NewBufferString(`{"userId": 2, "timezone": "Mars/Olympus"}`)))
+			gomega.Expect(w.Code).To(gomega.Equal(403))
+
+			w = httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("PATCH", "/rest/v1/users/3/profile", bytes.NewBufferString(`{"timezone": "Mars/Olympus"}`)))
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+
+			w = httptest.NewRecorder()
+			controller.UsersHandler(w, httptest.NewRequest("PATCH", "/rest/v1/users/3/profile", bytes.NewBufferString(`{"userId": 3}`)))
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
 	})
 
-})
\ No newline at end of file
+	ginkgo.Context("Admin", func() {
+		// adminRequest sends an authenticated request to the admin endpoints
+		adminRequest := func(service Service, method string, url string, body string) *httptest.ResponseRecorder {
+			controller := NewControllerImpl(service, nil, health.NewMonitor("test", &chatserver.ServiceMock{}), ":3000", "secret", nil)
+			request := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+			request.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			controller.admin(controller.AdminHandler)(w, request)
+			return w
+		}
+
+		ginkgo.It("should describe a user", func() {
+			w := adminRequest(&ServiceMock{}, "GET", "/rest/v1/admin/users/1", "")
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"name":"Rob"`))
+
+			w = adminRequest(&ServiceMock{}, "GET", "/rest/v1/admin/users/42", "")
+			gomega.Expect(w.Code).To(gomega.Equal(404))
+			w = adminRequest(&ServiceMock{}, "GET", "/rest/v1/admin/users/rob", "")
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+
+		ginkgo.It("should disconnect a user with the reason", func() {
+			apiServiceMock := &ServiceMock{}
+			apiServiceMock.On("KillUser", 2, "spamming").Return(nil)
+			apiServiceMock.On("KillUser", 3, "").Return(ErrNotConnected)
+
+			w := adminRequest(apiServiceMock, "DELETE", "/rest/v1/admin/users/2?reason=spamming", "")
+			gomega.Expect(w.Code).To(gomega.Equal(204))
+			w = adminRequest(apiServiceMock, "DELETE", "/rest/v1/admin/users/3", "")
+			gomega.Expect(w.Code).To(gomega.Equal(409))
+		})
+
+		ginkgo.It("should grant and revoke the admin role", func() {
+			apiServiceMock := &ServiceMock{}
+			apiServiceMock.On("SetAdmin", 1, true).Return(data.User{ID: 1, Name: "Rob", Admin: true}, nil)
+			apiServiceMock.On("SetAdmin", 1, false).Return(data.User{ID: 1, Name: "Rob"}, nil)
+
+			gomega.Expect(adminRequest(apiServiceMock, "PUT", "/rest/v1/admin/users/1/admin", "").Code).To(gomega.Equal(200))
+			gomega.Expect(adminRequest(apiServiceMock, "DELETE", "/rest/v1/admin/users/1/admin", "").Code).To(gomega.Equal(200))
+			apiServiceMock.AssertExpectations(ginkgo.GinkgoT())
+		})
+
+		ginkgo.It("should broadcast a system message", func() {
+			apiServiceMock := &ServiceMock{}
+			apiServiceMock.On("Broadcast", "Restarting soon").Return(messages)
+
+			w := adminRequest(apiServiceMock, "POST", "/rest/v1/admin/broadcast", `{"text":"Restarting soon"}`)
+			gomega.Expect(w.Code).To(gomega.Equal(201))
+			w = adminRequest(apiServiceMock, "POST", "/rest/v1/admin/broadcast", `{"text":" "}`)
+			gomega.Expect(w.Code).To(gomega.Equal(400))
+		})
+
+		ginkgo.It("should list the connected users and the stats", func() {
+			w := adminRequest(&ServiceMock{}, "GET", "/rest/v1/admin/users", "")
+			gomega.Expect(w.Code).To(gomega.Equal(200))
+			gomega.Expect(w.Body.String()).To(gomega.Equal("[]\n"))
+			w = adminRequest(&ServiceMock{}, "GET", "/rest/v1/admin/stats", "")
+			gomega.Expect(w.Body.String()).To(gomega.ContainSubstring(`"version":"test"`))
+			gomega.Expect(adminRequest(&ServiceMock{}, "GET", "/rest/v1/admin/unknown", "").Code).To(gomega.Equal(404))
+		})
+	})
+})
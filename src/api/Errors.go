@@ -0,0 +1,23 @@
+package api
+
+import (
+	"errors"
+
+	"chatServer/src/chatserver"
+)
+
+// Errors returned by the api service, the chat server errors are returned as they are
+var (
+	ErrUserNotFound    = chatserver.ErrUserNotFound
+	ErrRoomNotFound    = chatserver.ErrRoomNotFound
+	ErrRoomExists      = chatserver.ErrRoomExists
+	ErrRoomArchived    = chatserver.ErrRoomArchived
+	ErrRoomNameInvalid = chatserver.ErrRoomNameInvalid
+	ErrForbidden       = chatserver.ErrNotCreator
+	ErrNotSubscribed   = chatserver.ErrNotSubscribed
+	ErrNotConnected    = chatserver.ErrUserNotConnected
+	ErrProfileInvalid  = chatserver.ErrProfileInvalid
+	ErrTimezoneInvalid = chatserver.ErrTimezoneInvalid
+	ErrWebhookNotFound = errors.New("Webhook not found")
+	ErrNotProfileOwner = errors.New("Only the user or an admin can change the profile")
+)
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chatServer/src/metrics"
+)
+
+// metrics of the API, exposed on /metrics
+var (
+	requestsHandled = metrics.NewCounter("chat_http_requests_total",
+		"API requests by endpoint, method and status code.", "handler", "method", "code")
+	requestDuration = metrics.NewHistogram("chat_http_request_duration_seconds",
+		"Time taken to answer the API requests.", metrics.DefaultBuckets, "handler")
+)
+
+// knownMethods are counted by name, the others as other so that clients cannot add label values
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodPost: true, http.MethodPut: true, http.MethodPatch: true, http.MethodDelete: true,
+}
+
+// statusRecorder remembers the status code and counts the bytes written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status code and writes it
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes and writes them
+func (recorder *statusRecorder) Write(body []byte) (int, error) {
+	written, err := recorder.ResponseWriter.Write(body)
+	recorder.bytes += written
+	return written, err
+}
+
+// instrument counts the requests of the handler and measures their duration under the name
+func instrument(name string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r)
+		method := r.Method
+		if !knownMethods[method] {
+			method = "other"
+		}
+		requestsHandled.Inc(name, method, strconv.Itoa(recorder.status))
+		requestDuration.Observe(time.Since(start).Seconds(), name)
+	}
+}
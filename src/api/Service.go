@@ -1,9 +1,30 @@
 package api
 
-import "chatServer/src/chatserver/data"
+import (
+	"math"
+
+	"chatServer/src/chatserver/data"
+)
+
+// noID is the id of the optional query parameters that are not given, e.g. no viewer or the messages of all the users
+const noID = math.MaxInt64
 
 // Service interface for api
 type Service interface {
 	PostMessage(message data.Message) (data.Message, error)
-	GetMessages(userID int, roomID int) []data.Message
+	GetMessages(viewerID int, userID int, roomID int) []data.Message
+	GetRooms(userID int) []data.Room
+	GetRoom(roomID int, userID int) (data.Room, error)
+	UpdateRoom(roomID int, update data.RoomUpdate) (data.Room, error)
+	DeleteRoom(roomID int, userID int) error
+	CreateWebhook(roomID int, request data.WebhookRequest) (data.IncomingWebhook, error)
+	GetWebhooks(roomID int, userID int) ([]data.IncomingWebhook, error)
+	RevokeWebhook(roomID int, webhookID int, userID int) error
+	PostWebhookMessage(token string, message data.WebhookMessage) (data.Message, error)
+	KillUser(userID int, reason string) error
+	SetAdmin(userID int, admin bool) (data.User, error)
+	Broadcast(text string) []data.Message
+	GetPresence(userID int) (data.Presence, error)
+	GetProfile(userID int) (data.Profile, error)
+	UpdateProfile(userID int, update data.ProfileUpdate) (data.Profile, error)
 }
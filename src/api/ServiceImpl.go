@@ -1,7 +1,11 @@
 package api
 
 import (
-	"errors"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
 
 	"chatServer/src/chatserver"
 	"chatServer/src/chatserver/data"
@@ -11,6 +15,9 @@ import (
 // ServiceImpl struct for api service
 type ServiceImpl struct {
 	chatService chatserver.Service
+	webhooks map[string]*data.IncomingWebhook // incoming webhooks by token
+	nextWebhookID int
+	sync.Mutex
 }
 
 
@@ -18,6 +25,7 @@ type ServiceImpl struct {
 func NewServiceImpl(chatService chatserver.Service, ) *ServiceImpl {
 	return &ServiceImpl{
 		chatService: chatService,
+		webhooks: make(map[string]*data.IncomingWebhook),
 	}
 }
 
@@ -27,38 +35,50 @@ func (service *ServiceImpl) PostMessage(message data.Message) (data.Message, err
 
 	//validate userID and roomID
 	_, userOk := service.chatService.GetUser(message.UserID)
-	_, roomOk := service.chatService.GetRoom(message.RoomID)
+	room, roomOk := service.chatService.GetRoom(message.RoomID)
 	if message.UserID != 0 && !userOk {
-		return data.Message{}, errors.New("User not found")
+		return data.Message{}, ErrUserNotFound
 	}
 
 	if message.RoomID != 0 && !roomOk {
-		return data.Message{}, errors.New("Room not found")
+		return data.Message{}, ErrRoomNotFound
 	}
 
-	messageResponse := service.chatService.Publish(data.Input{
+	if room.Archived {
+		return data.Message{}, ErrRoomArchived
+	}
+
+	return service.chatService.Publish(data.Input{
 		Room: message.RoomID,
 		Text:	message.Text,
 	}, message.UserID, false)
-	return messageResponse, nil
 }
 
 
-// GetMessages service is for retrieving messages
-func (service *ServiceImpl) GetMessages(userID int, roomID int) []data.Message {
+// GetMessages service is for retrieving the messages of a user or a room, only the messages of the rooms the viewer
+// can read are returned and everyone can only read the public rooms when no viewer is given
+func (service *ServiceImpl) GetMessages(viewerID int, userID int, roomID int) []data.Message {
 	messages := service.chatService.GetMessages()
-
-	// if userId and roomId are not provided return all messages
-	if userID == 9223372036854775807 && roomID == 9223372036854775807 {
-		return messages
+	readable := map[int]bool{}
+	canRead := func(msgRoomID int) bool {
+		if allowed, checked := readable[msgRoomID]; checked {
+			return allowed
+		}
+		readable[msgRoomID] = service.chatService.CanReadRoom(viewerID, msgRoomID)
+		return readable[msgRoomID]
 	}
 
 	filteredMessages := []data.Message{}
 	// filter messages based on the query parameters
 	for index, msg := range messages {
-		if msg.UserID == userID  && roomID == 9223372036854775807 { // if only userId is provided
+		if !canRead(msg.RoomID) {
+			continue
+		}
+		if userID == noID && roomID == noID { // if userId and roomId are not provided
+			filteredMessages = append(filteredMessages, messages[index])
+		} else if msg.UserID == userID  && roomID == noID { // if only userId is provided
 			filteredMessages = append(filteredMessages, messages[index])
-		} else if msg.RoomID == roomID  && userID == 9223372036854775807 { // if only roomId is provided
+		} else if msg.RoomID == roomID  && userID == noID { // if only roomId is provided
 			filteredMessages = append(filteredMessages, messages[index])
 		} else if msg.RoomID == roomID && msg.UserID == userID { // if both userId and roomId are provided
 			filteredMessages = append(filteredMessages, messages[index])
@@ -66,3 +86,248 @@ func (service *ServiceImpl) GetMessages(userID int, roomID int) []data.Message {
 	}
 	return filteredMessages
 }
+
+
+// GetRooms service is for retrieving the rooms visible to a user
+func (service *ServiceImpl) GetRooms(userID int) []data.Room {
+	return service.chatService.GetVisibleRooms(userID)
+}
+
+
+// GetRoom service is for retrieving a room visible to a user
+func (service *ServiceImpl) GetRoom(roomID int, userID int) (data.Room, error) {
+	room, roomOk := service.chatService.GetRoom(roomID)
+	if !roomOk || !service.chatService.CanViewRoom(userID, roomID) {
+		return data.Room{}, ErrRoomNotFound
+	}
+	return room, nil
+}
+
+
+// UpdateRoom service is for renaming, archiving and restoring a room and for changing its welcome message
+func (service *ServiceImpl) UpdateRoom(roomID int, update data.RoomUpdate) (data.Room, error) {
+	room, err := service.getManagedRoom(roomID, update.UserID)
+	if err != nil {
+		return data.Room{}, err
+	}
+
+	// the chat server checks the name, its errors are the ones of the api
+	if update.Name != nil && *update.Name != room.Name {
+		if room, err = service.chatService.RenameRoom(update.UserID, roomID, *update.Name); err != nil {
+			return data.Room{}, err
+		}
+	}
+	if update.Welcome != nil && *update.Welcome != room.Welcome {
+		if room, err = service.chatService.SetWelcome(update.UserID, roomID, *update.Welcome); err != nil {
+			return data.Room{}, err
+		}
+	}
+	if update.Archived != nil && *update.Archived != room.Archived {
+		if room, err = service.chatService.ArchiveRoom(update.UserID, roomID, *update.Archived); err != nil {
+			return data.Room{}, err
+		}
+	}
+	return room, nil
+}
+
+
+// DeleteRoom service is for deleting a room
+func (service *ServiceImpl) DeleteRoom(roomID int, userID int) error {
+	_, err := service.getManagedRoom(roomID, userID)
+	if err != nil {
+		return err
+	}
+	_, err = service.chatService.DeleteRoom(userID, roomID)
+	return err
+}
+
+
+// getManagedRoom gets the room if the user is allowed to rename, archive or delete it
+func (service *ServiceImpl) getManagedRoom(roomID int, userID int) (data.Room, error) {
+	if _, userOk := service.chatService.GetUser(userID); !userOk {
+		return data.Room{}, ErrUserNotFound
+	}
+	room, err := service.GetRoom(roomID, userID)
+	if err != nil {
+		return data.Room{}, err
+	}
+	if roomID == 0 || room.CreatorID != userID {
+		return data.Room{}, ErrForbidden
+	}
+	return room, nil
+}
+
+
+// CreateWebhook service is for creating an incoming webhook that posts to a room as a new bot user
+func (service *ServiceImpl) CreateWebhook(roomID int, request data.WebhookRequest) (data.IncomingWebhook, error) {
+	room, err := service.getMemberRoom(roomID, request.UserID)
+	if err != nil {
+		return data.IncomingWebhook{}, err
+	}
+	if room.Archived {
+		return data.IncomingWebhook{}, ErrRoomArchived
+	}
+	token, err := newToken()
+	if err != nil {
+		return data.IncomingWebhook{}, err
+	}
+	name := request.Name
+	if name == "" {
+		name = "Webhook"
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	webhook := &data.IncomingWebhook{
+		ID: service.nextWebhookID,
+		RoomID: roomID,
+		Name: name,
+		UserID: service.chatService.CreateBotUser(name).ID,
+		CreatorID: request.UserID,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Token: token,
+	}
+	if _, err := service.chatService.SubscribeBot(webhook.UserID, roomID); err != nil { // the room was deleted meanwhile
+		service.chatService.RemoveUser(webhook.UserID)
+		return data.IncomingWebhook{}, err
+	}
+	service.nextWebhookID++
+	service.webhooks[token] = webhook
+	created := *webhook
+	created.URL = WebhookPath + token
+	return created, nil
+}
+
+
+// GetWebhooks service is for listing the incoming webhooks of a room, their tokens are not returned
+func (service *ServiceImpl) GetWebhooks(roomID int, userID int) ([]data.IncomingWebhook, error) {
+	if _, err := service.getMemberRoom(roomID, userID); err != nil {
+		return nil, err
+	}
+	service.Lock()
+	defer service.Unlock()
+	webhooks := []data.IncomingWebhook{}
+	for _, webhook := range service.webhooks {
+		if webhook.RoomID == roomID {
+			webhooks = append(webhooks, *webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+
+// RevokeWebhook service is for revoking an incoming webhook, only its creator and the creator of the room can revoke it
+func (service *ServiceImpl) RevokeWebhook(roomID int, webhookID int, userID int) error {
+	room, err := service.getMemberRoom(roomID, userID)
+	if err != nil {
+		return err
+	}
+	service.Lock()
+	defer service.Unlock()
+	for token, webhook := range service.webhooks {
+		if webhook.ID != webhookID || webhook.RoomID != roomID {
+			continue
+		}
+		if webhook.CreatorID != userID && room.CreatorID != userID {
+			return ErrForbidden
+		}
+		delete(service.webhooks, token)
+		service.chatService.RemoveUser(webhook.UserID)
+		return nil
+	}
+	return ErrWebhookNotFound
+}
+
+
+// PostWebhookMessage service is for posting the message received by an incoming webhook
+func (service *ServiceImpl) PostWebhookMessage(token string, message data.WebhookMessage) (data.Message, error) {
+	service.Lock()
+	webhook, ok := service.webhooks[token]
+	service.Unlock()
+	if !ok {
+		return data.Message{}, ErrWebhookNotFound
+	}
+	return service.chatService.Publish(data.Input{
+		Room: webhook.RoomID,
+		Text: message.Text,
+		UserName: message.UserName,
+	}, webhook.UserID, false)
+}
+
+
+// KillUser service is for disconnecting a user, the reason is shown to the user
+func (service *ServiceImpl) KillUser(userID int, reason string) error {
+	return service.chatService.Disconnect(userID, reason)
+}
+
+
+// SetAdmin service is for granting or revoking the admin role of a user
+func (service *ServiceImpl) SetAdmin(userID int, admin bool) (data.User, error) {
+	return service.chatService.SetAdmin(userID, admin)
+}
+
+
+// Broadcast service is for sending a System message to every room
+func (service *ServiceImpl) Broadcast(text string) []data.Message {
+	return service.chatService.Broadcast(text)
+}
+
+
+// GetPresence service is for getting the presence of a user
+func (service *ServiceImpl) GetPresence(userID int) (data.Presence, error) {
+	presence, found := service.chatService.GetPresence(userID)
+	if !found {
+		return data.Presence{}, ErrUserNotFound
+	}
+	return presence, nil
+}
+
+
+// GetProfile service is for getting the profile of a user
+func (service *ServiceImpl) GetProfile(userID int) (data.Profile, error) {
+	profile, found := service.chatService.GetProfile(userID)
+	if !found {
+		return data.Profile{}, ErrUserNotFound
+	}
+	return profile, nil
+}
+
+
+// UpdateProfile service is for changing the display name, the bio or the timezone of a user, by the user or an admin
+func (service *ServiceImpl) UpdateProfile(userID int, update data.ProfileUpdate) (data.Profile, error) {
+	caller, callerOk := service.chatService.GetUser(update.UserID)
+	if !callerOk {
+		return data.Profile{}, ErrUserNotFound
+	}
+	if caller.ID != userID && !caller.Admin {
+		return data.Profile{}, ErrNotProfileOwner
+	}
+	return service.chatService.SetProfile(userID, update)
+}
+
+
+// getMemberRoom gets the room if the user is subscribed to it
+func (service *ServiceImpl) getMemberRoom(roomID int, userID int) (data.Room, error) {
+	if _, userOk := service.chatService.GetUser(userID); !userOk {
+		return data.Room{}, ErrUserNotFound
+	}
+	room, err := service.GetRoom(roomID, userID)
+	if err != nil {
+		return data.Room{}, err
+	}
+	if _, member := room.Users[userID]; !member {
+		return data.Room{}, ErrNotSubscribed
+	}
+	return room, nil
+}
+
+
+// newToken returns a random token for the url of an incoming webhook
+func newToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/onsi/ginkgo"
@@ -8,6 +10,7 @@ import (
 
 	"chatServer/src/chatserver"
 	"chatServer/src/chatserver/data"
+	"chatServer/testhelpers"
 )
 
 func TestServiceImpl(t *testing.T) {
@@ -28,7 +31,7 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			chatServiceMock := &chatserver.ServiceMock{}
 			service := createService(chatServiceMock)
 			chatServiceMock.On("GetMessages").Return(messages)
-			responseMessages := service.GetMessages(9223372036854775807, 9223372036854775807)
+			responseMessages := service.GetMessages(noID, noID, noID)
 			gomega.Expect(len(responseMessages)).To(gomega.Equal(3))
 		})
 
@@ -37,7 +40,7 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			chatServiceMock := &chatserver.ServiceMock{}
 			service := createService(chatServiceMock)
 			chatServiceMock.On("GetMessages").Return(messages)
-			responseMessages := service.GetMessages(1, 9223372036854775807)
+			responseMessages := service.GetMessages(noID, 1, noID)
 			gomega.Expect(len(responseMessages)).To(gomega.Equal(1))
 		})
 
@@ -46,7 +49,7 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			chatServiceMock := &chatserver.ServiceMock{}
 			service := createService(chatServiceMock)
 			chatServiceMock.On("GetMessages").Return(messages)
-			responseMessages := service.GetMessages(9223372036854775807, 0)
+			responseMessages := service.GetMessages(noID, noID, 0)
 			gomega.Expect(len(responseMessages)).To(gomega.Equal(3))
 		})
 
@@ -55,11 +58,179 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			chatServiceMock := &chatserver.ServiceMock{}
 			service := createService(chatServiceMock)
 			chatServiceMock.On("GetMessages").Return(messages)
-			responseMessages := service.GetMessages(1, 0)
+			responseMessages := service.GetMessages(noID, 1, 0)
 			gomega.Expect(len(responseMessages)).To(gomega.Equal(1))
 		})
 	})
 
+	ginkgo.Context("Private rooms", func() {
+
+		// createChatService returns a chat server where alice posted to her private room Secret
+		createChatService := func() (chatserver.Service, data.Room) {
+			chatService := chatserver.NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+			chatService.Run()
+			alice := chatService.CreateUser("alice")
+			chatService.CreateUser("bob")
+			room, _ := chatService.CreateRoom("Secret", alice.ID, data.VisibilityPrivate, "")
+			chatService.Publish(data.Input{Room: room.ID, Text: "for members only"}, alice.ID, false)
+			return chatService, room
+		}
+
+		ginkgo.It("Returns the messages of a private room only to its members", func() {
+			chatService, room := createChatService()
+			service := createService(chatService)
+			gomega.Expect(service.GetMessages(2, noID, room.ID)).To(gomega.BeEmpty())
+			gomega.Expect(service.GetMessages(noID, noID, room.ID)).To(gomega.BeEmpty())
+			gomega.Expect(service.GetMessages(noID, noID, noID)).To(gomega.BeEmpty())
+			gomega.Expect(service.GetMessages(1, noID, room.ID)).To(gomega.HaveLen(1))
+		})
+
+		ginkgo.It("Returns the messages of the other members to a member and filters them by user", func() {
+			chatService, room := createChatService()
+			chatService.Invite(1, "bob", room.ID)
+			chatService.AcceptInvite(2, room.ID)
+			service := createService(chatService)
+			gomega.Expect(service.GetMessages(2, noID, room.ID)).To(gomega.HaveLen(1))
+			gomega.Expect(service.GetMessages(2, 1, noID)).To(gomega.HaveLen(1))
+			gomega.Expect(service.GetMessages(2, 2, room.ID)).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("Rejects the messages of the users who are not members", func() {
+			chatService, room := createChatService()
+			service := createService(chatService)
+			_, err := service.PostMessage(data.Message{UserID: 2, RoomID: room.ID, Text: "let me in"})
+			gomega.Expect(err).To(gomega.Equal(ErrNotSubscribed))
+			gomega.Expect(chatService.GetMessages()).To(gomega.HaveLen(1))
+		})
+	})
+
+	ginkgo.Context("GetRooms", func() {
+
+		ginkgo.It("Return the rooms visible to the user", func() {
+
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			responseRooms := service.GetRooms(1)
+			gomega.Expect(len(responseRooms)).To(gomega.Equal(2))
+			gomega.Expect(responseRooms[0].Name).To(gomega.Equal("Default"))
+		})
+	})
+
+	ginkgo.Context("GetRoom", func() {
+
+		ginkgo.It("Return the room with its details", func() {
+
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			room, err := service.GetRoom(0, 1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Topic).To(gomega.Equal("General discussion"))
+		})
+
+		ginkgo.It("Return failure when the room is not visible", func() {
+
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			_, err := service.GetRoom(3, 1)
+			gomega.Expect(err.Error()).To(gomega.Equal("Room not found"))
+		})
+	})
+
+	ginkgo.Context("UpdateRoom", func() {
+
+		ginkgo.It("Update room returns the room", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			archived := true
+			room, err := service.UpdateRoom(1, data.RoomUpdate{UserID: 1, Archived: &archived})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Tech"))
+		})
+
+		ginkgo.It("Update room changes the welcome message", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			welcome := "Be nice"
+			room, err := service.UpdateRoom(1, data.RoomUpdate{UserID: 1, Welcome: &welcome})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Tech"))
+		})
+
+		ginkgo.It("Update room returns failure when the user is not the creator", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			name := "Talk"
+			_, err := service.UpdateRoom(1, data.RoomUpdate{UserID: 2, Name: &name})
+			gomega.Expect(err).To(gomega.Equal(ErrForbidden))
+		})
+
+		ginkgo.It("Update room returns failure when the name is taken", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			name := "Default"
+			_, err := service.UpdateRoom(1, data.RoomUpdate{UserID: 1, Name: &name})
+			gomega.Expect(err).To(gomega.Equal(ErrRoomExists))
+		})
+
+		ginkgo.It("Update room returns failure when the name is a number", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			name := "42"
+			_, err := service.UpdateRoom(1, data.RoomUpdate{UserID: 1, Name: &name})
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNameInvalid))
+		})
+
+		ginkgo.It("Update room renames the room", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			name := "Talk"
+			room, err := service.UpdateRoom(1, data.RoomUpdate{UserID: 1, Name: &name})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Talk"))
+		})
+	})
+
+	ginkgo.Context("UpdateProfile", func() {
+
+		ginkgo.It("Update profile changes the profile of the user itself", func() {
+			service := createService(&chatserver.ServiceMock{})
+			bio := "Gopher"
+			_, err := service.UpdateProfile(1, data.ProfileUpdate{UserID: 1, Bio: &bio})
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("Update profile lets an admin change the profile of another user", func() {
+			service := createService(&chatserver.ServiceMock{})
+			bio := "Gopher"
+			_, err := service.UpdateProfile(1, data.ProfileUpdate{UserID: 3, Bio: &bio})
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("Update profile returns failure when another user changes the profile", func() {
+			service := createService(&chatserver.ServiceMock{})
+			bio := "Gopher"
+			_, err := service.UpdateProfile(1, data.ProfileUpdate{UserID: 2, Bio: &bio})
+			gomega.Expect(err).To(gomega.Equal(ErrNotProfileOwner))
+			_, err = service.UpdateProfile(1, data.ProfileUpdate{UserID: 9, Bio: &bio})
+			gomega.Expect(err).To(gomega.Equal(ErrUserNotFound))
+		})
+	})
+
+	ginkgo.Context("DeleteRoom", func() {
+
+		ginkgo.It("Delete room returns failure for the Default room", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			gomega.Expect(service.DeleteRoom(0, 1)).To(gomega.Equal(ErrForbidden))
+		})
+
+		ginkgo.It("Delete room returns failure when the room does not exist", func() {
+			chatServiceMock := &chatserver.ServiceMock{}
+			service := createService(chatServiceMock)
+			gomega.Expect(service.DeleteRoom(7, 1)).To(gomega.Equal(ErrRoomNotFound))
+		})
+	})
+
 	ginkgo.Context("PostMessage", func() {
 
 		ginkgo.It("Post message returns success", func() {
@@ -95,6 +266,68 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			gomega.Expect(err.Error()).To(gomega.Equal("User not found"))
 		})
 	})
+
+	ginkgo.Context("Webhooks", func() {
+
+		// createChatService returns a chat server with the users 1 and 2, user 1 created the room Tech
+		createChatService := func() chatserver.Service {
+			chatService := chatserver.NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+			chatService.Run()
+			chatService.CreateUser("alice")
+			chatService.CreateUser("bob")
+			chatService.CreateRoom("Tech", 1, "", "")
+			return chatService
+		}
+
+		ginkgo.It("Creates a webhook that posts to the room as a bot user", func() {
+			chatService := createChatService()
+			service := createService(chatService)
+			webhook, err := service.CreateWebhook(1, data.WebhookRequest{UserID: 1, Name: "CI"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(webhook.URL).To(gomega.HavePrefix(WebhookPath))
+			bot, _ := chatService.GetUser(webhook.UserID)
+			gomega.Expect(bot.Bot).To(gomega.BeTrue())
+
+			message, err := service.PostWebhookMessage(strings.TrimPrefix(webhook.URL, WebhookPath), data.WebhookMessage{Text: "Build passed"})
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(message.RoomID).To(gomega.Equal(1))
+			gomega.Expect(message.UserName).To(gomega.Equal("CI"))
+
+			message, _ = service.PostWebhookMessage(strings.TrimPrefix(webhook.URL, WebhookPath), data.WebhookMessage{Text: "Deployed", UserName: "Jenkins"})
+			gomega.Expect(message.UserName).To(gomega.Equal("Jenkins"))
+		})
+
+		ginkgo.It("Only lets the members of the room create and list webhooks", func() {
+			service := createService(createChatService())
+			_, err := service.CreateWebhook(1, data.WebhookRequest{UserID: 2})
+			gomega.Expect(err).To(gomega.Equal(ErrNotSubscribed))
+			_, err = service.CreateWebhook(5, data.WebhookRequest{UserID: 1})
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNotFound))
+
+			service.CreateWebhook(1, data.WebhookRequest{UserID: 1})
+			webhooks, err := service.GetWebhooks(1, 1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(webhooks).To(gomega.HaveLen(1))
+			gomega.Expect(webhooks[0].Name).To(gomega.Equal("Webhook"))
+			gomega.Expect(webhooks[0].URL).To(gomega.BeEmpty())
+			_, err = service.GetWebhooks(1, 2)
+			gomega.Expect(err).To(gomega.Equal(ErrNotSubscribed))
+		})
+
+		ginkgo.It("Revokes a webhook so its token stops working", func() {
+			chatService := createChatService()
+			service := createService(chatService)
+			chatService.Subscribe(2, 1, "")
+			webhook, _ := service.CreateWebhook(1, data.WebhookRequest{UserID: 1})
+			token := strings.TrimPrefix(webhook.URL, WebhookPath)
+
+			gomega.Expect(service.RevokeWebhook(1, webhook.ID, 2)).To(gomega.Equal(ErrForbidden))
+			gomega.Expect(service.RevokeWebhook(1, 7, 1)).To(gomega.Equal(ErrWebhookNotFound))
+			gomega.Expect(service.RevokeWebhook(1, webhook.ID, 1)).To(gomega.BeNil())
+			_, err := service.PostWebhookMessage(token, data.WebhookMessage{Text: "hello"})
+			gomega.Expect(err).To(gomega.Equal(ErrWebhookNotFound))
+		})
+	})
 })
 
 var users = []data.User {
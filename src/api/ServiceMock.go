@@ -23,17 +23,152 @@ func (mock *ServiceMock) PostMessage(message data.Message) (data.Message, error)
 	if args.Get(0).(data.Message) != (data.Message{}) {
 		return args.Get(0).(data.Message), nil
 	}
+	if err := args.Error(1); err != nil {
+		return args.Get(0).(data.Message), err
+	}
 	return args.Get(0).(data.Message), errors.New("")
 }
 
 
 // GetMessages mocks the Service GetMessages method
-func (mock *ServiceMock) GetMessages(userID int, roomID int) (msgs []data.Message) {
+func (mock *ServiceMock) GetMessages(viewerID int, userID int, roomID int) (msgs []data.Message) {
 
-	args := mock.Called(userID, roomID)
+	args := mock.Called(viewerID, userID, roomID)
 
 	if args.Get(0) != nil {
 		msgs = args.Get(0).([]data.Message)
 	}
 	return
-}
\ No newline at end of file
+}
+
+
+// GetRooms mocks the Service GetRooms method
+func (mock *ServiceMock) GetRooms(userID int) (rooms []data.Room) {
+
+	args := mock.Called(userID)
+
+	if args.Get(0) != nil {
+		rooms = args.Get(0).([]data.Room)
+	}
+	return
+}
+
+
+// GetRoom mocks the Service GetRoom method
+func (mock *ServiceMock) GetRoom(roomID int, userID int) (data.Room, error) {
+
+	args := mock.Called(roomID, userID)
+
+	if args.Get(0).(data.Room).Name != "" {
+		return args.Get(0).(data.Room), nil
+	}
+	return args.Get(0).(data.Room), ErrRoomNotFound
+}
+
+
+// UpdateRoom mocks the Service UpdateRoom method
+func (mock *ServiceMock) UpdateRoom(roomID int, update data.RoomUpdate) (data.Room, error) {
+
+	args := mock.Called(roomID, update.UserID)
+
+	return args.Get(0).(data.Room), args.Error(1)
+}
+
+
+// DeleteRoom mocks the Service DeleteRoom method
+func (mock *ServiceMock) DeleteRoom(roomID int, userID int) error {
+
+	args := mock.Called(roomID, userID)
+
+	return args.Error(0)
+}
+
+
+// CreateWebhook mocks the Service CreateWebhook method
+func (mock *ServiceMock) CreateWebhook(roomID int, request data.WebhookRequest) (data.IncomingWebhook, error) {
+
+	args := mock.Called(roomID, request.UserID)
+
+	return args.Get(0).(data.IncomingWebhook), args.Error(1)
+}
+
+
+// GetWebhooks mocks the Service GetWebhooks method
+func (mock *ServiceMock) GetWebhooks(roomID int, userID int) ([]data.IncomingWebhook, error) {
+
+	args := mock.Called(roomID, userID)
+
+	return args.Get(0).([]data.IncomingWebhook), args.Error(1)
+}
+
+
+// RevokeWebhook mocks the Service RevokeWebhook method
+func (mock *ServiceMock) RevokeWebhook(roomID int, webhookID int, userID int) error {
+
+	args := mock.Called(roomID, webhookID, userID)
+
+	return args.Error(0)
+}
+
+
+// PostWebhookMessage mocks the Service PostWebhookMessage method
+func (mock *ServiceMock) PostWebhookMessage(token string, message data.WebhookMessage) (data.Message, error) {
+
+	args := mock.Called(token, message.Text)
+
+	return args.Get(0).(data.Message), args.Error(1)
+}
+
+
+// KillUser mocks the Service KillUser method
+func (mock *ServiceMock) KillUser(userID int, reason string) error {
+
+	args := mock.Called(userID, reason)
+
+	return args.Error(0)
+}
+
+
+// SetAdmin mocks the Service SetAdmin method
+func (mock *ServiceMock) SetAdmin(userID int, admin bool) (data.User, error) {
+
+	args := mock.Called(userID, admin)
+
+	return args.Get(0).(data.User), args.Error(1)
+}
+
+
+// Broadcast mocks the Service Broadcast method
+func (mock *ServiceMock) Broadcast(text string) []data.Message {
+
+	args := mock.Called(text)
+
+	return args.Get(0).([]data.Message)
+}
+
+
+// GetPresence mocks the Service GetPresence method
+func (mock *ServiceMock) GetPresence(userID int) (data.Presence, error) {
+
+	args := mock.Called(userID)
+
+	return args.Get(0).(data.Presence), args.Error(1)
+}
+
+
+// GetProfile mocks the Service GetProfile method
+func (mock *ServiceMock) GetProfile(userID int) (data.Profile, error) {
+
+	args := mock.Called(userID)
+
+	return args.Get(0).(data.Profile), args.Error(1)
+}
+
+
+// UpdateProfile mocks the Service UpdateProfile method
+func (mock *ServiceMock) UpdateProfile(userID int, update data.ProfileUpdate) (data.Profile, error) {
+
+	args := mock.Called(userID, update)
+
+	return args.Get(0).(data.Profile), args.Error(1)
+}
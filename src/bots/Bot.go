@@ -0,0 +1,120 @@
+package bots
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/connections"
+	"chatServer/src/logging"
+)
+
+// ErrUnknownBot is returned when the config refers to a bot type that is not registered
+var ErrUnknownBot = errors.New("Unknown bot type")
+
+// Bot is a plugin that runs inside the server as a bot user
+type Bot interface {
+	// Start is called once the bot user exists, the bot joins rooms and registers its commands here
+	Start(host *Host) error
+	// HandleMessage is called for every message published by other users to the rooms the bot joined
+	HandleMessage(message data.Message)
+	// Stop is called before the bot user is removed
+	Stop()
+}
+
+// Factory creates a new instance of a bot
+type Factory func() Bot
+
+var factories = struct {
+	types map[string]Factory
+	sync.RWMutex
+}{types: make(map[string]Factory)}
+
+// RegisterFactory makes a bot type available to the config, it is meant to be called from init
+func RegisterFactory(botType string, factory Factory) {
+	factories.Lock()
+	defer factories.Unlock()
+	factories.types[botType] = factory
+}
+
+// newBot creates a bot of the registered type
+func newBot(botType string) (Bot, error) {
+	factories.RLock()
+	defer factories.RUnlock()
+	factory, ok := factories.types[botType]
+	if !ok {
+		return nil, ErrUnknownBot
+	}
+	return factory(), nil
+}
+
+// Host gives a bot access to the chat server
+type Host struct {
+	User        data.User
+	Settings    map[string]string
+	Logger      *logging.Logger // tagged with the name of the bot, it can be nil
+	chatService chatserver.Service
+	commands    *connections.CommandRegistry
+	registered  []string
+}
+
+// Setting returns the setting of the bot or the fallback when it is not set
+func (host *Host) Setting(key string, fallback string) string {
+	if value, ok := host.Settings[key]; ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Join subscribes the bot user to the room referenced by id, name or #name
+func (host *Host) Join(reference string) (data.Room, error) {
+	room, found := host.chatService.FindRoom(host.User.ID, reference)
+	if !found {
+		return data.Room{}, chatserver.ErrRoomNotFound
+	}
+	if _, member := room.Users[host.User.ID]; member {
+		return room, nil
+	}
+	return host.chatService.Subscribe(host.User.ID, room.ID, "")
+}
+
+// Post publishes a message to a room as the bot user
+func (host *Host) Post(roomID int, text string) (data.Message, error) {
+	return host.chatService.Publish(data.Input{
+		Text: text,
+		Room: roomID,
+	}, host.User.ID, false)
+}
+
+// RegisterCommand adds a telnet command that is removed again when the bot stops
+func (host *Host) RegisterCommand(command connections.Command) error {
+	if err := host.commands.Register(command); err != nil {
+		return err
+	}
+	host.registered = append(host.registered, command.Name)
+	return nil
+}
+
+// ChatService returns the chat server for the bots that need more than posting messages
+func (host *Host) ChatService() chatserver.Service {
+	return host.chatService
+}
+
+// joinRooms joins the comma separated rooms of the rooms setting, the bot stays in the Default room
+func joinRooms(host *Host) []data.Room {
+	rooms := []data.Room{}
+	for _, reference := range strings.Split(host.Setting("rooms", ""), ",") {
+		if reference = strings.TrimSpace(reference); reference == "" {
+			continue
+		}
+		room, err := host.Join(reference)
+		if err != nil {
+			host.Logger.Warn("The bot cannot join a room", "room", reference, "error", err)
+			continue
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
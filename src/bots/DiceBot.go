@@ -0,0 +1,113 @@
+package bots
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chatServer/src/chatserver/data"
+	"chatServer/src/connections"
+)
+
+func init() {
+	RegisterFactory("dice", func() Bot { return &DiceBot{} })
+}
+
+// errInvalidDice is returned for dice that are not written as NdM
+var errInvalidDice = errors.New("Dice must be given as NdM, e.g. 2d6, with up to 20 dice of up to 1000 sides")
+
+// DiceBot rolls dice for the messages starting with !roll and adds the /roll command
+type DiceBot struct {
+	host   *Host
+	random *rand.Rand
+	sync.Mutex
+}
+
+// Start joins the configured rooms and registers /roll
+func (bot *DiceBot) Start(host *Host) error {
+	bot.host = host
+	bot.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	joinRooms(host)
+	return host.RegisterCommand(connections.Command{
+		Name:    "roll",
+		Args:    []connections.Argument{{Name: "dice", Optional: true}},
+		Help:    "rolls dice in your active room, e.g. /roll 2d6",
+		Handler: bot.roll,
+	})
+}
+
+// HandleMessage rolls the dice following !roll in the room of the message
+func (bot *DiceBot) HandleMessage(message data.Message) {
+	fields := strings.Fields(message.Text)
+	if len(fields) == 0 || fields[0] != "!roll" {
+		return
+	}
+	dice := ""
+	if len(fields) > 1 {
+		dice = fields[1]
+	}
+	result, err := bot.rollDice(dice)
+	if err != nil {
+		bot.host.Post(message.RoomID, err.Error())
+		return
+	}
+	bot.host.Post(message.RoomID, message.UserName+" rolled "+result)
+}
+
+// Stop stops the bot
+func (bot *DiceBot) Stop() {
+}
+
+// roll posts the result to the active room of the user who ran the command
+func (bot *DiceBot) roll(ctx *connections.CommandContext) {
+	result, err := bot.rollDice(ctx.Arg(0))
+	if err != nil {
+		ctx.Error(err.Error() + "!!\n")
+		return
+	}
+	room, _ := bot.host.ChatService().GetActiveRoom(ctx.User.ID)
+	if _, err := bot.host.Join(strconv.Itoa(room.ID)); err != nil { // only the members post to a room
+		ctx.Error(err.Error() + "!!\n")
+		return
+	}
+	if _, err := bot.host.Post(room.ID, ctx.User.Name+" rolled "+result); err != nil {
+		ctx.Error(err.Error() + "!!\n")
+	}
+}
+
+// rollDice rolls dice written as NdM, one six sided die when the dice are empty
+func (bot *DiceBot) rollDice(dice string) (string, error) {
+	count, sides := 1, 6
+	if dice != "" {
+		parts := strings.SplitN(strings.ToLower(dice), "d", 2)
+		if len(parts) != 2 {
+			return "", errInvalidDice
+		}
+		var err error
+		if parts[0] != "" {
+			if count, err = strconv.Atoi(parts[0]); err != nil {
+				return "", errInvalidDice
+			}
+		}
+		if sides, err = strconv.Atoi(parts[1]); err != nil {
+			return "", errInvalidDice
+		}
+	}
+	if count < 1 || count > 20 || sides < 2 || sides > 1000 {
+		return "", errInvalidDice
+	}
+
+	bot.Lock()
+	defer bot.Unlock()
+	rolls := make([]string, count)
+	total := 0
+	for i := range rolls {
+		roll := bot.random.Intn(sides) + 1
+		total += roll
+		rolls[i] = strconv.Itoa(roll)
+	}
+	return strconv.Itoa(count) + "d" + strconv.Itoa(sides) + ": " + strings.Join(rolls, " ") + " = " + strconv.Itoa(total), nil
+}
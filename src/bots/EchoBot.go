@@ -0,0 +1,45 @@
+package bots
+
+import (
+	"strings"
+
+	"chatServer/src/chatserver/data"
+	"chatServer/src/connections"
+)
+
+func init() {
+	RegisterFactory("echo", func() Bot { return &EchoBot{} })
+}
+
+// EchoBot repeats the messages starting with !echo and adds the /echo command
+type EchoBot struct {
+	host *Host
+}
+
+// Start joins the configured rooms and registers /echo
+func (bot *EchoBot) Start(host *Host) error {
+	bot.host = host
+	joinRooms(host)
+	return host.RegisterCommand(connections.Command{
+		Name:    "echo",
+		Args:    []connections.Argument{{Name: "text", Rest: true}},
+		Help:    "echoes the text back, sent by " + host.User.Name,
+		Handler: bot.echo,
+	})
+}
+
+// HandleMessage repeats the text following !echo in the room of the message
+func (bot *EchoBot) HandleMessage(message data.Message) {
+	if text := strings.TrimSpace(strings.TrimPrefix(message.Text, "!echo")); text != message.Text && text != "" {
+		bot.host.Post(message.RoomID, text)
+	}
+}
+
+// Stop stops the bot
+func (bot *EchoBot) Stop() {
+}
+
+// echo replies to the user who ran the command
+func (bot *EchoBot) echo(ctx *connections.CommandContext) {
+	ctx.Reply(bot.host.User.Name + ": " + ctx.Arg(0) + "\n")
+}
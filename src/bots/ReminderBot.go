@@ -0,0 +1,60 @@
+package bots
+
+import (
+	"errors"
+	"time"
+
+	"chatServer/src/chatserver/data"
+)
+
+func init() {
+	RegisterFactory("reminder", func() Bot { return &ReminderBot{} })
+}
+
+// errInvalidInterval is returned when the interval setting is not a positive duration
+var errInvalidInterval = errors.New("interval must be a positive duration, e.g. 24h")
+
+// ReminderBot posts a reminder, e.g. for the daily standup, to its rooms at a fixed interval
+type ReminderBot struct {
+	host  *Host
+	rooms []data.Room
+	stop  chan struct{}
+}
+
+// Start joins the configured rooms and starts posting the text setting every interval
+func (bot *ReminderBot) Start(host *Host) error {
+	interval, err := time.ParseDuration(host.Setting("interval", "24h"))
+	if err != nil || interval <= 0 {
+		return errInvalidInterval
+	}
+	bot.host = host
+	bot.rooms = joinRooms(host)
+	bot.stop = make(chan struct{})
+	go bot.remind(interval, host.Setting("text", "Time for the standup!"))
+	return nil
+}
+
+// HandleMessage ignores the messages, the reminders are only driven by time
+func (bot *ReminderBot) HandleMessage(message data.Message) {
+}
+
+// Stop stops posting the reminders
+func (bot *ReminderBot) Stop() {
+	close(bot.stop)
+}
+
+// remind posts the text to the rooms of the bot on every tick
+func (bot *ReminderBot) remind(interval time.Duration, text string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, room := range bot.rooms {
+				bot.host.Post(room.ID, text)
+			}
+		case <-bot.stop:
+			return
+		}
+	}
+}
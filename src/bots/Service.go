@@ -0,0 +1,7 @@
+package bots
+
+// Service interface for the bots running inside the server
+type Service interface {
+	Start()
+	Stop()
+}
@@ -0,0 +1,128 @@
+package bots
+
+import (
+	"sync"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/src/connections"
+	"chatServer/src/logging"
+)
+
+// ServiceImpl struct for the bots service
+type ServiceImpl struct {
+	chatService chatserver.Service
+	commands    *connections.CommandRegistry
+	config      *config.Config
+	logger      *logging.Logger
+	running     []*runningBot
+	sync.Mutex
+}
+
+// runningBot is a started bot with its host and the channel that stops its event loop
+type runningBot struct {
+	bot  Bot
+	host *Host
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewServiceImpl returns ServiceImpl, a nil logger discards the server log
+func NewServiceImpl(chatService chatserver.Service, commands *connections.CommandRegistry, config *config.Config, logger *logging.Logger) *ServiceImpl {
+	return &ServiceImpl{
+		chatService: chatService,
+		commands:    commands,
+		config:      config,
+		logger:      logger.With("component", "bots"),
+	}
+}
+
+// Start starts the bots enabled in the config, a bot failing to start is logged and skipped
+func (service *ServiceImpl) Start() {
+	for _, botConfig := range service.config.Bots {
+		if !botConfig.Enabled {
+			continue
+		}
+		if err := service.StartBot(botConfig); err != nil {
+			service.logger.Error("Error starting a bot", "bot", botConfig.Name, "type", botConfig.Type, "error", err)
+		}
+	}
+}
+
+// StartBot creates the bot user and starts a bot of the configured type
+func (service *ServiceImpl) StartBot(botConfig config.BotConfig) error {
+	bot, err := newBot(botConfig.Type)
+	if err != nil {
+		return err
+	}
+	name := botConfig.Name
+	if name == "" {
+		name = botConfig.Type
+	}
+	host := &Host{
+		User:        service.chatService.CreateUser(name),
+		Settings:    botConfig.Settings,
+		Logger:      service.logger.With("bot", name),
+		chatService: service.chatService,
+		commands:    service.commands,
+	}
+	running := &runningBot{
+		bot:  bot,
+		host: host,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	// the event loop drains the output of the bot user, so it runs before the bot joins any room
+	go service.handleEvents(running)
+	if err := bot.Start(host); err != nil {
+		service.stopBot(running)
+		return err
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	service.running = append(service.running, running)
+	host.Logger.Info("The bot started", "type", botConfig.Type, "userId", host.User.ID)
+	return nil
+}
+
+// Stop stops all the running bots
+func (service *ServiceImpl) Stop() {
+	service.Lock()
+	running := service.running
+	service.running = nil
+	service.Unlock()
+	for _, r := range running {
+		r.bot.Stop()
+		service.stopBot(r)
+	}
+}
+
+// stopBot ends the event loop, removes the commands of the bot and the bot user
+func (service *ServiceImpl) stopBot(running *runningBot) {
+	close(running.stop)
+	<-running.done
+	for _, name := range running.host.registered {
+		service.commands.Unregister(name)
+	}
+	service.chatService.RemoveUser(running.host.User.ID)
+}
+
+// handleEvents passes the messages delivered to the bot user to the bot until it is stopped
+func (service *ServiceImpl) handleEvents(running *runningBot) {
+	defer close(running.done)
+	for {
+		select {
+		case event := <-running.host.User.Output:
+			switch event.Type {
+			case data.EventMessage:
+				running.bot.HandleMessage(event.Message)
+			case data.EventInvitation: // bots accept every invitation
+				service.chatService.AcceptInvite(running.host.User.ID, event.RoomID)
+			}
+		case <-running.stop:
+			return
+		}
+	}
+}
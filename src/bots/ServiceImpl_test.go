@@ -0,0 +1,179 @@
+package bots
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/src/connections"
+	"chatServer/testhelpers"
+)
+
+func TestServiceImpl(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Bots ServiceImpl unit Test Suite")
+}
+
+// recorderBot records the messages it receives and answers ping with pong
+type recorderBot struct {
+	host     *Host
+	messages chan data.Message
+	stopped  bool
+}
+
+func (bot *recorderBot) Start(host *Host) error {
+	bot.host = host
+	return nil
+}
+
+func (bot *recorderBot) HandleMessage(message data.Message) {
+	bot.messages <- message
+	if message.Text == "ping" {
+		bot.host.Post(message.RoomID, "pong")
+	}
+}
+
+func (bot *recorderBot) Stop() {
+	bot.stopped = true
+}
+
+var recorder *recorderBot
+
+func init() {
+	RegisterFactory("recorder", func() Bot {
+		recorder = &recorderBot{messages: make(chan data.Message, 10)}
+		return recorder
+	})
+}
+
+func createService(botConfigs ...config.BotConfig) (*ServiceImpl, chatserver.Service, *connections.CommandRegistry) {
+	chatService := chatserver.NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+	chatService.Run()
+	commands := connections.NewCommandRegistry()
+	return NewServiceImpl(chatService, commands, &config.Config{Bots: botConfigs}, nil), chatService, commands
+}
+
+// nextMessage waits for the next message delivered to the user
+func nextMessage(user data.User) data.Message {
+	select {
+	case event := <-user.Output:
+		return event.Message
+	case <-time.After(time.Second):
+		return data.Message{}
+	}
+}
+
+var _ = ginkgo.Describe("ServiceImpl", func() {
+
+	ginkgo.Context("Start", func() {
+		ginkgo.It("should start the enabled bots as bot users", func() {
+			service, chatService, _ := createService(
+				config.BotConfig{Type: "echo", Name: "EchoBot", Enabled: true},
+				config.BotConfig{Type: "dice", Enabled: false},
+				config.BotConfig{Type: "unknown", Enabled: true})
+			service.Start()
+			defer service.Stop()
+
+			users := chatService.GetUsers()
+			gomega.Expect(users).To(gomega.HaveLen(2))
+			gomega.Expect(users[1].Name).To(gomega.Equal("EchoBot"))
+		})
+
+		ginkgo.It("should join the configured rooms", func() {
+			service, chatService, _ := createService()
+			chatService.CreateRoom("Tech", chatserver.SystemUserID, "", "")
+			gomega.Expect(service.StartBot(config.BotConfig{Type: "dice", Settings: map[string]string{"rooms": "#Tech, Missing"}})).To(gomega.BeNil())
+			defer service.Stop()
+
+			room, _ := chatService.FindRoom(chatserver.SystemUserID, "Tech")
+			gomega.Expect(room.Users).To(gomega.HaveKeyWithValue(1, "dice"))
+		})
+
+		ginkgo.It("should reject reminders without a valid interval", func() {
+			service, chatService, _ := createService()
+			err := service.StartBot(config.BotConfig{Type: "reminder", Settings: map[string]string{"interval": "soon"}})
+			gomega.Expect(err).To(gomega.Equal(errInvalidInterval))
+			user, _ := chatService.GetUser(1)
+			gomega.Expect(user.Dead).To(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Context("Messages", func() {
+		ginkgo.It("should pass the messages to the bot and post its answers", func() {
+			service, chatService, _ := createService()
+			gomega.Expect(service.StartBot(config.BotConfig{Type: "recorder", Name: "Recorder"})).To(gomega.BeNil())
+			defer service.Stop()
+			alice := chatService.CreateUser("alice")
+
+			chatService.Publish(data.Input{Text: "ping", Room: chatserver.DefaultRoomID}, alice.ID, false)
+			gomega.Eventually(recorder.messages).Should(gomega.Receive(gomega.WithTransform(
+				func(message data.Message) string { return message.Text }, gomega.Equal("ping"))))
+			message := nextMessage(alice)
+			gomega.Expect(message.Text).To(gomega.Equal("pong"))
+			gomega.Expect(message.UserName).To(gomega.Equal("Recorder"))
+		})
+
+		ginkgo.It("should echo the messages starting with !echo", func() {
+			service, chatService, _ := createService()
+			service.StartBot(config.BotConfig{Type: "echo", Name: "EchoBot"})
+			defer service.Stop()
+			alice := chatService.CreateUser("alice")
+
+			chatService.Publish(data.Input{Text: "!echo hello there", Room: chatserver.DefaultRoomID}, alice.ID, false)
+			gomega.Expect(nextMessage(alice).Text).To(gomega.Equal("hello there"))
+		})
+	})
+
+	ginkgo.Context("Stop", func() {
+		ginkgo.It("should stop the bots and remove their commands and users", func() {
+			service, chatService, commands := createService()
+			service.StartBot(config.BotConfig{Type: "recorder"})
+			service.StartBot(config.BotConfig{Type: "echo"})
+			_, found := commands.Find("/echo")
+			gomega.Expect(found).To(gomega.BeTrue())
+
+			service.Stop()
+			_, found = commands.Find("/echo")
+			gomega.Expect(found).To(gomega.BeFalse())
+			gomega.Expect(recorder.stopped).To(gomega.BeTrue())
+			for _, user := range chatService.GetUsers()[1:] {
+				gomega.Expect(user.Dead).To(gomega.BeTrue())
+			}
+		})
+	})
+})
+
+var _ = ginkgo.Describe("DiceBot", func() {
+	bot := &DiceBot{}
+	ginkgo.BeforeEach(func() {
+		service, _, _ := createService()
+		bot.Start(&Host{commands: service.commands, chatService: service.chatService})
+	})
+
+	ginkgo.It("should roll one six sided die by default", func() {
+		result, err := bot.rollDice("")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result).To(gomega.MatchRegexp(`^1d6: [1-6] = [1-6]$`))
+	})
+
+	ginkgo.It("should roll NdM dice", func() {
+		result, err := bot.rollDice("3d1000")
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(result).To(gomega.HavePrefix("3d1000: "))
+		result, _ = bot.rollDice("d20")
+		gomega.Expect(result).To(gomega.HavePrefix("1d20: "))
+	})
+
+	ginkgo.It("should reject invalid dice", func() {
+		for _, dice := range []string{"6", "xd6", "2d", "0d6", "21d6", "1d1"} {
+			_, err := bot.rollDice(dice)
+			gomega.Expect(err).To(gomega.Equal(errInvalidDice))
+		}
+	})
+})
@@ -0,0 +1,31 @@
+package chatserver
+
+import "errors"
+
+// Errors returned by the chat server service
+var (
+	ErrUserNotFound      = errors.New("User not found")
+	ErrRoomNotFound      = errors.New("Room not found")
+	ErrRoomExists        = errors.New("Room with similar name already exists")
+	ErrRoomNameInvalid   = errors.New("Room name cannot be empty or a number")
+	ErrRoomArchived      = errors.New("Room is archived")
+	ErrRoomNotArchived   = errors.New("Room is not archived")
+	ErrInvalidVisibility = errors.New("Visibility must be public, private, invite or password")
+	ErrPasswordMissing   = errors.New("Password missing")
+	ErrIncorrectPassword = errors.New("Incorrect password")
+	ErrInviteOnly        = errors.New("Room is invite only")
+	ErrAlreadySubscribed = errors.New("Already subscribed")
+	ErrNotSubscribed     = errors.New("Not subscribed")
+	ErrAlreadyInRoom     = errors.New("Already in room")
+	ErrAlreadyInvited    = errors.New("Already invited")
+	ErrNoInvitation      = errors.New("No invitation")
+	ErrDefaultRoom       = errors.New("Default room cannot be changed")
+	ErrNotCreator        = errors.New("Only the creator can change the room")
+	ErrUserNotConnected  = errors.New("User is not connected")
+	ErrOperFailed        = errors.New("Name or password is not valid")
+	ErrPresenceInvalid   = errors.New("Presence must be online, away or busy")
+	ErrNameInvalid       = errors.New("Name must have 1 to 30 characters without spaces, cannot be a number and cannot start with @ or #")
+	ErrNameInUse         = errors.New("Name is already in use")
+	ErrProfileInvalid    = errors.New("Display name must have at most 50 characters and bio at most 300")
+	ErrTimezoneInvalid   = errors.New("Timezone must be a name such as Europe/Paris")
+)
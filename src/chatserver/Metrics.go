@@ -0,0 +1,17 @@
+package chatserver
+
+import "chatServer/src/metrics"
+
+// metrics of the chat server, exposed by the API on /metrics
+var (
+	messagesPublished = metrics.NewCounter("chat_messages_published_total",
+		"Messages published to the rooms, by user or system messages.", "source")
+	publishDuration = metrics.NewHistogram("chat_publish_duration_seconds",
+		"Time taken to store and fan out a message to the members of the room.", metrics.DefaultBuckets)
+	droppedSends = metrics.NewCounter("chat_dropped_sends_total",
+		"Events not delivered because the output of the user stayed full for a second, typing events are dropped right away.", "event")
+	droppedObserverEvents = metrics.NewCounter("chat_observer_events_dropped_total",
+		"Events not passed to the observers because their queue was full.")
+	roomsGauge  = metrics.NewGauge("chat_rooms", "Rooms, including the archived ones.")
+	usersOnline = metrics.NewGauge("chat_users_online", "Connected users, the in-process bots are not counted.")
+)
@@ -0,0 +1,131 @@
+package chatserver
+
+import (
+	"sort"
+	"time"
+
+	"chatServer/src/chatserver/data"
+)
+
+// idleCheckInterval is how often the idle users are marked away
+var idleCheckInterval = 10 * time.Second
+
+// SetPresence sets the presence of a connected user, online clears the status text
+func (service *ServiceImpl) SetPresence(userID int, state string, status string) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || user.Dead || userID == SystemUserID {
+		return data.User{}, ErrUserNotFound
+	}
+	if state != data.PresenceOnline && state != data.PresenceAway && state != data.PresenceBusy {
+		return data.User{}, ErrPresenceInvalid
+	}
+	if state == data.PresenceOnline {
+		status = ""
+	}
+	user.LastSeen = service.now()
+	user.AutoAway = false
+	if user.Presence != state || user.StatusText != status {
+		user.Presence, user.StatusText = state, status
+		service.notifyPresence(user)
+	}
+	return copyUser(user), nil
+}
+
+// GetPresence returns the presence of a user with the time of its last activity, or the time it left when it is offline
+func (service *ServiceImpl) GetPresence(userID int) (data.Presence, bool) {
+	service.RLock()
+	defer service.RUnlock()
+	user, ok := service.users[userID]
+	if !ok || userID == SystemUserID {
+		return data.Presence{}, false
+	}
+	return data.Presence{
+		UserID:   user.ID,
+		Name:     user.Name,
+		State:    user.Presence,
+		Status:   user.StatusText,
+		LastSeen: user.LastSeen.UTC().Format(time.RFC3339),
+	}, true
+}
+
+// Touch records an activity of the user, a user marked away for being idle is back online
+func (service *ServiceImpl) Touch(userID int) {
+	service.Lock()
+	defer service.Unlock()
+	if user, ok := service.users[userID]; ok && !user.Dead {
+		service.touch(user)
+	}
+}
+
+// SetAwayAfter sets the idle time after which the users are marked away, 0 never marks them
+func (service *ServiceImpl) SetAwayAfter(awayAfter time.Duration) {
+	service.Lock()
+	defer service.Unlock()
+	service.awayAfter = awayAfter
+}
+
+// touch records an activity of the user, the caller must hold the lock
+func (service *ServiceImpl) touch(user *data.User) {
+	user.LastSeen = service.now()
+	if user.AutoAway {
+		user.Presence, user.AutoAway = data.PresenceOnline, false
+		service.notifyPresence(user)
+	}
+}
+
+// watchIdle marks the idle users away, it runs as long as the server
+func (service *ServiceImpl) watchIdle() {
+	for range time.Tick(idleCheckInterval) {
+		service.markIdle()
+	}
+}
+
+// markIdle marks away the online users who have been idle for longer than the away time
+func (service *ServiceImpl) markIdle() {
+	service.Lock()
+	defer service.Unlock()
+	if service.awayAfter <= 0 {
+		return
+	}
+	now := service.now()
+	for id, user := range service.users {
+		if id == SystemUserID || user.Dead || user.Bot || user.Presence != data.PresenceOnline {
+			continue
+		}
+		if now.Sub(user.LastSeen) >= service.awayAfter {
+			user.Presence, user.AutoAway = data.PresenceAway, true
+			service.notifyPresence(user)
+		}
+	}
+}
+
+// notifyPresence tells the users sharing a room with the user about its presence, the caller must hold the lock
+func (service *ServiceImpl) notifyPresence(user *data.User) {
+	event := data.Event{Type: data.EventPresence, Text: user.StatusText, UserID: user.ID, UserName: user.Name, Presence: user.Presence}
+	for _, id := range service.peers(user.ID) {
+		service.notify(id, event)
+	}
+}
+
+// peers returns the ids of the users sharing a room with the user, the caller must hold the lock
+func (service *ServiceImpl) peers(userID int) []int {
+	peers := map[int]bool{}
+	for _, room := range service.rooms {
+		if _, member := room.Users[userID]; !member {
+			continue
+		}
+		for id := range room.Users {
+			if id != userID {
+				peers[id] = true
+			}
+		}
+	}
+	ids := make([]int, 0, len(peers))
+	for id := range peers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,158 @@
+package chatserver
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"chatServer/src/chatserver/data"
+)
+
+// Limits of the names and the profiles, in characters
+const (
+	maxNameLength        = 30
+	maxDisplayNameLength = 50
+	maxBioLength         = 300
+)
+
+// Rename changes the name of a connected user in every room it is subscribed to and tells the members of its rooms,
+// the names of the connected users are unique regardless of the case
+func (service *ServiceImpl) Rename(userID int, name string) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || user.Dead || user.Bot || userID == SystemUserID {
+		return data.User{}, ErrUserNotFound
+	}
+	if name == user.Name {
+		return copyUser(user), nil
+	}
+	if err := service.checkName(name, userID); err != nil {
+		return copyUser(user), err
+	}
+	oldName := user.Name
+	user.Name = name
+	for _, room := range service.rooms {
+		if _, member := room.Users[userID]; member {
+			room.Users[userID] = name
+		}
+	}
+	event := data.Event{Type: data.EventNick, Text: oldName, UserID: userID, UserName: name}
+	for _, id := range service.peers(userID) {
+		service.notify(id, event)
+	}
+	service.logger.Info("User renamed", "userId", userID, "from", oldName, "to", name)
+	return copyUser(user), nil
+}
+
+// RegisterUser creates a user for a client that logs in, the name must be valid and not used by another connected user
+func (service *ServiceImpl) RegisterUser(name string) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.checkName(name, -1); err != nil {
+		return data.User{}, err
+	}
+	return service.createUser(name, false), nil
+}
+
+// CheckName checks that a client can log in with the name, it is valid and not used by a connected user
+func (service *ServiceImpl) CheckName(name string) error {
+	service.RLock()
+	defer service.RUnlock()
+	return service.checkName(name, -1)
+}
+
+// checkName checks that the name is valid and not used by a connected user other than the user, the caller must hold the lock
+func (service *ServiceImpl) checkName(name string, userID int) error {
+	if !isNameValid(name) {
+		return ErrNameInvalid
+	}
+	for id, other := range service.users {
+		if id != userID && !other.Dead && strings.EqualFold(other.Name, name) {
+			return ErrNameInUse
+		}
+	}
+	return nil
+}
+
+// SetProfile changes the display name, the bio and the timezone of a user
+func (service *ServiceImpl) SetProfile(userID int, update data.ProfileUpdate) (data.Profile, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || userID == SystemUserID {
+		return data.Profile{}, ErrUserNotFound
+	}
+	displayName, bio, timezone := user.DisplayName, user.Bio, user.Timezone
+	if update.DisplayName != nil {
+		displayName = strings.TrimSpace(*update.DisplayName)
+	}
+	if update.Bio != nil {
+		bio = strings.TrimSpace(*update.Bio)
+	}
+	if update.Timezone != nil {
+		timezone = strings.TrimSpace(*update.Timezone)
+	}
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength || utf8.RuneCountInString(bio) > maxBioLength {
+		return service.profileOf(user), ErrProfileInvalid
+	}
+	if _, err := loadTimezone(timezone); err != nil {
+		return service.profileOf(user), err
+	}
+	user.DisplayName, user.Bio, user.Timezone = displayName, bio, timezone
+	return service.profileOf(user), nil
+}
+
+// GetProfile returns the profile of a user with its local time when it has a timezone
+func (service *ServiceImpl) GetProfile(userID int) (data.Profile, bool) {
+	service.RLock()
+	defer service.RUnlock()
+	user, ok := service.users[userID]
+	if !ok || userID == SystemUserID {
+		return data.Profile{}, false
+	}
+	return service.profileOf(user), true
+}
+
+// profileOf returns the profile of the user, the caller must hold the lock
+func (service *ServiceImpl) profileOf(user *data.User) data.Profile {
+	profile := data.Profile{
+		UserID:      user.ID,
+		Name:        user.Name,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		Timezone:    user.Timezone,
+	}
+	if location, err := loadTimezone(user.Timezone); err == nil && location != nil {
+		profile.LocalTime = service.now().In(location).Format(time.RFC3339)
+	}
+	return profile
+}
+
+// loadTimezone loads the location of an IANA timezone name, it returns nil for an empty name
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return nil, nil
+	}
+	if timezone == "Local" { // the timezone of the server, not a name
+		return nil, ErrTimezoneInvalid
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, ErrTimezoneInvalid
+	}
+	return location, nil
+}
+
+// isNameValid checks if the name can be chosen by a user, numbers are left to the ids
+func isNameValid(name string) bool {
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength || strings.IndexFunc(name, unicode.IsSpace) != -1 {
+		return false
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return false
+	}
+	return !strings.HasPrefix(name, "@") && !strings.HasPrefix(name, "#")
+}
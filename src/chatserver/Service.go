@@ -1,22 +1,70 @@
 package chatserver
 
-import "chatServer/src/chatserver/data"
+import (
+	"time"
+
+	"chatServer/src/chatserver/data"
+)
+
+// Observer is called for the events of the whole chat server, e.g. by webhooks
+type Observer func(event data.Event)
 
 // Service interface for the chatserver
 type Service interface {
 	Run()
 	CreateUser(username string) data.User
-	Publish(input data.Input, userID int, sysMessage bool) data.Message
-	Subscribe(userID int, roomID int)
-	UnSubscribe(userID int, roomID int)
-	SwitchRoom(userID int, roomID int)
-	GetActiveRoom(userID int)
-	ListRooms(userID int)
-	CreateRoom(roomName string, userID int, userName string)
+	CreateBotUser(username string) data.User
+	RegisterUser(username string) (data.User, error)
+	CheckName(username string) error
+	Publish(input data.Input, userID int, sysMessage bool) (data.Message, error)
+	Subscribe(userID int, roomID int, password string) (data.Room, error)
+	UnSubscribe(userID int, roomID int) (data.Room, error)
+	SwitchRoom(userID int, roomID int) (data.Room, error)
+	GetActiveRoom(userID int) (data.Room, error)
+	GetVisibleRooms(userID int) []data.Room
+	FindRoom(userID int, reference string) (data.Room, bool)
+	SuggestRooms(userID int, reference string) []string
+	SuggestUsers(name string) []string
+	CanViewRoom(userID int, roomID int) bool
+	CanReadRoom(userID int, roomID int) bool
+	SubscribeBot(userID int, roomID int) (data.Room, error)
+	CreateRoom(roomName string, userID int, visibility string, password string) (data.Room, error)
+	Invite(userID int, inviteeName string, roomID int) (data.User, error)
+	AcceptInvite(userID int, roomID int) (data.Room, error)
+	SetTopic(userID int, roomID int, topic string) (data.Room, error)
+	SetDescription(userID int, roomID int, description string) (data.Room, error)
+	SetMetadata(userID int, roomID int, key string, value string) (data.Room, error)
+	SetWelcome(userID int, roomID int, welcome string) (data.Room, error)
+	RenameRoom(userID int, roomID int, roomName string) (data.Room, error)
+	ArchiveRoom(userID int, roomID int, archived bool) (data.Room, error)
+	DeleteRoom(userID int, roomID int) (data.Room, error)
 	GetUser(userID int) (data.User, bool)
 	GetRoom(roomID int) (data.Room, bool)
 	GetMessages() []data.Message
 	GetUsers() []data.User
 	GetRooms() []data.Room
 	RemoveUser(userID int)
+	AddObserver(observer Observer)
+	Broadcast(text string) []data.Message
+	Close() error
+	SetLogFilePath(logFilePath string)
+	CheckStorage() error
+	FindUser(name string) (data.User, bool)
+	SetAdmins(admins map[string]string)
+	Oper(userID int, password string) (data.User, error)
+	SetAdmin(userID int, admin bool) (data.User, error)
+	Disconnect(userID int, reason string) error
+	SetMOTD(motd string)
+	GetMOTD() string
+	GetSummary(userID int) []data.RoomSummary
+	SetPresence(userID int, state string, status string) (data.User, error)
+	GetPresence(userID int) (data.Presence, bool)
+	Touch(userID int)
+	SetAwayAfter(awayAfter time.Duration)
+	SetTyping(userID int, roomID int, typing bool) error
+	IsTyping(userID int, roomID int) bool
+	ShowTyping(userID int, show bool) (data.User, error)
+	Rename(userID int, name string) (data.User, error)
+	SetProfile(userID int, update data.ProfileUpdate) (data.Profile, error)
+	GetProfile(userID int) (data.Profile, bool)
 }
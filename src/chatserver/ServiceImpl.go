@@ -1,30 +1,62 @@
 package chatserver
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"chatServer/src/chatserver/data"
+	"chatServer/src/logging"
+)
+
+// SystemUserID is the id of the System user and DefaultRoomID the id of the Default room
+const (
+	SystemUserID  = 0
+	DefaultRoomID = 0
 )
 
 // ServiceImpl struct for chat server service
 type ServiceImpl struct {
 	logFilePath string
-	users []data.User
-	rooms []data.Room
+	users map[int]*data.User
+	rooms map[int]*data.Room
 	messages []data.Message
+	nextUserID int
+	nextRoomID int
+	nextMessageID int
+	observers []Observer
+	events chan data.Event
+	pendingEvents sync.WaitGroup
+	logFile *os.File
+	admins map[string]string // lower case names of the users that can become admins with their passwords
+	motd string
+	readMarks map[string]map[int]int // id of the last message of each room when the user of the lower case name left
+	awayAfter time.Duration // idle time after which the users are marked away, 0 never marks them
+	typing map[int]map[int]*time.Timer // timers ending the typing of the users in each room
+	now func() time.Time
+	logger *logging.Logger
 	sync.RWMutex
 }
 
-// NewServiceImpl returns ServiceImpl
-func NewServiceImpl(logFilePath string) *ServiceImpl {
+// NewServiceImpl returns ServiceImpl, a nil logger discards the server log
+func NewServiceImpl(logFilePath string, logger *logging.Logger) *ServiceImpl {
 	return &ServiceImpl{
 		logFilePath: logFilePath,
+		logger: logger.With("component", "chatserver"),
+		users: make(map[int]*data.User),
+		rooms: make(map[int]*data.Room),
+		events: make(chan data.Event, 1000),
+		readMarks: make(map[string]map[int]int),
+		typing: make(map[int]map[int]*time.Timer),
+		now: time.Now,
 	}
 }
 
@@ -33,184 +65,619 @@ func NewServiceImpl(logFilePath string) *ServiceImpl {
 func (service *ServiceImpl) Run() {
 	service.createDefaultRoom()
 	service.CreateUser("System") // System user
+	go service.dispatchEvents()
+	go service.watchIdle()
+}
+
+// Broadcast publishes a System message to every room
+func (service *ServiceImpl) Broadcast(text string) []data.Message {
+	service.Lock()
+	defer service.Unlock()
+	messages := []data.Message{}
+	for _, room := range service.rooms {
+		if message, err := service.publish(data.Input{Room: room.ID, Text: text}, SystemUserID, true); err == nil {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Close waits until the observers have seen the events and closes the message log, it is called when the server stops
+func (service *ServiceImpl) Close() error {
+	service.pendingEvents.Wait()
+	service.Lock()
+	defer service.Unlock()
+	if service.logFile == nil {
+		return nil
+	}
+	err := service.logFile.Sync()
+	if closeErr := service.logFile.Close(); err == nil {
+		err = closeErr
+	}
+	service.logFile = nil
+	return err
+}
+
+// CheckStorage checks that the message log can be written
+func (service *ServiceImpl) CheckStorage() error {
+	service.RLock()
+	logFilePath := service.logFilePath
+	service.RUnlock()
+	file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// SetLogFilePath switches the message log to another file, the next message opens it
+func (service *ServiceImpl) SetLogFilePath(logFilePath string) {
+	service.Lock()
+	defer service.Unlock()
+	if logFilePath == service.logFilePath {
+		return
+	}
+	if service.logFile != nil {
+		service.logFile.Close()
+		service.logFile = nil
+	}
+	service.logFilePath = logFilePath
+}
+
+// AddObserver adds an observer that is called for the messages, joins, leaves and created rooms of the chat server
+func (service *ServiceImpl) AddObserver(observer Observer) {
+	service.Lock()
+	defer service.Unlock()
+	service.observers = append(service.observers, observer)
 }
 
 // CreateUser creates a new user
 func (service *ServiceImpl) CreateUser(name string) data.User {
 	service.Lock()
 	defer service.Unlock()
-	id := len(service.users)
-	newUser := data.User{
+	return service.createUser(name, false)
+}
+
+// CreateBotUser creates a user without a connection, it posts messages but does not receive any event
+func (service *ServiceImpl) CreateBotUser(name string) data.User {
+	service.Lock()
+	defer service.Unlock()
+	return service.createUser(name, true)
+}
+
+// createUser creates a new user, the caller must hold the lock
+func (service *ServiceImpl) createUser(name string, bot bool) data.User {
+	id := service.nextUserID
+	service.nextUserID++
+	newUser := &data.User{
 		ID: id,
 		Name: name,
-		Output: make(chan string, 100),
+		Output: make(chan data.Event, 100),
+		Bot: bot,
+		Presence: data.PresenceOnline,
+		LastSeen: service.now(),
 	}
-	newUser.ActiveRoom = service.rooms[0].ID // make the active room as Default room when user is created
-	if (service.rooms[0].Users == nil) {
-		service.rooms[0].Users = make(map[int]string)
+	newUser.ActiveRoom = DefaultRoomID // make the active room as Default room when user is created
+	service.rooms[DefaultRoomID].Users[id] = name // add the created user to the Default room
+	service.users[id] = newUser
+	if !bot && id != SystemUserID {
+		usersOnline.Inc()
+		service.notifyPresence(newUser)
 	}
-	service.rooms[0].Users[id] = name // add the created user to the Default room
-	service.users = append(service.users, newUser)
-	return newUser
+	return copyUser(newUser)
 }
 
 
 // createDefaultRoom creates a new default room in chat chatserver
 func (service *ServiceImpl) createDefaultRoom() {
-	id := len(service.rooms)
-	defaultRoom := data.Room{
+	id := service.nextRoomID
+	service.nextRoomID++
+	defaultRoom := &data.Room{
 		ID: id,
 		Name: "Default",
+		Visibility: data.VisibilityPublic,
+		CreatedAt: service.getTimeStamp(),
 		Users: make(map[int]string),
+		Invited: make(map[int]bool),
+		Metadata: make(map[string]string),
 	}
-	service.rooms = append(service.rooms, defaultRoom)
-	log.Println("Default room created!!")
+	service.rooms[id] = defaultRoom
+	roomsGauge.Set(float64(len(service.rooms)))
+	service.logger.Info("Default room created", "roomId", id)
 }
 
 
 // Publish broadcasts the message to the users in the room
-func (service *ServiceImpl) Publish(input data.Input, userID int, sysMessage bool) data.Message {
+func (service *ServiceImpl) Publish(input data.Input, userID int, sysMessage bool) (data.Message, error) {
 	service.Lock()
 	defer service.Unlock()
+	return service.publish(input, userID, sysMessage)
+}
+
+
+// publish broadcasts the message to the users in the room, the caller must hold the lock
+func (service *ServiceImpl) publish(input data.Input, userID int, sysMessage bool) (data.Message, error) {
+	start := time.Now()
+	defer func() { publishDuration.Observe(time.Since(start).Seconds()) }()
 	roomID := input.Room
-	userList := service.rooms[roomID].Users
+	room, roomOk := service.rooms[roomID]
+	sender, userOk := service.users[userID]
+	if !userOk {
+		return data.Message{}, ErrUserNotFound
+	}
+	if !roomOk {
+		return data.Message{}, ErrRoomNotFound
+	}
+	if room.Archived && !sysMessage { // archived rooms are read only
+		return data.Message{}, ErrRoomArchived
+	}
+	if _, member := room.Users[userID]; !member && !sysMessage { // only the members post to a room
+		return data.Message{}, ErrNotSubscribed
+	}
+	userList := room.Users
+	senderName := sender.Name
+	if input.UserName != "" { // display name of bots posting through webhooks
+		senderName = input.UserName
+	}
 
 	timeStamp := service.getTimeStamp()
 	formattedMessage := service.formatMessage(input,
 		userID,
-		service.users[userID].Name,
-		service.rooms[input.Room].Name,sysMessage,
+		senderName,
+		room.Name,sysMessage,
 		timeStamp)
 
+	var uID int
+	var uName string
+	if sysMessage {
+		uID = service.users[SystemUserID].ID
+		uName = service.users[SystemUserID].Name
+		messagesPublished.Inc("system")
+	} else {
+		uID = sender.ID
+		uName = senderName
+		messagesPublished.Inc("user")
+		service.touch(sender)
+		service.stopTyping(userID, roomID)
+	}
+	savedMessage := service.saveMessage(uID, roomID, uName, room.Name, input.Text, timeStamp)
+	service.emit(data.Event{
+		Type: data.EventMessage,
+		Text: input.Text,
+		Message: savedMessage,
+		RoomID: roomID,
+		RoomName: room.Name,
+		UserID: uID,
+		UserName: uName,
+	})
+
 	// publish the message
+	event := data.Event{
+		Type: data.EventMessage,
+		Text: formattedMessage,
+		Message: savedMessage,
+	}
 	for id := range userList {
-		userStruct := service.users[id]
-		if id != userID && id != 0  && userStruct.Dead == false { // dont write message from self, to the system user and to dead user
+		userStruct, ok := service.users[id]
+		if ok && id != userID && id != SystemUserID  && userStruct.Dead == false && !userStruct.Bot { // dont write message from self, to the system user, to dead users and to bots
 			select {
-				case userStruct.Output <- formattedMessage:
+				case userStruct.Output <- event:
 				case <-time.After(1 * time.Second):
-					log.Printf("timeout sending to user %d", id)
+					droppedSends.Inc(event.Type)
+					service.logger.Warn("Timeout sending an event to a user", "event", event.Type, "userId", id, "roomId", savedMessage.RoomID)
 			}
 		}
 	}
 	service.logMessageToFile(formattedMessage)
-	var uID int
-	var uName string
-	if sysMessage {
-		uID = service.users[0].ID
-		uName = service.users[0].Name
-	} else {
-		uID = service.users[userID].ID
-		uName = service.users[userID].Name
-	}
-	savedMessage := service.saveMessage(uID, roomID, uName, service.rooms[roomID].Name, input.Text, timeStamp)
-	return savedMessage
+	return savedMessage, nil
 }
 
 
 // Subscribe lets the user subscribe to a particular room
-func (service *ServiceImpl) Subscribe(userID int, roomID int) {
+func (service *ServiceImpl) Subscribe(userID int, roomID int, password string) (data.Room, error) {
 	service.Lock()
 	defer service.Unlock()
 	// check if room is valid or not
-	if roomID < len(service.rooms) {
-		if service.rooms[roomID].Users[userID] == service.users[userID].Name { // check if already subscribed
-			service.sendInfo("Already subscribed to room " + service.rooms[roomID].Name + "!!\n", userID)
-		} else {
-			service.rooms[roomID].Users[userID] = service.users[userID].Name
-			service.sendInfo("Subscribed to " + service.rooms[roomID].Name + "!!\n", userID)
-		}
+	if !service.userExists(userID) {
+		return data.Room{}, ErrUserNotFound
+	}
+	if !service.roomExists(roomID) || !service.canView(service.rooms[roomID], userID) {
+		return data.Room{}, ErrRoomNotFound
+	}
+	room := service.rooms[roomID]
+	if service.isMember(userID, roomID) { // check if already subscribed
+		return copyRoom(room), ErrAlreadySubscribed
+	} else if room.Archived {
+		return copyRoom(room), ErrRoomArchived
+	} else if room.Invited[userID] {
+		service.addToRoom(userID, roomID)
+	} else if room.Visibility == data.VisibilityInviteOnly || room.Visibility == data.VisibilityPrivate {
+		return copyRoom(room), ErrInviteOnly
+	} else if room.Visibility == data.VisibilityPassword && !checkPassword(room.PasswordHash, password) {
+		return copyRoom(room), ErrIncorrectPassword
 	} else {
-		service.sendInfo("Room " + strconv.Itoa(roomID) + " not found!!\n", userID)
+		service.addToRoom(userID, roomID)
+	}
+	return copyRoom(room), nil
+}
+
+
+// Invite invites a user to a particular room
+func (service *ServiceImpl) Invite(userID int, inviteeName string, roomID int) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	// check if room is valid or not
+	if !service.roomExists(roomID) || !service.canView(service.rooms[roomID], userID) {
+		return data.User{}, ErrRoomNotFound
+	}
+	room := service.rooms[roomID]
+	if !service.isMember(userID, roomID) {
+		return data.User{}, ErrNotSubscribed
+	}
+	if room.Archived {
+		return data.User{}, ErrRoomArchived
+	}
+	inviteeID, found := service.findUserByName(strings.TrimPrefix(inviteeName, "@"))
+	if !found {
+		return data.User{}, ErrUserNotFound
 	}
+	invitee := service.users[inviteeID]
+	if service.isMember(inviteeID, roomID) {
+		return copyUser(invitee), ErrAlreadySubscribed
+	}
+	if room.Invited[inviteeID] {
+		return copyUser(invitee), ErrAlreadyInvited
+	}
+	room.Invited[inviteeID] = true
+	invitee.Invitations = append(invitee.Invitations, roomID)
+	service.notify(inviteeID, data.Event{
+		Type: data.EventInvitation,
+		RoomID: roomID,
+		RoomName: room.Name,
+		UserName: service.users[userID].Name,
+	})
+	return copyUser(invitee), nil
+}
+
+
+// AcceptInvite lets the user accept an invitation to a particular room
+func (service *ServiceImpl) AcceptInvite(userID int, roomID int) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if !service.userExists(userID) {
+		return data.Room{}, ErrUserNotFound
+	}
+	if !service.roomExists(roomID) || !service.rooms[roomID].Invited[userID] {
+		return data.Room{}, ErrNoInvitation
+	}
+	service.addToRoom(userID, roomID)
+	return copyRoom(service.rooms[roomID]), nil
 }
 
 
 // UnSubscribe lets the user unsubscribe to a particular room
-func (service *ServiceImpl) UnSubscribe(userID int, roomID int) {
+func (service *ServiceImpl) UnSubscribe(userID int, roomID int) (data.Room, error) {
 	service.Lock()
 	defer service.Unlock()
 	// check if room is valid or not
-	if roomID < len(service.rooms) {
-		if service.rooms[roomID].Users[userID] == "" {
-			service.sendInfo("User is not subscribed to " + service.rooms[roomID].Name + "!!\n", userID)
-		} else {
-			userList := service.rooms[roomID].Users
-			delete(userList, userID)
-			service.rooms[roomID].Users = userList
-			if roomID == service.users[userID].ActiveRoom { // change the active room to Default if the user unsubscribes an active room
-				service.users[userID].ActiveRoom = 0
-			}
-			service.sendInfo("Unsubscribed " + service.rooms[roomID].Name + "!!\n", userID)
-		}
-	} else {
-		service.sendInfo("Room " + strconv.Itoa(roomID) + " not found!!\n", userID)
+	if !service.userExists(userID) {
+		return data.Room{}, ErrUserNotFound
+	}
+	if !service.roomExists(roomID) {
+		return data.Room{}, ErrRoomNotFound
+	}
+	if !service.isMember(userID, roomID) {
+		return copyRoom(service.rooms[roomID]), ErrNotSubscribed
 	}
+	service.stopTyping(userID, roomID)
+	delete(service.rooms[roomID].Users, userID)
+	service.emitRoomEvent(data.EventLeave, userID, roomID)
+	if roomID == service.users[userID].ActiveRoom { // change the active room to Default if the user unsubscribes an active room
+		service.users[userID].ActiveRoom = DefaultRoomID
+	}
+	return copyRoom(service.rooms[roomID]), nil
 }
 
 
 // SwitchRoom lets the user switch to a particular room
-func (service *ServiceImpl) SwitchRoom(userID int, roomID int) {
+func (service *ServiceImpl) SwitchRoom(userID int, roomID int) (data.Room, error) {
 	service.Lock()
 	defer service.Unlock()
-	if roomID < len(service.rooms) {
-		if service.users[userID].ActiveRoom == roomID {
-			service.sendInfo("Already in room " + service.rooms[roomID].Name + "!!\n", userID)
-		} else if service.rooms[roomID].Users[userID] != "" { // check if the user is subscribed to the room or not
-			service.users[userID].ActiveRoom = roomID
-			service.sendInfo("Switched to " + service.rooms[roomID].Name + "!!\n", userID)
-		} else {
-			service.sendInfo("Subscribe to " + service.rooms[roomID].Name + " before switching!!\n", userID)
-		}
-	} else {
-		service.sendInfo("Room " + strconv.Itoa(roomID) + " not found!!\n", userID)
+	if !service.userExists(userID) {
+		return data.Room{}, ErrUserNotFound
 	}
+	if !service.roomExists(roomID) {
+		return data.Room{}, ErrRoomNotFound
+	}
+	room := service.rooms[roomID]
+	if service.users[userID].ActiveRoom == roomID {
+		return copyRoom(room), ErrAlreadyInRoom
+	} else if room.Archived {
+		return copyRoom(room), ErrRoomArchived
+	} else if !service.isMember(userID, roomID) { // check if the user is subscribed to the room or not
+		return copyRoom(room), ErrNotSubscribed
+	}
+	service.users[userID].ActiveRoom = roomID
+	return copyRoom(room), nil
 }
 
 // GetActiveRoom gets the active room of the user
-func (service *ServiceImpl) GetActiveRoom(userID int) {
+func (service *ServiceImpl) GetActiveRoom(userID int) (data.Room, error) {
+	service.RLock()
+	defer service.RUnlock()
+	if !service.userExists(userID) {
+		return data.Room{}, ErrUserNotFound
+	}
+	return copyRoom(service.rooms[service.users[userID].ActiveRoom]), nil
+}
+
+
+// FindRoom finds a room visible to the user by id, name or #name, names are matched ignoring case
+func (service *ServiceImpl) FindRoom(userID int, reference string) (data.Room, bool) {
+	service.RLock()
+	defer service.RUnlock()
+	name := strings.TrimPrefix(reference, "#")
+	if name == reference { // references without # can be room ids
+		if roomID, err := strconv.Atoi(reference); err == nil {
+			if service.roomExists(roomID) && service.canView(service.rooms[roomID], userID) {
+				return copyRoom(service.rooms[roomID]), true
+			}
+			return data.Room{}, false
+		}
+	}
+	for _, room := range service.rooms {
+		if strings.EqualFold(room.Name, name) && service.canView(room, userID) {
+			return copyRoom(room), true
+		}
+	}
+	return data.Room{}, false
+}
+
+
+// SuggestRooms suggests names of rooms visible to the user that are close to the reference
+func (service *ServiceImpl) SuggestRooms(userID int, reference string) []string {
+	service.RLock()
+	defer service.RUnlock()
+	names := []string{}
+	for _, room := range service.visibleRooms(userID) {
+		names = append(names, room.Name)
+	}
+	return suggest(strings.TrimPrefix(reference, "#"), names)
+}
+
+
+// SuggestUsers suggests names of connected users that are close to the name
+func (service *ServiceImpl) SuggestUsers(name string) []string {
+	service.RLock()
+	defer service.RUnlock()
+	return suggest(strings.TrimPrefix(name, "@"), service.connectedUserNames())
+}
+
+
+// CanViewRoom checks if the room exists and is visible to the user
+func (service *ServiceImpl) CanViewRoom(userID int, roomID int) bool {
 	service.RLock()
 	defer service.RUnlock()
-	activeRoomID := service.users[userID].ActiveRoom
-	info := "Active room is " + service.rooms[activeRoomID].Name + " - " + strconv.Itoa(activeRoomID) + "!!\n"
-	service.sendInfo(info, userID)
+	return service.roomExists(roomID) && service.canView(service.rooms[roomID], userID)
 }
 
 
-// ListRooms lists all the rooms in the chat chatserver
-func (service *ServiceImpl) ListRooms(userID int) {
+// CanReadRoom checks if the user can read the messages of the room, the messages of public rooms can be read by everyone
+// and the messages of the other rooms only by their members
+func (service *ServiceImpl) CanReadRoom(userID int, roomID int) bool {
 	service.RLock()
 	defer service.RUnlock()
-	var info string
-	info = "List of rooms: \n"
-	for _,room := range service.rooms {
-		info = info + strconv.Itoa(room.ID) + "-" + room.Name + "\n"
+	if !service.roomExists(roomID) {
+		return false
+	}
+	room := service.rooms[roomID]
+	_, member := room.Users[userID]
+	return member || (room.Visibility == data.VisibilityPublic && service.canView(room, userID))
+}
+
+
+// SubscribeBot subscribes a bot user to a room whatever its visibility, e.g. the bot user of an incoming webhook
+// created by a member of the room
+func (service *ServiceImpl) SubscribeBot(userID int, roomID int) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || !user.Bot {
+		return data.Room{}, ErrUserNotFound
+	}
+	if !service.roomExists(roomID) {
+		return data.Room{}, ErrRoomNotFound
 	}
-	service.sendInfo(info, userID)
+	if !service.isMember(userID, roomID) {
+		service.addToRoom(userID, roomID)
+	}
+	return copyRoom(service.rooms[roomID]), nil
+}
+
+
+// GetVisibleRooms returns the rooms that are visible to the user
+func (service *ServiceImpl) GetVisibleRooms(userID int) []data.Room {
+	service.RLock()
+	defer service.RUnlock()
+	return service.visibleRooms(userID)
 }
 
 
 // CreateRoom creates a new room in the chat server
-func (service *ServiceImpl) CreateRoom(roomName string, userID int, userName string) {
+func (service *ServiceImpl) CreateRoom(roomName string, userID int, visibility string, password string) (data.Room, error) {
 	service.Lock()
 	defer service.Unlock()
 	// check if the room already exists
-	for i := 0; i < len(service.rooms); i++ {
-		if service.rooms[i].Name == roomName {
-			service.sendInfo("Room with similar name already exists!!\n", userID)
-			return
-		}
+	if !service.userExists(userID) {
+		return data.Room{}, ErrUserNotFound
+	}
+	if err := service.validateRoomName(roomName); err != nil {
+		return data.Room{}, err
+	}
+	if visibility == "" {
+		visibility = data.VisibilityPublic
 	}
-	room := data.Room{
-		ID: len(service.rooms),
+	if !isVisibilityValid(visibility) {
+		return data.Room{}, ErrInvalidVisibility
+	}
+	if visibility == data.VisibilityPassword && password == "" {
+		return data.Room{}, ErrPasswordMissing
+	}
+	room := &data.Room{
+		ID: service.nextRoomID,
 		Name: roomName,
+		Visibility: visibility,
+		CreatorID: userID,
+		CreatedAt: service.getTimeStamp(),
+		Invited: make(map[int]bool),
+		Metadata: make(map[string]string),
+	}
+	if visibility == data.VisibilityPassword {
+		room.PasswordHash = hashPassword(password)
 	}
 	if room.Users == nil {
 		room.Users = make(map[int]string)
 	}
-	room.Users[userID] = userName
-	service.rooms = append(service.rooms, room)
-	service.sendInfo("Room " + roomName + " created!!\n", userID)
+	room.Users[userID] = service.users[userID].Name
+	service.rooms[room.ID] = room
+	roomsGauge.Set(float64(len(service.rooms)))
+	service.nextRoomID++
+	service.emitRoomEvent(data.EventRoomCreated, userID, room.ID)
+	return copyRoom(room), nil
+}
+
+
+// SetTopic sets the topic of a room and announces it to the members
+func (service *ServiceImpl) SetTopic(userID int, roomID int, topic string) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canEdit(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	service.rooms[roomID].Topic = topic
+	service.publish(data.Input{
+		Room: roomID,
+		Text: service.users[userID].Name + " changed the topic to: " + topic,
+	}, 0, true)
+	return copyRoom(service.rooms[roomID]), nil
+}
+
+
+// SetWelcome sets the message shown to the users who subscribe to a room, an empty message removes it
+func (service *ServiceImpl) SetWelcome(userID int, roomID int, welcome string) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canEdit(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	service.rooms[roomID].Welcome = welcome
+	return copyRoom(service.rooms[roomID]), nil
+}
+
+
+// SetDescription sets the description of a room
+func (service *ServiceImpl) SetDescription(userID int, roomID int, description string) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canEdit(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	service.rooms[roomID].Description = description
+	return copyRoom(service.rooms[roomID]), nil
+}
+
+
+// SetMetadata sets a metadata key of a room, an empty value removes the key
+func (service *ServiceImpl) SetMetadata(userID int, roomID int, key string, value string) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canEdit(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	if value == "" {
+		delete(service.rooms[roomID].Metadata, key)
+	} else {
+		service.rooms[roomID].Metadata[key] = value
+	}
+	return copyRoom(service.rooms[roomID]), nil
+}
+
+
+// RenameRoom renames a room, only the creator of the room can rename it
+func (service *ServiceImpl) RenameRoom(userID int, roomID int, roomName string) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canManage(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	oldName := service.rooms[roomID].Name
+	if oldName == roomName {
+		return copyRoom(service.rooms[roomID]), nil
+	}
+	if err := service.validateRoomName(roomName); err != nil && !strings.EqualFold(oldName, roomName) {
+		return data.Room{}, err
+	}
+	service.rooms[roomID].Name = roomName
+	service.publish(data.Input{
+		Room: roomID,
+		Text: "Room " + oldName + " renamed to " + roomName,
+	}, 0, true)
+	return copyRoom(service.rooms[roomID]), nil
+}
+
+
+// ArchiveRoom archives or restores a room, archived rooms are read only and hidden from listings
+func (service *ServiceImpl) ArchiveRoom(userID int, roomID int, archived bool) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canManage(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	room := service.rooms[roomID]
+	if room.Archived && archived {
+		return copyRoom(room), ErrRoomArchived
+	}
+	if !room.Archived && !archived {
+		return copyRoom(room), ErrRoomNotArchived
+	}
+	if archived {
+		service.publish(data.Input{Room: roomID, Text: "Room " + room.Name + " has been archived"}, 0, true)
+		service.rooms[roomID].Archived = true
+		service.moveActiveUsersToDefault(roomID)
+	} else {
+		service.rooms[roomID].Archived = false
+		service.publish(data.Input{Room: roomID, Text: "Room " + room.Name + " has been restored"}, 0, true)
+	}
+	return copyRoom(room), nil
+}
+
+
+// DeleteRoom deletes a room and moves its active members back to the Default room
+func (service *ServiceImpl) DeleteRoom(userID int, roomID int) (data.Room, error) {
+	service.Lock()
+	defer service.Unlock()
+	if err := service.canManage(userID, roomID); err != nil {
+		return data.Room{}, err
+	}
+	room := service.rooms[roomID]
+	for memberID := range room.Users {
+		service.notify(memberID, data.Event{
+			Type: data.EventRoomDeleted,
+			RoomID: roomID,
+			RoomName: room.Name,
+		})
+	}
+	service.moveActiveUsersToDefault(roomID)
+	for invitedID := range room.Invited {
+		service.removeInvitation(invitedID, roomID)
+	}
+	for _, timer := range service.typing[roomID] {
+		timer.Stop()
+	}
+	delete(service.typing, roomID)
+	delete(service.rooms, roomID)
+	roomsGauge.Set(float64(len(service.rooms)))
+	return copyRoom(room), nil
 }
 
 
@@ -219,8 +686,8 @@ func (service *ServiceImpl) GetUser(userID int) (data.User, bool) {
 	service.RLock()
 	defer service.RUnlock()
 	// check if userID is valid or not
-	if userID < len(service.users) {
-		return service.users[userID], true
+	if user, ok := service.users[userID]; ok {
+		return copyUser(user), true
 	}
 	return data.User{}, false
 }
@@ -231,8 +698,8 @@ func (service *ServiceImpl) GetRoom(roomID int) (data.Room, bool) {
 	service.RLock()
 	defer service.RUnlock()
 	// check if roomID is valid or not
-	if roomID < len(service.rooms) {
-		return service.rooms[roomID], true
+	if service.roomExists(roomID) {
+		return copyRoom(service.rooms[roomID]), true
 	}
 	return data.Room{}, false
 }
@@ -241,30 +708,422 @@ func (service *ServiceImpl) GetRoom(roomID int) (data.Room, bool) {
 func (service *ServiceImpl) GetMessages() []data.Message {
 	service.RLock()
 	defer service.RUnlock()
-	return service.messages
+	return append([]data.Message{}, service.messages...)
 }
 
-// GetUsers returns all the users
+// GetUsers returns all the users ordered by id
 func (service *ServiceImpl) GetUsers() []data.User {
 	service.RLock()
 	defer service.RUnlock()
-	return service.users
+	users := []data.User{}
+	for _, user := range service.users {
+		users = append(users, copyUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users
 }
 
-// GetRooms returns all the rooms
+// GetRooms returns all the rooms ordered by id, including archived ones
 func (service *ServiceImpl) GetRooms() []data.Room {
 	service.RLock()
 	defer service.RUnlock()
-	return service.rooms
+	rooms := []data.Room{}
+	for _, room := range service.rooms {
+		rooms = append(rooms, copyRoom(room))
+	}
+	sortRooms(rooms)
+	return rooms
 }
 
 // RemoveUser marks the user as dead
 func (service *ServiceImpl) RemoveUser(userID int) {
+	service.Lock()
+	defer service.Unlock()
+	if user, ok := service.users[userID]; ok {
+		if !user.Dead && !user.Bot && userID != SystemUserID {
+			usersOnline.Dec()
+			service.markRead(user)
+			service.stopTypingEverywhere(userID)
+			user.Presence, user.StatusText, user.AutoAway, user.LastSeen = data.PresenceOffline, "", false, service.now()
+			service.notifyPresence(user)
+		}
+		user.Dead = true
+	}
+}
+
+
+// markRead remembers the last message of the rooms of a user who leaves, the caller must hold the lock
+func (service *ServiceImpl) markRead(user *data.User) {
+	marks := map[int]int{}
+	for roomID, room := range service.rooms {
+		if _, member := room.Users[user.ID]; member {
+			marks[roomID] = -1
+		}
+	}
+	for _, message := range service.messages {
+		if _, member := marks[message.RoomID]; member {
+			marks[message.RoomID] = message.ID
+		}
+	}
+	service.readMarks[strings.ToLower(user.Name)] = marks
+}
+
+
+// SetMOTD sets the message of the day shown to the users when they log in
+func (service *ServiceImpl) SetMOTD(motd string) {
+	service.Lock()
+	defer service.Unlock()
+	service.motd = motd
+}
+
+
+// GetMOTD returns the message of the day, it is empty when there is none
+func (service *ServiceImpl) GetMOTD() string {
+	service.RLock()
+	defer service.RUnlock()
+	return service.motd
+}
+
+
+// GetSummary returns the rooms of the user with the messages posted since a user of the same name left
+// and the other members who are connected
+func (service *ServiceImpl) GetSummary(userID int) []data.RoomSummary {
 	service.RLock()
 	defer service.RUnlock()
-	service.users[userID].Dead = true;
+	summaries := []data.RoomSummary{}
+	user, ok := service.users[userID]
+	if !ok {
+		return summaries
+	}
+	marks, returning := service.readMarks[strings.ToLower(user.Name)]
+	unread := map[int]int{}
+	if returning {
+		for _, message := range service.messages {
+			if mark, found := marks[message.RoomID]; found && message.ID > mark {
+				unread[message.RoomID]++
+			}
+		}
+	}
+	for roomID, room := range service.rooms {
+		if _, member := room.Users[userID]; !member {
+			continue
+		}
+		summary := data.RoomSummary{ID: roomID, Name: room.Name, Unread: unread[roomID], Online: []string{}}
+		for memberID, name := range room.Users {
+			member, found := service.users[memberID]
+			if found && memberID != userID && memberID != SystemUserID && !member.Dead {
+				summary.Online = append(summary.Online, name)
+			}
+		}
+		sort.Strings(summary.Online)
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].ID < summaries[j].ID })
+	return summaries
+}
+
+// FindUser finds a user by name, ignoring the case and a leading @, connected users are preferred to the ones who left
+func (service *ServiceImpl) FindUser(name string) (data.User, bool) {
+	service.RLock()
+	defer service.RUnlock()
+	found := service.findUser(strings.TrimPrefix(name, "@"))
+	if found == nil {
+		return data.User{}, false
+	}
+	return copyUser(found), true
+}
+
+
+// findUser finds a user by name regardless of the case, the connected user or else the last user who left with the name,
+// the caller must hold the lock
+func (service *ServiceImpl) findUser(name string) *data.User {
+	var found *data.User
+	for _, user := range service.users {
+		if !strings.EqualFold(user.Name, name) {
+			continue
+		}
+		if found == nil || (found.Dead && !user.Dead) || (found.Dead == user.Dead && user.ID > found.ID) {
+			found = user
+		}
+	}
+	return found
+}
+
+
+// SetAdmins replaces the names and passwords of the users that can become admins with Oper, the current admins stay admins
+func (service *ServiceImpl) SetAdmins(admins map[string]string) {
+	service.Lock()
+	defer service.Unlock()
+	service.admins = make(map[string]string, len(admins))
+	for name, password := range admins {
+		service.admins[strings.ToLower(name)] = password
+	}
+}
+
+
+// Oper makes the user an admin when the name of the user is configured as admin with the password
+func (service *ServiceImpl) Oper(userID int, password string) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || user.Dead {
+		return data.User{}, ErrUserNotFound
+	}
+	expected, configured := service.admins[strings.ToLower(user.Name)]
+	// the password is compared even when the name is not configured so the time does not tell the admin names
+	matches := subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+	if !configured || !matches || password == "" {
+		return data.User{}, ErrOperFailed
+	}
+	user.Admin = true
+	return copyUser(user), nil
+}
+
+
+// SetAdmin grants or revokes the admin role of a user
+func (service *ServiceImpl) SetAdmin(userID int, admin bool) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || userID == SystemUserID {
+		return data.User{}, ErrUserNotFound
+	}
+	user.Admin = admin
+	return copyUser(user), nil
+}
+
+
+// Disconnect tells the listener of a connected user to close the connection, the reason is shown to the user
+func (service *ServiceImpl) Disconnect(userID int, reason string) error {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || userID == SystemUserID {
+		return ErrUserNotFound
+	}
+	if user.Dead || user.Bot {
+		return ErrUserNotConnected
+	}
+	service.notify(userID, data.Event{Type: data.EventKilled, Text: reason, UserID: userID, UserName: user.Name})
+	return nil
+}
+
+
+// visibleRooms returns the rooms that are visible to the user
+func (service *ServiceImpl) visibleRooms(userID int) []data.Room {
+	rooms := []data.Room{}
+	for _, room := range service.rooms {
+		if !room.Archived && service.canView(room, userID) {
+			rooms = append(rooms, copyRoom(room))
+		}
+	}
+	sortRooms(rooms)
+	return rooms
+}
+
+
+// canView checks if the room is visible to the user, private rooms are only visible to members and invitees
+func (service *ServiceImpl) canView(room *data.Room, userID int) bool {
+	if room.Visibility != data.VisibilityPrivate {
+		return true
+	}
+	_, member := room.Users[userID]
+	return member || room.Invited[userID]
+}
+
+
+// roomExists checks if the room id refers to an existing room
+func (service *ServiceImpl) roomExists(roomID int) bool {
+	_, ok := service.rooms[roomID]
+	return ok
+}
+
+
+// userExists checks if the user id refers to an existing user
+func (service *ServiceImpl) userExists(userID int) bool {
+	_, ok := service.users[userID]
+	return ok
+}
+
+
+// validateRoomName checks if the room name can be used
+func (service *ServiceImpl) validateRoomName(roomName string) error {
+	if _, err := strconv.Atoi(roomName); err == nil || roomName == "" {
+		return ErrRoomNameInvalid
+	}
+	if service.roomNameExists(roomName) {
+		return ErrRoomExists
+	}
+	return nil
+}
+
+
+// roomNameExists checks if a room with the name already exists
+func (service *ServiceImpl) roomNameExists(roomName string) bool {
+	for _, room := range service.rooms {
+		if strings.EqualFold(room.Name, roomName) {
+			return true
+		}
+	}
+	return false
+}
+
+
+// isMember checks if the user is subscribed to the room
+func (service *ServiceImpl) isMember(userID int, roomID int) bool {
+	if !service.roomExists(roomID) {
+		return false
+	}
+	_, member := service.rooms[roomID].Users[userID]
+	return member
+}
+
+
+// addToRoom subscribes the user to the room and clears any pending invitation
+func (service *ServiceImpl) addToRoom(userID int, roomID int) {
+	service.rooms[roomID].Users[userID] = service.users[userID].Name
+	delete(service.rooms[roomID].Invited, userID)
+	service.removeInvitation(userID, roomID)
+	service.emitRoomEvent(data.EventJoin, userID, roomID)
+}
+
+
+// removeInvitation removes the room from the pending invitations of the user
+func (service *ServiceImpl) removeInvitation(userID int, roomID int) {
+	if !service.userExists(userID) {
+		return
+	}
+	invitations := []int{}
+	for _, invitedRoomID := range service.users[userID].Invitations {
+		if invitedRoomID != roomID {
+			invitations = append(invitations, invitedRoomID)
+		}
+	}
+	service.users[userID].Invitations = invitations
+}
+
+
+// canEdit checks if the user can change the details of the room
+func (service *ServiceImpl) canEdit(userID int, roomID int) error {
+	if !service.isMember(userID, roomID) {
+		return ErrRoomNotFound
+	}
+	if service.rooms[roomID].Archived {
+		return ErrRoomArchived
+	}
+	return nil
+}
+
+
+// canManage checks if the user can rename, archive or delete the room
+func (service *ServiceImpl) canManage(userID int, roomID int) error {
+	if !service.roomExists(roomID) || !service.canView(service.rooms[roomID], userID) {
+		return ErrRoomNotFound
+	}
+	if roomID == DefaultRoomID {
+		return ErrDefaultRoom
+	}
+	if service.rooms[roomID].CreatorID != userID {
+		return ErrNotCreator
+	}
+	return nil
+}
+
+
+// moveActiveUsersToDefault switches the users whose active room is the given room to the Default room
+func (service *ServiceImpl) moveActiveUsersToDefault(roomID int) {
+	for id, user := range service.users {
+		if user.ActiveRoom == roomID {
+			user.ActiveRoom = DefaultRoomID
+			service.notify(id, data.Event{
+				Type: data.EventRoomSwitched,
+				RoomID: DefaultRoomID,
+				RoomName: service.rooms[DefaultRoomID].Name,
+			})
+		}
+	}
+}
+
+
+// connectedUserNames returns the names of the connected users in alphabetical order
+func (service *ServiceImpl) connectedUserNames() []string {
+	names := []string{}
+	for _, user := range service.users {
+		if user.ID != SystemUserID && !user.Dead {
+			names = append(names, user.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+
+// findUserByName finds a connected user by name regardless of the case like FindUser
+func (service *ServiceImpl) findUserByName(name string) (int, bool) {
+	user := service.findUser(name)
+	if user == nil || user.ID == SystemUserID || user.Dead {
+		return 0, false
+	}
+	return user.ID, true
+}
+
+
+// isVisibilityValid checks if the visibility is one of the supported values
+func isVisibilityValid(visibility string) bool {
+	switch visibility {
+	case data.VisibilityPublic, data.VisibilityPrivate, data.VisibilityInviteOnly, data.VisibilityPassword:
+		return true
+	}
+	return false
+}
+
+
+// hashPassword hashes the room password so that it is never stored in plain text
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+
+// checkPassword compares the password against the stored hash
+func checkPassword(passwordHash string, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(passwordHash), []byte(hashPassword(password))) == 1
+}
+
+
+// copyUser copies the user so that callers cannot change the stored user
+func copyUser(user *data.User) data.User {
+	userCopy := *user
+	userCopy.Invitations = append([]int{}, user.Invitations...)
+	return userCopy
+}
+
+
+// copyRoom copies the room so that callers cannot change the stored room
+func copyRoom(room *data.Room) data.Room {
+	roomCopy := *room
+	roomCopy.Users = make(map[int]string, len(room.Users))
+	for id, name := range room.Users {
+		roomCopy.Users[id] = name
+	}
+	roomCopy.Invited = make(map[int]bool, len(room.Invited))
+	for id, invited := range room.Invited {
+		roomCopy.Invited[id] = invited
+	}
+	roomCopy.Metadata = make(map[string]string, len(room.Metadata))
+	for key, value := range room.Metadata {
+		roomCopy.Metadata[key] = value
+	}
+	return roomCopy
+}
+
+
+// sortRooms orders the rooms by id
+func sortRooms(rooms []data.Room) {
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
 }
 
+
 // formatMessage formats the message to a particular format
 func (service *ServiceImpl) formatMessage(
 	input data.Input,
@@ -293,17 +1152,18 @@ func (service *ServiceImpl) getTimeStamp() string {
 
 // logMessageToFile logs the message to the log file
 func (service *ServiceImpl) logMessageToFile(message string) {
-	file, err := os.OpenFile(
-		path.Join(service.logFilePath),
-		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
-		0666)
-	defer file.Close()
-
-	if err != nil {
-		panic(err)
+	if service.logFile == nil { // the file stays open until the chat server is closed
+		file, err := os.OpenFile(
+			path.Join(service.logFilePath),
+			os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+			0666)
+		if err != nil {
+			panic(err)
+		}
+		service.logFile = file
 	}
 
-	if _, err := file.WriteString(message); err != nil {
+	if _, err := service.logFile.WriteString(message); err != nil {
 		panic(err)
 	}
 }
@@ -312,7 +1172,7 @@ func (service *ServiceImpl) logMessageToFile(message string) {
 // saveMessage saves the message
 func (service *ServiceImpl) saveMessage(userID int, roomID int, userName string, roomName string, text string, timeStamp string)  data.Message{
 	newMessage := data.Message{
-		ID: len(service.messages),
+		ID: service.nextMessageID,
 		UserID: userID,
 		RoomID: roomID,
 		UserName: userName,
@@ -321,12 +1181,63 @@ func (service *ServiceImpl) saveMessage(userID int, roomID int, userName string,
 		TimeStamp: timeStamp,
 	}
 	service.messages = append(service.messages, newMessage)
+	service.nextMessageID++
 	return newMessage
 }
 
 
-// sendInfo sends the info to a particular user
-func (service *ServiceImpl) sendInfo(info string, userID int) {
-	user := service.users[userID]
-	user.Output <- info
-}
\ No newline at end of file
+// notify sends an event to a particular connected user
+func (service *ServiceImpl) notify(userID int, event data.Event) {
+	user, ok := service.users[userID]
+	if !ok || userID == SystemUserID || user.Dead || user.Bot {
+		return
+	}
+	select {
+		case user.Output <- event:
+		case <-time.After(1 * time.Second):
+			droppedSends.Inc(event.Type)
+			service.logger.Warn("Timeout sending an event to a user", "event", event.Type, "userId", userID)
+	}
+}
+
+
+// emitRoomEvent queues an event about a user and a room for the observers
+func (service *ServiceImpl) emitRoomEvent(eventType string, userID int, roomID int) {
+	service.emit(data.Event{
+		Type: eventType,
+		RoomID: roomID,
+		RoomName: service.rooms[roomID].Name,
+		UserID: userID,
+		UserName: service.users[userID].Name,
+	})
+}
+
+
+// emit queues an event for the observers, it is called with the lock held so the observers run on the dispatcher
+func (service *ServiceImpl) emit(event data.Event) {
+	if len(service.observers) == 0 {
+		return
+	}
+	service.pendingEvents.Add(1)
+	select {
+		case service.events <- event:
+		default:
+			service.pendingEvents.Done()
+			droppedObserverEvents.Inc()
+			service.logger.Warn("Dropping an event, the observers are too slow", "event", event.Type, "roomId", event.RoomID)
+	}
+}
+
+
+// dispatchEvents calls the observers for the queued events in order
+func (service *ServiceImpl) dispatchEvents() {
+	for event := range service.events {
+		service.RLock()
+		observers := service.observers
+		service.RUnlock()
+		for _, observer := range observers {
+			observer(event)
+		}
+		service.pendingEvents.Done()
+	}
+}
@@ -2,8 +2,12 @@ package chatserver
 
 import (
 	"chatServer/src/chatserver/data"
+	"io/ioutil"
+	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
@@ -17,7 +21,7 @@ func TestServiceImpl(t *testing.T) {
 }
 
 func createService(logFilePath string) Service {
-	return NewServiceImpl(logFilePath)
+	return NewServiceImpl(logFilePath, nil)
 }
 
 var _ = ginkgo.Describe("ServiceImpl", func() {
@@ -50,22 +54,20 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			user, _ := service.GetUser(1)
+			room, err := service.CreateRoom("Tech", 1, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Tech"))
 			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(2))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
 		})
 
 		ginkgo.It("Cannot create a room with same name", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			user, _ := service.GetUser(1)
+			service.CreateRoom("Tech", 1, "", "")
+			_, err := service.CreateRoom("Tech", 1, "", "")
 			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(2))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room with similar name already exists!!\n"))
+			gomega.Expect(err).To(gomega.Equal(ErrRoomExists))
 		})
 	})
 
@@ -75,11 +77,12 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			service.UnSubscribe(1, 1)
-			user, _ := service.GetUser(1)
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Unsubscribed Tech!!\n"))
+			service.CreateRoom("Tech", 1, "", "")
+			room, err := service.UnSubscribe(1, 1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Users).NotTo(gomega.HaveKey(1))
+			_, err = service.UnSubscribe(1, 1)
+			gomega.Expect(err).To(gomega.Equal(ErrNotSubscribed))
 		})
 	})
 
@@ -89,50 +92,360 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			user, _ := service.GetUser(1)
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
+			service.CreateUser("Bob")
+			service.CreateRoom("Tech", 1, "", "")
+			room, err := service.Subscribe(2, 1, "")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Users[2]).To(gomega.Equal("Bob"))
 			gomega.Expect(service.GetRooms()[1].Users[1]).To(gomega.Equal("TestUser"))
 		})
 
-		ginkgo.It("sends info to the user that the user is already subscribed when subscribing to a same room", func() {
+		ginkgo.It("returns an error when subscribing to a same room", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			room, err := service.Subscribe(1, 1, "")
+			gomega.Expect(err).To(gomega.Equal(ErrAlreadySubscribed))
+			gomega.Expect(room.Name).To(gomega.Equal("Tech"))
+		})
+	})
+
+	ginkgo.Context("Room visibility", func() {
+
+		ginkgo.It("hides private rooms from users who are not members", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Secret", 1, data.VisibilityPrivate, "")
+			service.CreateRoom("Club", 1, data.VisibilityInviteOnly, "")
+			gomega.Expect(len(service.GetVisibleRooms(1))).To(gomega.Equal(3))
+			gomega.Expect(len(service.GetVisibleRooms(2))).To(gomega.Equal(2))
+			gomega.Expect(service.GetVisibleRooms(2)[1].Name).To(gomega.Equal("Club"))
+		})
+
+		ginkgo.It("does not let users subscribe to private rooms without an invitation", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Secret", 1, data.VisibilityPrivate, "")
+			_, err := service.Subscribe(2, 1, "")
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNotFound))
+			gomega.Expect(service.GetRooms()[1].Users).NotTo(gomega.HaveKey(2))
+		})
+
+		ginkgo.It("subscribes to password protected rooms only with the right password", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Vault", 1, data.VisibilityPassword, "s3cret")
+			_, err := service.Subscribe(2, 1, "wrong")
+			gomega.Expect(err).To(gomega.Equal(ErrIncorrectPassword))
+			_, err = service.Subscribe(2, 1, "s3cret")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(service.GetRooms()[1].PasswordHash).NotTo(gomega.Equal("s3cret"))
+		})
+
+		ginkgo.It("does not create password protected rooms without a password", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			_, err := service.CreateRoom("Vault", 1, data.VisibilityPassword, "")
+			gomega.Expect(err).To(gomega.Equal(ErrPasswordMissing))
+			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(1))
+		})
+	})
+
+	ginkgo.Context("Invite", func() {
+
+		ginkgo.It("invites a user who can then accept the invitation", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Secret", 1, data.VisibilityPrivate, "")
+			invitee, err := service.Invite(1, "Bob", 1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(invitee.Name).To(gomega.Equal("Bob"))
+			bob, _ := service.GetUser(2)
+			invitation := <-bob.Output
+			gomega.Expect(invitation.Type).To(gomega.Equal(data.EventInvitation))
+			gomega.Expect(invitation.RoomID).To(gomega.Equal(1))
+			gomega.Expect(invitation.UserName).To(gomega.Equal("TestUser"))
+			gomega.Expect(bob.Invitations).To(gomega.Equal([]int{1}))
+			gomega.Expect(len(service.GetVisibleRooms(2))).To(gomega.Equal(2))
+			_, err = service.Invite(1, "Bob", 1)
+			gomega.Expect(err).To(gomega.Equal(ErrAlreadyInvited))
+
+			room, err := service.AcceptInvite(2, 1)
+			bob, _ = service.GetUser(2)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Secret"))
+			gomega.Expect(bob.Invitations).To(gomega.BeEmpty())
+			gomega.Expect(service.GetRooms()[1].Users[2]).To(gomega.Equal("Bob"))
+		})
+
+		ginkgo.It("finds the invited user regardless of the case like FindUser", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Secret", 1, data.VisibilityPrivate, "")
+			invitee, err := service.Invite(1, "@bOB", 1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(invitee.ID).To(gomega.Equal(2))
+			_, err = service.Invite(1, "alice", 1)
+			gomega.Expect(err).To(gomega.Equal(ErrUserNotFound))
+		})
+
+		ginkgo.It("does not let non members invite users", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Club", 1, data.VisibilityInviteOnly, "")
+			_, err := service.Invite(2, "Bob", 1)
+			gomega.Expect(err).To(gomega.Equal(ErrNotSubscribed))
+		})
+
+		ginkgo.It("does not accept a room without an invitation", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Club", 1, data.VisibilityInviteOnly, "")
+			_, err := service.AcceptInvite(2, 1)
+			gomega.Expect(err).To(gomega.Equal(ErrNoInvitation))
+		})
+	})
+
+	ginkgo.Context("Room details", func() {
+
+		ginkgo.It("records the creator and creation time of a room", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			room, _ := service.GetRoom(1)
+			gomega.Expect(room.CreatorID).To(gomega.Equal(1))
+			gomega.Expect(room.CreatedAt).NotTo(gomega.BeEmpty())
+		})
+
+		ginkgo.It("broadcasts topic changes to the members of the room", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			service.Subscribe(1, 1)
+			service.CreateUser("Bob")
+			service.SetTopic(1, 0, "Release planning")
+			room, _ := service.GetRoom(0)
 			user, _ := service.GetUser(1)
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Already subscribed to room Tech!!\n"))
+			bob, _ := service.GetUser(2)
+			gomega.Expect(room.Topic).To(gomega.Equal("Release planning"))
+			gomega.Expect((<-user.Output).Presence).To(gomega.Equal(data.PresenceOnline)) // Bob came online
+			gomega.Expect((<-user.Output).Text).To(gomega.ContainSubstring("|System| TestUser changed the topic to: Release planning"))
+			gomega.Expect((<-bob.Output).Text).To(gomega.ContainSubstring("changed the topic to: Release planning"))
+		})
+
+		ginkgo.It("does not change the topic of a room the user is not subscribed to", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Tech", 1, "", "")
+			_, err := service.SetTopic(2, 1, "Hijacked")
+			room, _ := service.GetRoom(1)
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNotFound))
+			gomega.Expect(room.Topic).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("returns the topic when switching rooms", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			service.SetTopic(1, 1, "Go")
+			service.SetDescription(1, 1, "All things tech")
+			room, err := service.SwitchRoom(1, 1)
+			user, _ := service.GetUser(1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect((<-user.Output).Text).To(gomega.ContainSubstring("changed the topic to: Go"))
+			gomega.Expect(room.Topic).To(gomega.Equal("Go"))
+			gomega.Expect(room.Description).To(gomega.Equal("All things tech"))
+		})
+
+		ginkgo.It("sets and removes metadata", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.SetMetadata(1, 0, "team", "platform")
+			room, _ := service.GetRoom(0)
+			gomega.Expect(room.Metadata).To(gomega.HaveKeyWithValue("team", "platform"))
+			service.SetMetadata(1, 0, "team", "")
+			room, _ = service.GetRoom(0)
+			gomega.Expect(room.Metadata).NotTo(gomega.HaveKey("team"))
 		})
 	})
 
-	ginkgo.Context("SwitchRoom", func() {
+	ginkgo.Context("Room lifecycle", func() {
 
-		ginkgo.It("switches to a room", func() {
+		ginkgo.It("renames a room and announces it", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tehc", 1, "", "")
+			room, err := service.RenameRoom(1, 1, "Tech")
+			user, _ := service.GetUser(1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Tech"))
+			gomega.Expect((<-user.Output).Text).To(gomega.ContainSubstring("Room Tehc renamed to Tech"))
+		})
+
+		ginkgo.It("only lets the creator change a room", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Tech", 1, "", "")
+			_, err := service.DeleteRoom(2, 1)
+			gomega.Expect(err).To(gomega.Equal(ErrNotCreator))
+			_, err = service.DeleteRoom(1, 0)
+			gomega.Expect(err).To(gomega.Equal(ErrDefaultRoom))
+			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(2))
+		})
+
+		ginkgo.It("archives a room making it read only and hidden from listings", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
 			service.SwitchRoom(1, 1)
+			service.Publish(data.Input{Room: 1, Text: "before archiving"}, 1, false)
+			service.ArchiveRoom(1, 1, true)
+			_, err := service.Publish(data.Input{Room: 1, Text: "after archiving"}, 1, false)
 			user, _ := service.GetUser(1)
+			gomega.Expect(user.ActiveRoom).To(gomega.Equal(0))
+			gomega.Expect(err).To(gomega.Equal(ErrRoomArchived))
+			gomega.Expect(len(service.GetVisibleRooms(1))).To(gomega.Equal(1))
+			gomega.Expect(len(service.GetMessages())).To(gomega.Equal(2))
+
+			service.ArchiveRoom(1, 1, false)
+			gomega.Expect(len(service.GetVisibleRooms(1))).To(gomega.Equal(2))
+			_, err = service.ArchiveRoom(1, 1, false)
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNotArchived))
+		})
+
+		ginkgo.It("deletes a room and moves active members to the Default room", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Tech", 1, "", "")
+			service.Subscribe(2, 1, "")
+			service.SwitchRoom(2, 1)
+			service.DeleteRoom(1, 1)
+			bob, _ := service.GetUser(2)
+			_, found := service.GetRoom(1)
+			gomega.Expect(found).To(gomega.Equal(false))
+			gomega.Expect(bob.ActiveRoom).To(gomega.Equal(0))
+			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(1))
+			gomega.Expect(<-bob.Output).To(gomega.Equal(data.Event{Type: data.EventRoomDeleted, RoomID: 1, RoomName: "Tech"}))
+			gomega.Expect(<-bob.Output).To(gomega.Equal(data.Event{Type: data.EventRoomSwitched, RoomID: 0, RoomName: "Default"}))
+
+			service.CreateRoom("Tech", 1, "", "")
+			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(2))
+		})
+	})
+
+	ginkgo.Context("SwitchRoom", func() {
+
+		ginkgo.It("switches to a room", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			room, err := service.SwitchRoom(1, 1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Tech"))
 			gomega.Expect(service.GetUsers()[1].ActiveRoom).To(gomega.Equal(1))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
-			gomega.Expect(<-user.Output).To(gomega.Equal("Switched to Tech!!\n"))
+			_, err = service.SwitchRoom(1, 1)
+			gomega.Expect(err).To(gomega.Equal(ErrAlreadyInRoom))
 		})
 	})
 
-	ginkgo.Context("ListRooms", func() {
+	ginkgo.Context("GetActiveRoom", func() {
 
-		ginkgo.It("lists all the rooms", func() {
+		ginkgo.It("returns the active room of the user", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
-			service.ListRooms(1)
-			user, _ := service.GetUser(1)
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
-			gomega.Expect(<-user.Output).To(gomega.ContainSubstring("Tech"))
+			room, err := service.GetActiveRoom(1)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(room.Name).To(gomega.Equal("Default"))
+			_, err = service.GetActiveRoom(42)
+			gomega.Expect(err).To(gomega.Equal(ErrUserNotFound))
+		})
+	})
+
+	ginkgo.Context("FindRoom", func() {
+
+		ginkgo.It("finds rooms by id, name and #name ignoring case", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			for _, reference := range []string{"1", "Tech", "#tech", "TECH"} {
+				room, found := service.FindRoom(1, reference)
+				gomega.Expect(found).To(gomega.Equal(true))
+				gomega.Expect(room.ID).To(gomega.Equal(1))
+			}
+			_, found := service.FindRoom(1, "#1")
+			gomega.Expect(found).To(gomega.Equal(false))
+		})
+
+		ginkgo.It("does not find private rooms of other users", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			service.CreateRoom("Secret", 1, data.VisibilityPrivate, "")
+			_, found := service.FindRoom(2, "#Secret")
+			gomega.Expect(found).To(gomega.Equal(false))
+			gomega.Expect(service.SuggestRooms(2, "#Secrt")).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("suggests rooms with similar names", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			service.CreateRoom("Music", 1, "", "")
+			gomega.Expect(service.SuggestRooms(1, "#tehc")).To(gomega.Equal([]string{"Tech"}))
+			gomega.Expect(service.SuggestRooms(1, "mus")).To(gomega.Equal([]string{"Music"}))
+		})
+
+		ginkgo.It("suggests users when inviting an unknown user", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			_, err := service.Invite(1, "@Bbo", 0)
+			gomega.Expect(err).To(gomega.Equal(ErrUserNotFound))
+			gomega.Expect(service.SuggestUsers("@Bbo")).To(gomega.Equal([]string{"Bob"}))
+		})
+
+		ginkgo.It("does not create rooms with numeric names", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			_, err := service.CreateRoom("42", 1, "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNameInvalid))
+			_, err = service.CreateRoom("tech", 1, "", "")
+			gomega.Expect(err).To(gomega.BeNil())
+			_, err = service.CreateRoom("Tech", 1, "", "")
+			gomega.Expect(err).To(gomega.Equal(ErrRoomExists))
 		})
 	})
 
@@ -148,16 +461,57 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 		})
 	})
 
+	ginkgo.Context("Identifiers", func() {
+
+		ginkgo.It("does not find users and rooms with unknown ids", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			_, userFound := service.GetUser(-1)
+			_, roomFound := service.GetRoom(42)
+			gomega.Expect(userFound).To(gomega.Equal(false))
+			gomega.Expect(roomFound).To(gomega.Equal(false))
+			_, err := service.Publish(data.Input{Room: 42, Text: "Hello!!"}, 0, false)
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNotFound))
+			_, err = service.Subscribe(-1, 0, "")
+			gomega.Expect(err).To(gomega.Equal(ErrUserNotFound))
+			service.RemoveUser(-1)
+		})
+
+		ginkgo.It("never reuses the id of a deleted room", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.CreateRoom("Tech", 1, "", "")
+			service.CreateRoom("Music", 1, "", "")
+			service.DeleteRoom(1, 1)
+			service.CreateRoom("Games", 1, "", "")
+			rooms := service.GetRooms()
+			gomega.Expect(len(rooms)).To(gomega.Equal(3))
+			gomega.Expect(rooms[1].ID).To(gomega.Equal(2))
+			gomega.Expect(rooms[2].ID).To(gomega.Equal(3))
+			room, _ := service.GetRoom(2)
+			gomega.Expect(room.Name).To(gomega.Equal("Music"))
+		})
+
+		ginkgo.It("returns copies that do not change the stored rooms", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			room, _ := service.GetRoom(0)
+			room.Users[42] = "Intruder"
+			room, _ = service.GetRoom(0)
+			gomega.Expect(room.Users).NotTo(gomega.HaveKey(42))
+		})
+	})
+
 	ginkgo.Context("GetRoom", func() {
 
 		ginkgo.It("Gets the room details", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
+			service.CreateRoom("Tech", 1, "", "")
 			room,_ := service.GetRoom(1)
-			user, _ := service.GetUser(1)
-			gomega.Expect(<-user.Output).To(gomega.Equal("Room Tech created!!\n"))
 			gomega.Expect(room.Name).To(gomega.ContainSubstring("Tech"))
 		})
 	})
@@ -170,11 +524,29 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			service.CreateUser("TestUser")
 			newUser := service.CreateUser("Bob")
 			service.Publish(data.Input{
-				0,
-				"Hello!!",
+				Room: 0,
+				Text: "Hello!!",
 			}, 1, false)
 			user, _ := service.GetUser(newUser.ID)
-			gomega.Expect(<-user.Output).To(gomega.ContainSubstring("Hello!!"))
+			gomega.Expect((<-user.Output).Text).To(gomega.ContainSubstring("Hello!!"))
+		})
+
+		ginkgo.It("Publishes the message of a bot user with its display name", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			bot := service.CreateBotUser("CI")
+			newUser := service.CreateUser("Bob")
+			message, err := service.Publish(data.Input{
+				Room: 0,
+				Text: "Build passed",
+				UserName: "Jenkins",
+			}, bot.ID, false)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(message.UserName).To(gomega.Equal("Jenkins"))
+			gomega.Expect((<-newUser.Output).Text).To(gomega.ContainSubstring("|Jenkins| Build passed"))
+
+			service.Publish(data.Input{Room: 0, Text: "Thanks"}, newUser.ID, false)
+			gomega.Expect(bot.Output).To(gomega.BeEmpty()) // bots do not receive events
 		})
 	})
 
@@ -186,8 +558,8 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			service.CreateUser("TestUser")
 			service.CreateUser("Bob")
 			service.Publish(data.Input{
-				0,
-				"Hello!!",
+				Room: 0,
+				Text: "Hello!!",
 			}, 1, false)
 			gomega.Expect(len(service.GetMessages())).To(gomega.Equal(1))
 		})
@@ -210,7 +582,7 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
 			service.Run()
 			service.CreateUser("TestUser")
-			service.CreateRoom("Tech", 1, "TestUser")
+			service.CreateRoom("Tech", 1, "", "")
 			gomega.Expect(len(service.GetRooms())).To(gomega.Equal(2))
 		})
 	})
@@ -227,4 +599,409 @@ var _ = ginkgo.Describe("ServiceImpl", func() {
 			gomega.Expect(user.Dead).To(gomega.Equal(true))
 		})
 	})
+	ginkgo.Context("AddObserver", func() {
+
+		ginkgo.It("passes the messages, joins, leaves and created rooms to the observer in order", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			events := make(chan data.Event, 10)
+			service.AddObserver(func(event data.Event) { events <- event })
+			service.CreateUser("TestUser")
+			service.CreateUser("Bob")
+			room, _ := service.CreateRoom("Tech", 1, "", "")
+			service.Subscribe(2, room.ID, "")
+			service.Publish(data.Input{Text: "hello", Room: room.ID}, 2, false)
+			service.UnSubscribe(2, room.ID)
+
+			types := []string{}
+			for range []int{1, 2, 3, 4} {
+				var event data.Event
+				gomega.Eventually(events).Should(gomega.Receive(&event))
+				gomega.Expect(event.RoomName).To(gomega.Equal("Tech"))
+				types = append(types, event.Type)
+				if event.Type == data.EventMessage {
+					gomega.Expect(event.Message.Text).To(gomega.Equal("hello"))
+					gomega.Expect(event.UserName).To(gomega.Equal("Bob"))
+				}
+			}
+			gomega.Expect(types).To(gomega.Equal([]string{data.EventRoomCreated, data.EventJoin, data.EventMessage, data.EventLeave}))
+		})
+	})
+
+	ginkgo.Context("Broadcast", func() {
+
+		ginkgo.It("publishes a System message to every room", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			user := service.CreateUser("TestUser")
+			service.CreateRoom("Tech", user.ID, "", "")
+			messages := service.Broadcast("Server is shutting down")
+			gomega.Expect(len(messages)).To(gomega.Equal(2))
+			gomega.Expect(messages[0].UserName).To(gomega.Equal("System"))
+			gomega.Expect(len(service.GetMessages())).To(gomega.Equal(2))
+		})
+	})
+
+	ginkgo.Context("SetLogFilePath", func() {
+
+		ginkgo.It("writes the next messages to the new file", func() {
+			file, _ := ioutil.TempFile("", "messages")
+			file.Close()
+			defer os.Remove(file.Name())
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.CreateUser("TestUser")
+			service.Publish(data.Input{Text: "before", Room: 0}, 1, false)
+			service.SetLogFilePath(file.Name())
+			service.Publish(data.Input{Text: "after", Room: 0}, 1, false)
+			service.Close()
+
+			content, _ := ioutil.ReadFile(file.Name())
+			gomega.Expect(string(content)).To(gomega.ContainSubstring("after"))
+			gomega.Expect(string(content)).ToNot(gomega.ContainSubstring("before"))
+		})
+	})
+
+	ginkgo.Context("FindUser", func() {
+
+		ginkgo.It("finds a user by name ignoring the case and the @, preferring connected users", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			first := service.CreateUser("Alice")
+			service.RemoveUser(first.ID)
+			second := service.CreateUser("alice")
+			user, found := service.FindUser("@ALICE")
+			gomega.Expect(found).To(gomega.BeTrue())
+			gomega.Expect(user.ID).To(gomega.Equal(second.ID))
+			_, found = service.FindUser("bob")
+			gomega.Expect(found).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("Oper", func() {
+
+		ginkgo.It("makes the configured users admins when the password matches", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			service.SetAdmins(map[string]string{"Alice": "secret"})
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+
+			_, err := service.Oper(alice.ID, "wrong")
+			gomega.Expect(err).To(gomega.Equal(ErrOperFailed))
+			_, err = service.Oper(bob.ID, "secret")
+			gomega.Expect(err).To(gomega.Equal(ErrOperFailed))
+			user, err := service.Oper(alice.ID, "secret")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(user.Admin).To(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Context("SetAdmin", func() {
+
+		ginkgo.It("grants and revokes the admin role", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			service.SetAdmin(alice.ID, true)
+			user, _ := service.GetUser(alice.ID)
+			gomega.Expect(user.Admin).To(gomega.BeTrue())
+			service.SetAdmin(alice.ID, false)
+			user, _ = service.GetUser(alice.ID)
+			gomega.Expect(user.Admin).To(gomega.BeFalse())
+			_, err := service.SetAdmin(SystemUserID, true)
+			gomega.Expect(err).To(gomega.Equal(ErrUserNotFound))
+		})
+	})
+
+	ginkgo.Context("Disconnect", func() {
+
+		ginkgo.It("tells the listener of a connected user to close the connection", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			bot := service.CreateBotUser("EchoBot")
+			gomega.Expect(service.Disconnect(alice.ID, "flooding")).To(gomega.Succeed())
+			var event data.Event
+			gomega.Expect(alice.Output).To(gomega.Receive(&event))
+			gomega.Expect(event.Type).To(gomega.Equal(data.EventKilled))
+			gomega.Expect(event.Text).To(gomega.Equal("flooding"))
+
+			gomega.Expect(service.Disconnect(bot.ID, "")).To(gomega.Equal(ErrUserNotConnected))
+			service.RemoveUser(alice.ID)
+			gomega.Expect(service.Disconnect(alice.ID, "")).To(gomega.Equal(ErrUserNotConnected))
+			gomega.Expect(service.Disconnect(42, "")).To(gomega.Equal(ErrUserNotFound))
+		})
+	})
+
+	ginkgo.Context("SetWelcome", func() {
+
+		ginkgo.It("sets the welcome message of a room the user is subscribed to", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+			room, _ := service.CreateRoom("Tech", alice.ID, "", "")
+			room, err := service.SetWelcome(alice.ID, room.ID, "Be nice")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(room.Welcome).To(gomega.Equal("Be nice"))
+			_, err = service.SetWelcome(bob.ID, room.ID, "Be mean")
+			gomega.Expect(err).To(gomega.Equal(ErrRoomNotFound))
+		})
+	})
+
+	ginkgo.Context("GetSummary", func() {
+
+		ginkgo.It("counts the messages posted since a user of the same name left and lists the members online", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+			service.Publish(data.Input{Text: "seen", Room: DefaultRoomID}, bob.ID, false)
+			summaries := service.GetSummary(alice.ID)
+			gomega.Expect(summaries).To(gomega.Equal([]data.RoomSummary{{ID: 0, Name: "Default", Unread: 0, Online: []string{"bob"}}}))
+
+			service.RemoveUser(alice.ID)
+			service.Publish(data.Input{Text: "missed", Room: DefaultRoomID}, bob.ID, false)
+			service.Publish(data.Input{Text: "missed too", Room: DefaultRoomID}, bob.ID, false)
+			returning := service.CreateUser("Alice")
+			summaries = service.GetSummary(returning.ID)
+			gomega.Expect(summaries[0].Unread).To(gomega.Equal(2))
+			gomega.Expect(summaries[0].Online).To(gomega.Equal([]string{"bob"}))
+		})
+	})
+
+	ginkgo.Context("SetMOTD", func() {
+
+		ginkgo.It("replaces the message of the day", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			gomega.Expect(service.GetMOTD()).To(gomega.BeEmpty())
+			service.SetMOTD("Hello")
+			gomega.Expect(service.GetMOTD()).To(gomega.Equal("Hello"))
+		})
+	})
+
+	ginkgo.Context("Presence", func() {
+
+		// nextPresence returns the next presence event sent to the user
+		nextPresence := func(output chan data.Event) data.Event {
+			for event := range output {
+				if event.Type == data.EventPresence {
+					return event
+				}
+			}
+			return data.Event{}
+		}
+
+		ginkgo.It("tells the members of the shared rooms about the changes", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+			gomega.Expect(nextPresence(alice.Output).UserName).To(gomega.Equal("bob"))
+
+			user, err := service.SetPresence(bob.ID, data.PresenceAway, "lunch")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(user.Presence).To(gomega.Equal(data.PresenceAway))
+			event := nextPresence(alice.Output)
+			gomega.Expect(event.Presence).To(gomega.Equal(data.PresenceAway))
+			gomega.Expect(event.Text).To(gomega.Equal("lunch"))
+
+			_, err = service.SetPresence(bob.ID, data.PresenceOffline, "")
+			gomega.Expect(err).To(gomega.Equal(ErrPresenceInvalid))
+
+			service.RemoveUser(bob.ID)
+			gomega.Expect(nextPresence(alice.Output).Presence).To(gomega.Equal(data.PresenceOffline))
+			presence, found := service.GetPresence(bob.ID)
+			gomega.Expect(found).To(gomega.BeTrue())
+			gomega.Expect(presence.State).To(gomega.Equal(data.PresenceOffline))
+			gomega.Expect(presence.Status).To(gomega.BeEmpty())
+			gomega.Expect(presence.LastSeen).To(gomega.MatchRegexp(`^\d{4}-\d\d-\d\dT`))
+		})
+
+		ginkgo.It("marks the idle users away until they are active again", func() {
+			service := NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+			start := time.Date(2019, 6, 8, 17, 0, 0, 0, time.UTC)
+			now := start
+			service.now = func() time.Time { return now }
+			service.Run()
+			service.SetAwayAfter(10 * time.Minute)
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+			service.SetPresence(bob.ID, data.PresenceBusy, "")
+
+			now = start.Add(11 * time.Minute)
+			service.markIdle()
+			user, _ := service.GetUser(alice.ID)
+			gomega.Expect(user.Presence).To(gomega.Equal(data.PresenceAway))
+			gomega.Expect(user.AutoAway).To(gomega.BeTrue())
+			user, _ = service.GetUser(bob.ID)
+			gomega.Expect(user.Presence).To(gomega.Equal(data.PresenceBusy))
+
+			service.Touch(alice.ID)
+			user, _ = service.GetUser(alice.ID)
+			gomega.Expect(user.Presence).To(gomega.Equal(data.PresenceOnline))
+			gomega.Expect(user.LastSeen).To(gomega.Equal(now))
+		})
+	})
+
+	ginkgo.Context("Typing", func() {
+
+		// nextTyping returns the next typing event sent to the user
+		nextTyping := func(output chan data.Event) data.Event {
+			for event := range output {
+				if event.Type == data.EventTyping {
+					return event
+				}
+			}
+			return data.Event{}
+		}
+
+		ginkgo.It("tells the members of the room until the user stops, posts or the typing expires", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			seen := make(chan data.Event, 10)
+			service.AddObserver(func(event data.Event) { seen <- event })
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+
+			gomega.Expect(service.SetTyping(bob.ID, DefaultRoomID, true)).To(gomega.Succeed())
+			gomega.Expect(service.SetTyping(bob.ID, DefaultRoomID, true)).To(gomega.Succeed()) // refreshed, not sent again
+			gomega.Expect(service.IsTyping(bob.ID, DefaultRoomID)).To(gomega.BeTrue())
+			event := nextTyping(alice.Output)
+			gomega.Expect(event.UserName).To(gomega.Equal("bob"))
+			gomega.Expect(event.RoomName).To(gomega.Equal("Default"))
+			gomega.Expect(event.Typing).To(gomega.BeTrue())
+
+			service.Publish(data.Input{Text: "hello", Room: DefaultRoomID}, bob.ID, false)
+			gomega.Expect(nextTyping(alice.Output).Typing).To(gomega.BeFalse())
+			gomega.Expect(service.IsTyping(bob.ID, DefaultRoomID)).To(gomega.BeFalse())
+
+			typingTimeout = 20 * time.Millisecond
+			defer func() { typingTimeout = 6 * time.Second }()
+			service.SetTyping(bob.ID, DefaultRoomID, true)
+			gomega.Expect(nextTyping(alice.Output).Typing).To(gomega.BeTrue())
+			gomega.Expect(nextTyping(alice.Output).Typing).To(gomega.BeFalse()) // expired
+			gomega.Eventually(func() bool { return service.IsTyping(bob.ID, DefaultRoomID) }).Should(gomega.BeFalse())
+
+			gomega.Expect(service.Close()).To(gomega.Succeed())
+			for len(seen) > 0 {
+				gomega.Expect((<-seen).Type).NotTo(gomega.Equal(data.EventTyping))
+			}
+			gomega.Expect(service.GetMessages()).To(gomega.HaveLen(1))
+		})
+
+		ginkgo.It("only tells the members who did not hide the typing", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+			room, _ := service.CreateRoom("secret", bob.ID, data.VisibilityPrivate, "")
+			gomega.Expect(service.SetTyping(alice.ID, room.ID, true)).To(gomega.Equal(ErrRoomNotFound))
+
+			user, err := service.ShowTyping(alice.ID, false)
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(user.HideTyping).To(gomega.BeTrue())
+			service.SetTyping(bob.ID, DefaultRoomID, true)
+			service.SetTyping(bob.ID, DefaultRoomID, false)
+			service.ShowTyping(alice.ID, true)
+			service.SetTyping(bob.ID, DefaultRoomID, true)
+			gomega.Expect(nextTyping(alice.Output).Typing).To(gomega.BeTrue()) // the hidden start and stop were not sent
+		})
+	})
+
+	ginkgo.Context("Profile", func() {
+
+		ginkgo.It("renames the user in its rooms and tells their members", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice := service.CreateUser("alice")
+			bob := service.CreateUser("bob")
+			room, _ := service.CreateRoom("tech", bob.ID, data.VisibilityPublic, "")
+
+			_, err := service.Rename(bob.ID, "Alice")
+			gomega.Expect(err).To(gomega.Equal(ErrNameInUse))
+			_, err = service.Rename(bob.ID, "bob smith")
+			gomega.Expect(err).To(gomega.Equal(ErrNameInvalid))
+			_, err = service.Rename(bob.ID, "#bob")
+			gomega.Expect(err).To(gomega.Equal(ErrNameInvalid))
+
+			user, err := service.Rename(bob.ID, "robert")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(user.Name).To(gomega.Equal("robert"))
+			for _, roomID := range []int{DefaultRoomID, room.ID} {
+				room, _ := service.GetRoom(roomID)
+				gomega.Expect(room.Users[bob.ID]).To(gomega.Equal("robert"))
+			}
+			for event := range alice.Output {
+				if event.Type == data.EventNick {
+					gomega.Expect(event.Text).To(gomega.Equal("bob"))
+					gomega.Expect(event.UserName).To(gomega.Equal("robert"))
+					break
+				}
+			}
+
+			service.RemoveUser(alice.ID)
+			_, err = service.Rename(bob.ID, "alice") // the names of the users who left can be taken
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		})
+
+		ginkgo.It("registers the users who log in with a valid name that is not in use", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			alice, err := service.RegisterUser("alice")
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			for _, name := range []string{"", "42", "alice smith", "@alice"} {
+				_, err = service.RegisterUser(name)
+				gomega.Expect(err).To(gomega.Equal(ErrNameInvalid))
+			}
+			_, err = service.RegisterUser("ALICE")
+			gomega.Expect(err).To(gomega.Equal(ErrNameInUse))
+			gomega.Expect(service.CheckName("Alice")).To(gomega.Equal(ErrNameInUse))
+
+			service.RemoveUser(alice.ID)
+			gomega.Expect(service.CheckName("Alice")).To(gomega.Succeed())
+		})
+
+		ginkgo.It("changes the fields of the profile that are given", func() {
+			service := NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+			service.now = func() time.Time { return time.Date(2019, 6, 8, 17, 0, 0, 0, time.UTC) }
+			service.Run()
+			bob := service.CreateUser("bob")
+			displayName, bio, timezone := "Bob Smith", "Gopher", "UTC"
+
+			profile, err := service.SetProfile(bob.ID, data.ProfileUpdate{DisplayName: &displayName, Timezone: &timezone})
+			gomega.Expect(err).NotTo(gomega.HaveOccurred())
+			gomega.Expect(profile.DisplayName).To(gomega.Equal("Bob Smith"))
+			gomega.Expect(profile.LocalTime).To(gomega.Equal("2019-06-08T17:00:00Z"))
+			profile, _ = service.SetProfile(bob.ID, data.ProfileUpdate{Bio: &bio})
+			gomega.Expect(profile.DisplayName).To(gomega.Equal("Bob Smith"))
+			gomega.Expect(profile.Bio).To(gomega.Equal("Gopher"))
+
+			timezone = "Mars/Olympus"
+			_, err = service.SetProfile(bob.ID, data.ProfileUpdate{Timezone: &timezone})
+			gomega.Expect(err).To(gomega.Equal(ErrTimezoneInvalid))
+			bio = strings.Repeat("b", 301)
+			_, err = service.SetProfile(bob.ID, data.ProfileUpdate{Bio: &bio})
+			gomega.Expect(err).To(gomega.Equal(ErrProfileInvalid))
+			profile, found := service.GetProfile(bob.ID)
+			gomega.Expect(found).To(gomega.BeTrue())
+			gomega.Expect(profile.Timezone).To(gomega.Equal("UTC"))
+			gomega.Expect(profile.Bio).To(gomega.Equal("Gopher"))
+		})
+	})
+
+	ginkgo.Context("Close", func() {
+
+		ginkgo.It("waits until the observers have seen the pending events", func() {
+			service := createService(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"))
+			service.Run()
+			seen := make(chan data.Event, 10)
+			service.AddObserver(func(event data.Event) { seen <- event })
+			service.CreateUser("TestUser")
+			service.Publish(data.Input{Text: "hello", Room: 0}, 1, false)
+			gomega.Expect(service.Close()).To(gomega.Succeed())
+			gomega.Expect(seen).To(gomega.HaveLen(1))
+		})
+	})
 })
@@ -1,6 +1,10 @@
 package chatserver
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/stretchr/testify/mock"
 
 	"chatServer/src/chatserver/data"
@@ -24,56 +28,193 @@ func (mock *ServiceMock) CreateUser(username string) data.User {
 }
 
 
+// CreateBotUser mocks chatserver Service CreateBotUser method
+func (mock *ServiceMock) CreateBotUser(username string) data.User {
+	return data.User{ID: len(dummyUsers), Name: username, Bot: true}
+}
+
+
+// RegisterUser mocks chatserver Service RegisterUser method
+func (mock *ServiceMock) RegisterUser(username string) (data.User, error) {
+	return data.User{}, nil
+}
+
+
+// CheckName mocks chatserver Service CheckName method
+func (mock *ServiceMock) CheckName(username string) error {
+	return nil
+}
+
+
 // Publish mocks chatserver Service Publish method
-func (mock *ServiceMock) Publish(input data.Input, userID int, sysMessage bool) data.Message {
-	return dummyMessages[1]
+func (mock *ServiceMock) Publish(input data.Input, userID int, sysMessage bool) (data.Message, error) {
+	return dummyMessages[1], nil
 }
 
 
 // Subscribe mocks chatserver Service Subscribe method
-func (mock *ServiceMock) Subscribe(userID int, roomID int) {
+func (mock *ServiceMock) Subscribe(userID int, roomID int, password string) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
 }
 
 
 // GetActiveRoom mocks chatserver Service GetActiveRoom method
-func (mock *ServiceMock) GetActiveRoom(userID int) {
+func (mock *ServiceMock) GetActiveRoom(userID int) (data.Room, error) {
+	return dummyRooms[0], nil
 }
 
 
 // UnSubscribe mocks chatserver Service UnSubscribe method
-func (mock *ServiceMock) UnSubscribe(userID int, roomID int) {
+func (mock *ServiceMock) UnSubscribe(userID int, roomID int) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
 }
 
 
 // SwitchRoom mocks chatserver Service SwitchRoom method
-func (mock *ServiceMock) SwitchRoom(userID int, roomID int) {
+func (mock *ServiceMock) SwitchRoom(userID int, roomID int) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// GetVisibleRooms mocks chatserver Service GetVisibleRooms method
+func (mock *ServiceMock) GetVisibleRooms(userID int) []data.Room {
+	return dummyRooms
+}
+
+
+// FindRoom mocks chatserver Service FindRoom method
+func (mock *ServiceMock) FindRoom(userID int, reference string) (data.Room, bool) {
+	for _, room := range dummyRooms {
+		if "#" + room.Name == reference || room.Name == reference {
+			return room, true
+		}
+	}
+	return data.Room{}, false
+}
+
+
+// SuggestRooms mocks chatserver Service SuggestRooms method
+func (mock *ServiceMock) SuggestRooms(userID int, reference string) []string {
+	return []string{}
+}
+
+
+// SuggestUsers mocks chatserver Service SuggestUsers method
+func (mock *ServiceMock) SuggestUsers(name string) []string {
+	return []string{}
+}
+
+
+// CanViewRoom mocks chatserver Service CanViewRoom method
+func (mock *ServiceMock) CanViewRoom(userID int, roomID int) bool {
+	return roomID < len(dummyRooms)
 }
 
 
-// ListRooms mocks chatserver Service ListRooms method
-func (mock *ServiceMock) ListRooms(userID int) {
+// CanReadRoom mocks chatserver Service CanReadRoom method
+func (mock *ServiceMock) CanReadRoom(userID int, roomID int) bool {
+	return roomID < len(dummyRooms)
+}
+
+
+// SubscribeBot mocks chatserver Service SubscribeBot method
+func (mock *ServiceMock) SubscribeBot(userID int, roomID int) (data.Room, error) {
+	if roomID < 0 || roomID >= len(dummyRooms) {
+		return data.Room{}, ErrRoomNotFound
+	}
+	return dummyRooms[roomID], nil
 }
 
 
 // CreateRoom mocks chatserver Service CreateRoom method
-func (mock *ServiceMock) CreateRoom(roomName string, userID int, userName string) {
+func (mock *ServiceMock) CreateRoom(roomName string, userID int, visibility string, password string) (data.Room, error) {
+	return data.Room{Name: roomName, Visibility: visibility, CreatorID: userID}, nil
 }
 
 
-// SendInfo mocks chatserver Service SendInfo method
-func (mock *ServiceMock) SendInfo(info string, userID int) {
+// Invite mocks chatserver Service Invite method
+func (mock *ServiceMock) Invite(userID int, inviteeName string, roomID int) (data.User, error) {
+	return dummyUsers[2], nil
+}
+
+
+// AcceptInvite mocks chatserver Service AcceptInvite method
+func (mock *ServiceMock) AcceptInvite(userID int, roomID int) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// SetTopic mocks chatserver Service SetTopic method
+func (mock *ServiceMock) SetTopic(userID int, roomID int, topic string) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// SetDescription mocks chatserver Service SetDescription method
+func (mock *ServiceMock) SetDescription(userID int, roomID int, description string) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// SetMetadata mocks chatserver Service SetMetadata method
+func (mock *ServiceMock) SetMetadata(userID int, roomID int, key string, value string) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// RenameRoom mocks chatserver Service RenameRoom method
+func (mock *ServiceMock) RenameRoom(userID int, roomID int, roomName string) (data.Room, error) {
+	room, err := mock.getRoomOrError(roomID)
+	if err != nil || strings.EqualFold(room.Name, roomName) {
+		return room, err
+	}
+	// the names are checked like the chat server does
+	if _, err := strconv.Atoi(roomName); err == nil || roomName == "" {
+		return data.Room{}, ErrRoomNameInvalid
+	}
+	for _, existingRoom := range dummyRooms {
+		if strings.EqualFold(existingRoom.Name, roomName) {
+			return data.Room{}, ErrRoomExists
+		}
+	}
+	room.Name = roomName
+	return room, nil
+}
+
+
+// ArchiveRoom mocks chatserver Service ArchiveRoom method
+func (mock *ServiceMock) ArchiveRoom(userID int, roomID int, archived bool) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// DeleteRoom mocks chatserver Service DeleteRoom method
+func (mock *ServiceMock) DeleteRoom(userID int, roomID int) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+
+// getRoomOrError returns the dummy room or ErrRoomNotFound
+func (mock *ServiceMock) getRoomOrError(roomID int) (data.Room, error) {
+	if room, ok := mock.GetRoom(roomID); ok {
+		return room, nil
+	}
+	return data.Room{}, ErrRoomNotFound
 }
 
 
 // GetRoom mocks chatserver Service GetRoom method
 func (mock *ServiceMock) GetRoom(roomID int) (data.Room, bool) {
-	return data.Room{}, false
+	if roomID < 0 || roomID >= len(dummyRooms) {
+		return data.Room{}, false
+	}
+	return dummyRooms[roomID], true
 }
 
 
 // GetUser mocks chatserver Service GetUser method
 func (mock *ServiceMock) GetUser(userID int) (data.User, bool) {
-	if userID > len(dummyUsers) {
+	if userID < 0 || userID >= len(dummyUsers) {
 		return data.User{}, false
 	}
 	return dummyUsers[userID], true
@@ -98,6 +239,169 @@ func (mock *ServiceMock) GetRooms() []data.Room {
 func (mock *ServiceMock) RemoveUser(userID int) {
 }
 
+// AddObserver mocks chatserver Service AddObserver method
+func (mock *ServiceMock) AddObserver(observer Observer) {
+}
+
+// Broadcast mocks chatserver Service Broadcast method
+func (mock *ServiceMock) Broadcast(text string) []data.Message {
+	return []data.Message{}
+}
+
+// SetLogFilePath mocks chatserver Service SetLogFilePath method
+func (mock *ServiceMock) SetLogFilePath(logFilePath string) {
+}
+
+// CheckStorage mocks chatserver Service CheckStorage method
+func (mock *ServiceMock) CheckStorage() error {
+	return nil
+}
+
+// FindUser mocks chatserver Service FindUser method
+func (mock *ServiceMock) FindUser(name string) (data.User, bool) {
+	for _, user := range dummyUsers {
+		if strings.EqualFold(user.Name, strings.TrimPrefix(name, "@")) {
+			return user, true
+		}
+	}
+	return data.User{}, false
+}
+
+// SetAdmins mocks chatserver Service SetAdmins method
+func (mock *ServiceMock) SetAdmins(admins map[string]string) {
+}
+
+// Oper mocks chatserver Service Oper method
+func (mock *ServiceMock) Oper(userID int, password string) (data.User, error) {
+	return data.User{}, ErrOperFailed
+}
+
+// SetAdmin mocks chatserver Service SetAdmin method
+func (mock *ServiceMock) SetAdmin(userID int, admin bool) (data.User, error) {
+	user, found := mock.GetUser(userID)
+	if !found {
+		return data.User{}, ErrUserNotFound
+	}
+	user.Admin = admin
+	return user, nil
+}
+
+// Disconnect mocks chatserver Service Disconnect method
+func (mock *ServiceMock) Disconnect(userID int, reason string) error {
+	if _, found := mock.GetUser(userID); !found {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SetWelcome mocks chatserver Service SetWelcome method
+func (mock *ServiceMock) SetWelcome(userID int, roomID int, welcome string) (data.Room, error) {
+	return mock.getRoomOrError(roomID)
+}
+
+// SetMOTD mocks chatserver Service SetMOTD method
+func (mock *ServiceMock) SetMOTD(motd string) {
+}
+
+// GetMOTD mocks chatserver Service GetMOTD method
+func (mock *ServiceMock) GetMOTD() string {
+	return ""
+}
+
+// GetSummary mocks chatserver Service GetSummary method
+func (mock *ServiceMock) GetSummary(userID int) []data.RoomSummary {
+	return []data.RoomSummary{}
+}
+
+// SetPresence mocks chatserver Service SetPresence method
+func (mock *ServiceMock) SetPresence(userID int, state string, status string) (data.User, error) {
+	user, found := mock.GetUser(userID)
+	if !found {
+		return data.User{}, ErrUserNotFound
+	}
+	user.Presence, user.StatusText = state, status
+	return user, nil
+}
+
+// GetPresence mocks chatserver Service GetPresence method
+func (mock *ServiceMock) GetPresence(userID int) (data.Presence, bool) {
+	user, found := mock.GetUser(userID)
+	if !found {
+		return data.Presence{}, false
+	}
+	return data.Presence{UserID: user.ID, Name: user.Name, State: data.PresenceOnline}, true
+}
+
+// Touch mocks chatserver Service Touch method
+func (mock *ServiceMock) Touch(userID int) {
+}
+
+// SetAwayAfter mocks chatserver Service SetAwayAfter method
+func (mock *ServiceMock) SetAwayAfter(awayAfter time.Duration) {
+}
+
+// SetTyping mocks chatserver Service SetTyping method
+func (mock *ServiceMock) SetTyping(userID int, roomID int, typing bool) error {
+	return nil
+}
+
+// IsTyping mocks chatserver Service IsTyping method
+func (mock *ServiceMock) IsTyping(userID int, roomID int) bool {
+	return false
+}
+
+// ShowTyping mocks chatserver Service ShowTyping method
+func (mock *ServiceMock) ShowTyping(userID int, show bool) (data.User, error) {
+	user, found := mock.GetUser(userID)
+	if !found {
+		return data.User{}, ErrUserNotFound
+	}
+	user.HideTyping = !show
+	return user, nil
+}
+
+// Rename mocks chatserver Service Rename method
+func (mock *ServiceMock) Rename(userID int, name string) (data.User, error) {
+	user, found := mock.GetUser(userID)
+	if !found {
+		return data.User{}, ErrUserNotFound
+	}
+	user.Name = name
+	return user, nil
+}
+
+// SetProfile mocks chatserver Service SetProfile method
+func (mock *ServiceMock) SetProfile(userID int, update data.ProfileUpdate) (data.Profile, error) {
+	profile, found := mock.GetProfile(userID)
+	if !found {
+		return data.Profile{}, ErrUserNotFound
+	}
+	if update.DisplayName != nil {
+		profile.DisplayName = *update.DisplayName
+	}
+	if update.Bio != nil {
+		profile.Bio = *update.Bio
+	}
+	if update.Timezone != nil {
+		profile.Timezone = *update.Timezone
+	}
+	return profile, nil
+}
+
+// GetProfile mocks chatserver Service GetProfile method
+func (mock *ServiceMock) GetProfile(userID int) (data.Profile, bool) {
+	user, found := mock.GetUser(userID)
+	if !found {
+		return data.Profile{}, false
+	}
+	return data.Profile{UserID: user.ID, Name: user.Name}, true
+}
+
+// Close mocks chatserver Service Close method
+func (mock *ServiceMock) Close() error {
+	return nil
+}
+
 var dummyMessages = []data.Message {
 	{
 		ID: 0,
@@ -148,6 +452,7 @@ var dummyUsers = []data.User {
 		ID: 3,
 		Name: "John",
 		ActiveRoom: 0,
+		Admin: true,
 	},
 }
 
@@ -155,5 +460,13 @@ var dummyRooms = []data.Room {
 	{
 		ID: 0,
 		Name: "Default",
+		Visibility: data.VisibilityPublic,
+		Topic: "General discussion",
+	},
+	{
+		ID: 1,
+		Name: "Tech",
+		Visibility: data.VisibilityPublic,
+		CreatorID: 1,
 	},
 }
\ No newline at end of file
@@ -0,0 +1,76 @@
+package chatserver
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of suggestions offered for a name that was not found
+const maxSuggestions = 3
+
+// maxSuggestionDistance is the edit distance up to which a name is considered a typo
+const maxSuggestionDistance = 2
+
+// suggest returns the candidates that are close to the name, closest first
+func suggest(name string, candidates []string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	name = strings.ToLower(name)
+	suggestions := []suggestion{}
+	for _, candidate := range candidates {
+		lowerCandidate := strings.ToLower(candidate)
+		distance := levenshtein(name, lowerCandidate)
+		if distance <= maxSuggestionDistance || (len(name) > 1 && strings.HasPrefix(lowerCandidate, name)) {
+			suggestions = append(suggestions, suggestion{candidate, distance})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	names := []string{}
+	for i := 0; i < len(suggestions) && i < maxSuggestions; i++ {
+		names = append(names, suggestions[i].name)
+	}
+	return names
+}
+
+// FormatSuggestions formats the suggestions as a question, the prefix is added to every name
+func FormatSuggestions(suggestions []string, prefix string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return " Did you mean " + prefix + strings.Join(suggestions, ", "+prefix) + "?"
+}
+
+// levenshtein returns the number of single character edits needed to turn one string into the other
+func levenshtein(first string, second string) int {
+	a, b := []rune(first), []rune(second)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// minInt returns the smaller of two ints
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
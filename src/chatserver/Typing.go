@@ -0,0 +1,111 @@
+package chatserver
+
+import (
+	"time"
+
+	"chatServer/src/chatserver/data"
+)
+
+// typingTimeout is how long a user is shown typing without sending another typing event
+var typingTimeout = 6 * time.Second
+
+// SetTyping starts or stops showing the user typing to the other members of the room, a user who keeps typing
+// sends it again before the timeout. Typing events are neither stored, logged nor passed to the observers
+func (service *ServiceImpl) SetTyping(userID int, roomID int, typing bool) error {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || user.Dead || userID == SystemUserID {
+		return ErrUserNotFound
+	}
+	if !typing {
+		if !service.isMember(userID, roomID) {
+			return ErrRoomNotFound
+		}
+		service.stopTyping(userID, roomID)
+		return nil
+	}
+	if err := service.canEdit(userID, roomID); err != nil {
+		return err
+	}
+	typists, ok := service.typing[roomID]
+	if !ok {
+		typists = map[int]*time.Timer{}
+		service.typing[roomID] = typists
+	}
+	if timer, started := typists[userID]; started {
+		timer.Stop()
+	} else {
+		service.notifyTyping(user, service.rooms[roomID], true)
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(typingTimeout, func() {
+		service.Lock()
+		defer service.Unlock()
+		if service.typing[roomID][userID] == timer { // not refreshed or stopped since
+			service.stopTyping(userID, roomID)
+		}
+	})
+	typists[userID] = timer
+	return nil
+}
+
+// IsTyping checks if the user is shown typing in the room
+func (service *ServiceImpl) IsTyping(userID int, roomID int) bool {
+	service.RLock()
+	defer service.RUnlock()
+	_, typing := service.typing[roomID][userID]
+	return typing
+}
+
+// ShowTyping sets whether the user is told when the members of its rooms are typing
+func (service *ServiceImpl) ShowTyping(userID int, show bool) (data.User, error) {
+	service.Lock()
+	defer service.Unlock()
+	user, ok := service.users[userID]
+	if !ok || user.Dead || userID == SystemUserID {
+		return data.User{}, ErrUserNotFound
+	}
+	user.HideTyping = !show
+	return copyUser(user), nil
+}
+
+// stopTyping tells the members of the room that the user stopped typing, the caller must hold the lock
+func (service *ServiceImpl) stopTyping(userID int, roomID int) {
+	timer, typing := service.typing[roomID][userID]
+	if !typing {
+		return
+	}
+	timer.Stop()
+	delete(service.typing[roomID], userID)
+	if len(service.typing[roomID]) == 0 {
+		delete(service.typing, roomID)
+	}
+	if room, ok := service.rooms[roomID]; ok {
+		service.notifyTyping(service.users[userID], room, false)
+	}
+}
+
+// stopTypingEverywhere stops the typing of the user in all its rooms, the caller must hold the lock
+func (service *ServiceImpl) stopTypingEverywhere(userID int) {
+	for roomID := range service.typing {
+		service.stopTyping(userID, roomID)
+	}
+}
+
+// notifyTyping tells the members of the room who did not hide the typing that the user started or stopped typing,
+// the event is dropped rather than delayed when the output of a member is full. The caller must hold the lock
+func (service *ServiceImpl) notifyTyping(user *data.User, room *data.Room, typing bool) {
+	event := data.Event{Type: data.EventTyping, RoomID: room.ID, RoomName: room.Name, UserID: user.ID, UserName: user.Name, Typing: typing}
+	for id := range room.Users {
+		member, ok := service.users[id]
+		if !ok || id == user.ID || id == SystemUserID || member.Dead || member.Bot || member.HideTyping {
+			continue
+		}
+		select {
+		case member.Output <- event:
+		default:
+			droppedSends.Inc(event.Type)
+		}
+	}
+}
@@ -1,26 +1,120 @@
 package data
 
+import "time"
+
+// Room visibility values
+const (
+	VisibilityPublic     = "public"   // listed and open to everyone
+	VisibilityPrivate    = "private"  // hidden from non members and joined by invitation only
+	VisibilityInviteOnly = "invite"   // listed but joined by invitation only
+	VisibilityPassword   = "password" // listed and joined with a password or an invitation
+)
+
+// Event types
+const (
+	EventMessage = "message" // a message published to a room
+	EventInfo    = "info"    // information meant only for the user
+	EventError   = "error"   // a failed command meant only for the user
+
+	EventInvitation   = "invitation"   // the user has been invited to a room
+	EventRoomDeleted  = "roomDeleted"  // a room the user is subscribed to has been deleted
+	EventRoomSwitched = "roomSwitched" // the active room of the user has been changed by the server
+	EventKilled       = "killed"       // an admin disconnected the user, the listener closes the connection
+	EventPresence     = "presence"     // a user sharing a room with the user changed its presence
+	EventTyping       = "typing"       // a member of a room of the user started or stopped typing, it is never stored
+	EventNick         = "nick"         // a user sharing a room with the user changed its name, the old name is the text of the event
+
+	EventJoin        = "join"        // a user joined a room, only delivered to observers
+	EventLeave       = "leave"       // a user left a room, only delivered to observers
+	EventRoomCreated = "roomCreated" // a room has been created, only delivered to observers
+)
+
+// Presence states of the users
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceBusy    = "busy"
+	PresenceOffline = "offline" // the user left, it cannot be chosen
+)
+
 // User is a User Object
 type User struct {
 	ID            int
 	Name          string
 	ActiveRoom    int
-	Output chan   string
+	Output chan   Event
 	Close chan    struct{}
 	Dead          bool
+	Invitations   []int
+	Bot           bool // bots without a connection, e.g. incoming webhooks, do not receive events
+	Admin         bool // server admins can list, inspect and disconnect the users
+	Presence      string
+	StatusText    string    // optional text of the presence, e.g. the reason of an away
+	AutoAway      bool      // the user has been marked away after being idle, any activity brings it back online
+	LastSeen      time.Time // last activity of the user or the time it left
+	HideTyping    bool      // the user is not told when the members of its rooms are typing
+	DisplayName   string
+	Bio           string
+	Timezone      string    // IANA name, e.g. Europe/Paris
+}
+
+// Presence is the presence of a user as returned by the api
+type Presence struct {
+	UserID        int        `json:"userId"`
+	Name          string     `json:"name"`
+	State         string     `json:"state"`
+	Status        string     `json:"status"`
+	LastSeen      string     `json:"lastSeen"` // RFC 3339
+}
+
+// Profile is what a user tells the others about itself
+type Profile struct {
+	UserID        int        `json:"userId"`
+	Name          string     `json:"name"`
+	DisplayName   string     `json:"displayName"`
+	Bio           string     `json:"bio"`
+	Timezone      string     `json:"timezone"`
+	LocalTime     string     `json:"localTime,omitempty"` // RFC 3339 in the timezone of the user, omitted without a timezone
+}
+
+// ProfileUpdate is a request to change the profile of a user, the fields left out are not changed and empty ones are cleared
+type ProfileUpdate struct {
+	UserID        int        `json:"userId"` // user changing the profile in the API, the user itself or an admin
+	DisplayName   *string    `json:"displayName"`
+	Bio           *string    `json:"bio"`
+	Timezone      *string    `json:"timezone"`
 }
 
 // Input is a Input Object
 type Input struct {
 	Room          int
 	Text          string
+	UserName      string // display name replacing the name of the sender when set
 }
 
 // Room is a Room Object
 type Room struct {
-	ID            int
-	Name          string
-	Users         map[int]string
+	ID            int               `json:"id"`
+	Name          string            `json:"name"`
+	Visibility    string            `json:"visibility"`
+	Topic         string            `json:"topic"`
+	Description   string            `json:"description"`
+	CreatorID     int               `json:"creatorId"`
+	CreatedAt     string            `json:"createdAt"`
+	Metadata      map[string]string `json:"metadata"`
+	Welcome       string            `json:"welcome"` // shown to the users when they subscribe
+	Archived      bool              `json:"archived"`
+	Users         map[int]string    `json:"users"`
+	Invited       map[int]bool      `json:"-"`
+	PasswordHash  string            `json:"-"`
+}
+
+// RoomUpdate is a request to rename, archive or restore a room or to change its welcome message
+type RoomUpdate struct {
+	UserID        int        `json:"userId"`
+	Name          *string    `json:"name"`
+	Archived      *bool      `json:"archived"`
+	Welcome       *string    `json:"welcome"`
 }
 
 // Message is a Message Object
@@ -33,3 +127,76 @@ type Message struct {
 	Text          string     `json:"text"`
 	TimeStamp     string     `json:"timestamp"`
 }
+
+// Event is an Event Object delivered to the output of a user
+type Event struct {
+	Type          string
+	Text          string
+	Message       Message
+	RoomID        int
+	RoomName      string
+	UserID        int
+	UserName      string
+	Presence      string // state of the user of a presence event, its status text is the text of the event
+	Typing        bool   // the user of a typing event started typing, false when it stopped
+}
+
+// IncomingWebhook is a secret URL that posts the messages it receives to a room as a bot user
+type IncomingWebhook struct {
+	ID            int        `json:"id"`
+	RoomID        int        `json:"roomId"`
+	Name          string     `json:"name"`
+	UserID        int        `json:"userId"` // the bot user posting the messages
+	CreatorID     int        `json:"creatorId"`
+	CreatedAt     string     `json:"createdAt"`
+	URL           string     `json:"url,omitempty"` // contains the token, only returned when the webhook is created
+	Token         string     `json:"-"`
+}
+
+// WebhookRequest is a request to create an incoming webhook
+type WebhookRequest struct {
+	UserID        int        `json:"userId"`
+	Name          string     `json:"name"`
+}
+
+// WebhookMessage is the payload posted to an incoming webhook
+type WebhookMessage struct {
+	Text          string     `json:"text"`
+	UserName      string     `json:"username"` // optional display name, the name of the webhook when empty
+}
+
+// UserDetails describes a user for the server admins
+type UserDetails struct {
+	ID            int         `json:"id"`
+	Name          string      `json:"name"`
+	Online        bool        `json:"online"`
+	Bot           bool        `json:"bot"`
+	Admin         bool        `json:"admin"`
+	DisplayName   string      `json:"displayName"`
+	Bio           string      `json:"bio"`
+	Timezone      string      `json:"timezone"`
+	LocalTime     string      `json:"localTime,omitempty"`
+	Presence      string      `json:"presence"`
+	Status        string      `json:"status"`
+	LastSeen      string      `json:"lastSeen"`
+	ActiveRoom    string      `json:"activeRoom"`
+	Rooms         []string    `json:"rooms"`
+	Connection    *Connection `json:"connection,omitempty"` // nil when the user is not connected to a listener
+}
+
+// RoomSummary tells a user who logs in what happened in a room it is subscribed to
+type RoomSummary struct {
+	ID            int         `json:"id"`
+	Name          string      `json:"name"`
+	Unread        int         `json:"unread"` // messages since the user of the same name left
+	Online        []string    `json:"online"` // names of the other connected members
+}
+
+// Connection is a user connected to one of the listeners
+type Connection struct {
+	UserID        int        `json:"userId"`
+	UserName      string     `json:"userName"`
+	Transport     string     `json:"transport"` // telnet or irc
+	Address       string     `json:"address"`
+	ConnectedAt   string     `json:"connectedAt"`
+}
@@ -2,5 +2,5 @@ package config
 
 // ConfigurationReader interface to read the configuration
 type ConfigurationReader interface {
-	Read (file string) *Config
+	Read (file string) (*Config, error)
 }
\ No newline at end of file
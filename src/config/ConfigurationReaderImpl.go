@@ -1,9 +1,15 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
-	"os"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
 )
 // ConfigurationReaderImpl struct for configuration reader
 type ConfigurationReaderImpl struct {
@@ -14,15 +20,91 @@ func NewReaderImpl() *ConfigurationReaderImpl {
 	return &ConfigurationReaderImpl{}
 }
 
-// Read reads the configuration from the configuration file
-func (configurationReader *ConfigurationReaderImpl) Read(file string) *Config {
-	var config Config
-	configFile, err := os.Open(file)
-	defer configFile.Close()
+// Read reads the configuration file over the defaults, the format is chosen by the extension: .json, .yaml, .yml or .toml
+func (configurationReader *ConfigurationReaderImpl) Read(file string) (*Config, error) {
+	// YAML and TOML are converted to JSON so every format uses the json names of the settings
+	var convert func(content []byte) ([]byte, error)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+	case ".yaml", ".yml":
+		convert = yamlToJSON
+	case ".toml":
+		convert = tomlToJSON
+	default:
+		return nil, fmt.Errorf("%s: unsupported format, use .json, .yaml, .yml or .toml", file)
+	}
+
+	content, err := ioutil.ReadFile(file)
 	if err != nil {
-		log.Println(err.Error())
+		return nil, err
+	}
+	if convert != nil {
+		if content, err = convert(content); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err.Error())
+		}
+	}
+
+	config := Defaults()
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields() // catches misspelled settings
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return config, nil
+}
+
+// yamlToJSON converts a YAML document to JSON
+func yamlToJSON(content []byte) ([]byte, error) {
+	var document interface{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+	if document == nil { // an empty file keeps the defaults
+		return []byte("{}"), nil
 	}
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
-	return &config
-}
\ No newline at end of file
+	converted, err := stringKeys(document)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(converted)
+}
+
+// stringKeys converts the maps decoded by the YAML parser to maps with string keys which can be encoded to JSON
+func stringKeys(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+			convertedItem, err := stringKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[name] = convertedItem
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, item := range value {
+			convertedItem, err := stringKeys(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = convertedItem
+		}
+		return converted, nil
+	}
+	return value, nil
+}
+
+// tomlToJSON converts a TOML document to JSON
+func tomlToJSON(content []byte) ([]byte, error) {
+	document := map[string]interface{}{}
+	if _, err := toml.Decode(string(content), &document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
 	"path"
 	"testing"
 
@@ -20,6 +22,15 @@ func createReader() ConfigurationReader {
 	return NewReaderImpl()
 }
 
+// writeConfig writes the content to a temporary file with the name and returns its path
+func writeConfig(name string, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	gomega.Expect(err).To(gomega.BeNil())
+	file := path.Join(dir, name)
+	gomega.Expect(ioutil.WriteFile(file, []byte(content), 0644)).To(gomega.Succeed())
+	return file
+}
+
 var _ = ginkgo.Describe("ConfigurationReaderImpl", func() {
 
 	ginkgo.Context("Read", func() {
@@ -27,10 +38,92 @@ var _ = ginkgo.Describe("ConfigurationReaderImpl", func() {
 		ginkgo.It("should read the json config file and return the configuration", func() {
 
 			reader := createReader()
-			cfg := reader.Read(path.Join(testhelpers.GetServerRootDir(), "/resources/config/config.json"))
+			cfg, err := reader.Read(path.Join(testhelpers.GetServerRootDir(), "/resources/config/config.json"))
+			gomega.Expect(err).To(gomega.BeNil())
 			gomega.Expect(cfg.Host).To(gomega.Equal("localhost"))
 			gomega.Expect(cfg.Port).To(gomega.Equal("9080"))
 			gomega.Expect(cfg.ConnectionType).To(gomega.Equal("tcp"))
+			gomega.Expect(cfg.Bots).ToNot(gomega.BeEmpty())
+		})
+
+		ginkgo.It("should read YAML and keep the defaults of the missing settings", func() {
+			file := writeConfig("config.yaml", `
+port: "9090"
+rateLimit:
+  messagesPerSecond: 2.5
+bots:
+  - type: echo
+    enabled: true
+    settings: {rooms: "#Tech"}
+`)
+			defer os.RemoveAll(path.Dir(file))
+			cfg, err := createReader().Read(file)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(cfg.Port).To(gomega.Equal("9090"))
+			gomega.Expect(cfg.Host).To(gomega.Equal("localhost"))
+			gomega.Expect(cfg.APIAddress).To(gomega.Equal(":3000"))
+			gomega.Expect(cfg.RateLimit.MessagesPerSecond).To(gomega.Equal(2.5))
+			gomega.Expect(cfg.RateLimit.MuteDuration).To(gomega.Equal("30s"))
+			gomega.Expect(cfg.Bots).To(gomega.Equal([]BotConfig{{Type: "echo", Enabled: true, Settings: map[string]string{"rooms": "#Tech"}}}))
+		})
+
+		ginkgo.It("should read TOML", func() {
+			file := writeConfig("config.toml", `
+# telnet listener
+port = "9090"
+apiAddress = '127.0.0.1:3001'
+bots = [{type = "dice", enabled = true}]
+
+[connections]
+maxPerIP = 1_000
+deny = [
+  "10.0.0.0/8", # internal
+  "192.168.0.1",
+]
+
+[[webhooks]]
+url = "https://ci.example.com/chat"
+events = ["message"]
+maxRetries = 2
+
+[[webhooks]]
+url = "https://audit.example.com/\u0063hat"
+
+[rateLimit]
+muteAfter = 1
+`)
+			defer os.RemoveAll(path.Dir(file))
+			cfg, err := createReader().Read(file)
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(cfg.Port).To(gomega.Equal("9090"))
+			gomega.Expect(cfg.APIAddress).To(gomega.Equal("127.0.0.1:3001"))
+			gomega.Expect(cfg.Connections.MaxPerIP).To(gomega.Equal(1000))
+			gomega.Expect(cfg.Connections.Deny).To(gomega.Equal([]string{"10.0.0.0/8", "192.168.0.1"}))
+			gomega.Expect(len(cfg.Webhooks)).To(gomega.Equal(2))
+			gomega.Expect(*cfg.Webhooks[0].MaxRetries).To(gomega.Equal(2))
+			gomega.Expect(cfg.Webhooks[1].URL).To(gomega.Equal("https://audit.example.com/chat"))
+			gomega.Expect(cfg.RateLimit.MuteAfter).To(gomega.Equal(1))
+			gomega.Expect(cfg.Bots[0].Type).To(gomega.Equal("dice"))
+		})
+
+		ginkgo.It("should report the file and the line of TOML errors", func() {
+			file := writeConfig("config.toml", "port = \"9090\"\nhost = localhost\n")
+			defer os.RemoveAll(path.Dir(file))
+			_, err := createReader().Read(file)
+			gomega.Expect(err).To(gomega.MatchError(gomega.HavePrefix(file + ": Near line 2 ")))
+		})
+
+		ginkgo.It("should return the errors instead of an empty configuration", func() {
+			_, err := createReader().Read("/does/not/exist.json")
+			gomega.Expect(err).ToNot(gomega.BeNil())
+
+			file := writeConfig("config.json", `{"port": "9090", "prot": "tcp"}`)
+			defer os.RemoveAll(path.Dir(file))
+			_, err = createReader().Read(file)
+			gomega.Expect(err).To(gomega.MatchError(file + `: json: unknown field "prot"`))
+
+			_, err = createReader().Read(path.Join(path.Dir(file), "config.ini"))
+			gomega.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("unsupported format")))
 		})
 	})
 })
\ No newline at end of file
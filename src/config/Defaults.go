@@ -0,0 +1,22 @@
+package config
+
+// Defaults returns the settings used when neither the config file, the environment nor the flags set them
+func Defaults() *Config {
+	return &Config{
+		Host:                  "localhost",
+		Port:                  "9080",
+		ConnectionType:        "tcp",
+		LogFilePath:           "/logs/messages.log",
+		APIAddress:            ":3000",
+		WebhookDeadLetterPath: "/logs/webhooks-dead-letter.log",
+		ShutdownTimeout:       "10s",
+		AwayAfter:             "10m",
+		RateLimit: RateLimitConfig{
+			MuteDuration: "30s",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import "flag"
+
+// EnvConfigFile is the environment variable naming the config file
+const EnvConfigFile = "CHAT_CONFIG"
+
+// setting is a top level setting that can be set with an environment variable and a command line flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	value func(config *Config) *string
+}
+
+// settings lists the settings that the environment and the flags can override
+var settings = []setting{
+	{"CHAT_HOST", "host", "host of the telnet and IRC listeners", func(config *Config) *string { return &config.Host }},
+	{"CHAT_PORT", "port", "port of the telnet listener", func(config *Config) *string { return &config.Port }},
+	{"CHAT_CONNECTION_TYPE", "connection-type", "tcp, tcp4 or tcp6", func(config *Config) *string { return &config.ConnectionType }},
+	{"CHAT_IRC_PORT", "irc-port", "port of the IRC listener, empty disables it", func(config *Config) *string { return &config.IRCPort }},
+	{"CHAT_API_ADDRESS", "api-address", "host:port of the REST API", func(config *Config) *string { return &config.APIAddress }},
+	{"CHAT_LOG_FILE_PATH", "log-file", "message log, relative to the server root", func(config *Config) *string { return &config.LogFilePath }},
+	{"CHAT_WEBHOOK_DEAD_LETTER_PATH", "webhook-dead-letters", "failed webhook deliveries, relative to the server root", func(config *Config) *string { return &config.WebhookDeadLetterPath }},
+	{"CHAT_SHUTDOWN_TIMEOUT", "shutdown-timeout", "time given to the clients when stopping", func(config *Config) *string { return &config.ShutdownTimeout }},
+	{"CHAT_LOG_LEVEL", "log-level", "debug, info, warn or error", func(config *Config) *string { return &config.Log.Level }},
+	{"CHAT_LOG_FORMAT", "log-format", "text or json", func(config *Config) *string { return &config.Log.Format }},
+	{"CHAT_AWAY_AFTER", "away-after", "idle time after which the users are marked away, empty never marks them", func(config *Config) *string { return &config.AwayAfter }},
+	{"CHAT_MOTD_PATH", "motd", "message of the day shown at login, relative to the server root, empty disables it", func(config *Config) *string { return &config.MOTDPath }},
+	{"CHAT_ADMIN_TOKEN", "admin-token", "bearer token of the admin endpoints, empty disables them", func(config *Config) *string { return &config.AdminToken }},
+}
+
+// Loader layers the configuration: the defaults, the config file, the environment variables and the command line flags
+type Loader struct {
+	reader      ConfigurationReader
+	defaultFile string
+	args        []string
+	lookupEnv   func(key string) (string, bool)
+	file        string
+}
+
+// NewLoader returns a Loader for the command line arguments without the program name, lookupEnv is usually os.LookupEnv
+func NewLoader(reader ConfigurationReader, defaultFile string, args []string, lookupEnv func(key string) (string, bool)) *Loader {
+	return &Loader{
+		reader:      reader,
+		defaultFile: defaultFile,
+		args:        args,
+		lookupEnv:   lookupEnv,
+		file:        defaultFile,
+	}
+}
+
+// Load reads and validates the configuration, flag.ErrHelp is returned when the usage has been requested
+func (loader *Loader) Load() (*Config, error) {
+	flags := flag.NewFlagSet("chatserver", flag.ContinueOnError)
+	file := flags.String("config", "", "config file in JSON, YAML or TOML, also set with "+EnvConfigFile)
+	values := make([]*string, len(settings))
+	for i, s := range settings {
+		values[i] = flags.String(s.flag, "", s.usage+", also set with "+s.env)
+	}
+	if err := flags.Parse(loader.args); err != nil {
+		return nil, err
+	}
+
+	loader.file = loader.defaultFile
+	if env, found := loader.lookupEnv(EnvConfigFile); found && env != "" {
+		loader.file = env
+	}
+	if *file != "" {
+		loader.file = *file
+	}
+	config, err := loader.reader.Read(loader.file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range settings {
+		if env, found := loader.lookupEnv(s.env); found {
+			*s.value(config) = env
+		}
+	}
+	flags.Visit(func(set *flag.Flag) { // only the flags given on the command line override the settings
+		for i, s := range settings {
+			if s.flag == set.Name {
+				*s.value(config) = *values[i]
+			}
+		}
+	})
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// File returns the config file read by the last Load
+func (loader *Loader) File() string {
+	return loader.file
+}
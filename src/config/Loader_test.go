@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// environment returns a lookup function for a fixed environment
+func environment(variables map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, found := variables[key]
+		return value, found
+	}
+}
+
+var _ = ginkgo.Describe("Loader", func() {
+
+	ginkgo.Context("Load", func() {
+
+		ginkgo.It("should layer the file, the environment and the flags", func() {
+			file := writeConfig("config.json", `{"port": "9001", "host": "file", "ircPort": "6667"}`)
+			defer os.RemoveAll(path.Dir(file))
+			env := environment(map[string]string{"CHAT_PORT": "9002", "CHAT_HOST": "env", "CHAT_LOG_FORMAT": "json"})
+			loader := NewLoader(NewReaderImpl(), file, []string{"-port", "9003", "-irc-port=", "-log-level", "debug"}, env)
+			cfg, err := loader.Load()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(cfg.Port).To(gomega.Equal("9003"))
+			gomega.Expect(cfg.Host).To(gomega.Equal("env"))
+			gomega.Expect(cfg.IRCPort).To(gomega.Equal("")) // an empty flag disables the IRC listener
+			gomega.Expect(cfg.APIAddress).To(gomega.Equal(":3000"))
+			gomega.Expect(cfg.Log).To(gomega.Equal(LogConfig{Level: "debug", Format: "json"}))
+		})
+
+		ginkgo.It("should read the file named by the flag or the environment", func() {
+			file := writeConfig("config.yml", "port: \"9004\"\n")
+			defer os.RemoveAll(path.Dir(file))
+			loader := NewLoader(NewReaderImpl(), "/does/not/exist.json", []string{}, environment(map[string]string{EnvConfigFile: file}))
+			cfg, err := loader.Load()
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(cfg.Port).To(gomega.Equal("9004"))
+			gomega.Expect(loader.File()).To(gomega.Equal(file))
+
+			loader = NewLoader(NewReaderImpl(), "/does/not/exist.json", []string{"-config", file}, environment(nil))
+			_, err = loader.Load()
+			gomega.Expect(err).To(gomega.BeNil())
+		})
+
+		ginkgo.It("should validate the layered configuration", func() {
+			file := writeConfig("config.json", `{"port": "9001"}`)
+			defer os.RemoveAll(path.Dir(file))
+			loader := NewLoader(NewReaderImpl(), file, []string{"-api-address", "3000"}, environment(map[string]string{"CHAT_IRC_PORT": "9001"}))
+			_, err := loader.Load()
+			gomega.Expect(err).To(gomega.MatchError(`invalid configuration: ircPort "9001" is already used by the telnet listener; apiAddress "3000" must be host:port, e.g. :3000`))
+		})
+
+	})
+
+	ginkgo.Context("Validate", func() {
+
+		ginkgo.It("should accept the defaults", func() {
+			gomega.Expect(Defaults().Validate()).To(gomega.Succeed())
+		})
+
+		ginkgo.It("should describe every invalid setting", func() {
+			cfg := Defaults()
+			retries := -1
+			cfg.Port = "http"
+			cfg.ShutdownTimeout = "soon"
+			cfg.AwayAfter = "-1m"
+			cfg.Log.Format = "xml"
+			cfg.RateLimit.MessageBurst = -1
+			cfg.RateLimit.MuteDuration = "0s"
+			cfg.Connections.Allow = []string{"10.0.0.0/33"}
+			cfg.Admins = map[string]string{"ops": ""}
+			cfg.Bots = []BotConfig{{Name: "nameless"}}
+			cfg.Webhooks = []WebhookConfig{{URL: "ftp://example.com", Events: []string{"typing"}, MaxRetries: &retries}}
+			err := cfg.Validate()
+			gomega.Expect(err).To(gomega.BeAssignableToTypeOf(&ValidationError{}))
+			gomega.Expect(err.(*ValidationError).Problems).To(gomega.Equal([]string{
+				`port "http" must be a number between 1 and 65535`,
+				`shutdownTimeout "soon" must be a duration, e.g. 10s`,
+				`awayAfter "-1m" must be a duration, e.g. 10m`,
+				`log format "xml" must be text or json`,
+				`rateLimit messagesPerSecond and messageBurst cannot be negative`,
+				`rateLimit muteDuration "0s" must be a positive duration, e.g. 30s`,
+				`connections "10.0.0.0/33" is not an IP or a CIDR`,
+				`admins "ops" password is required`,
+				`bots[0] type is required`,
+				`webhooks[0] url "ftp://example.com" must be an http or https URL`,
+				`webhooks[0] event "typing" must be message, join, leave or roomCreated`,
+				`webhooks[0] maxRetries cannot be negative`,
+			}))
+		})
+	})
+})
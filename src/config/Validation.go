@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every invalid setting of a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error joins the problems into a single message
+func (err *ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(err.Problems, "; ")
+}
+
+// webhookEvents are the events an outgoing webhook can subscribe to
+var webhookEvents = map[string]bool{"message": true, "join": true, "leave": true, "roomCreated": true}
+
+// logLevels and logFormats are the accepted log settings
+var (
+	logLevels  = map[string]bool{"": true, "debug": true, "info": true, "warn": true, "warning": true, "error": true}
+	logFormats = map[string]bool{"": true, "text": true, "json": true}
+)
+
+// Validate checks the settings, it returns a ValidationError describing every invalid setting
+func (config *Config) Validate() error {
+	problems := []string{}
+	check := func(valid bool, format string, args ...interface{}) {
+		if !valid {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(isPort(config.Port), "port %q must be a number between 1 and 65535", config.Port)
+	check(config.ConnectionType == "tcp" || config.ConnectionType == "tcp4" || config.ConnectionType == "tcp6",
+		"connectionType %q must be tcp, tcp4 or tcp6", config.ConnectionType)
+	check(config.LogFilePath != "", "logFilePath is required")
+	if config.IRCPort != "" {
+		check(isPort(config.IRCPort), "ircPort %q must be a number between 1 and 65535", config.IRCPort)
+		check(config.IRCPort != config.Port, "ircPort %q is already used by the telnet listener", config.IRCPort)
+	}
+	_, apiPort, err := net.SplitHostPort(config.APIAddress)
+	check(err == nil && isPort(apiPort), "apiAddress %q must be host:port, e.g. :3000", config.APIAddress)
+	check(isDuration(config.ShutdownTimeout), "shutdownTimeout %q must be a duration, e.g. 10s", config.ShutdownTimeout)
+	check(isDuration(config.AwayAfter), "awayAfter %q must be a duration, e.g. 10m", config.AwayAfter)
+
+	check(logLevels[strings.ToLower(config.Log.Level)], "log level %q must be debug, info, warn or error", config.Log.Level)
+	check(logFormats[strings.ToLower(config.Log.Format)], "log format %q must be text or json", config.Log.Format)
+
+	limits := config.RateLimit
+	check(limits.MessagesPerSecond >= 0 && limits.MessageBurst >= 0, "rateLimit messagesPerSecond and messageBurst cannot be negative")
+	check(limits.IPMessagesPerSecond >= 0 && limits.IPMessageBurst >= 0, "rateLimit ipMessagesPerSecond and ipMessageBurst cannot be negative")
+	check(limits.APIRequestsPerSecond >= 0 && limits.APIBurst >= 0, "rateLimit apiRequestsPerSecond and apiBurst cannot be negative")
+	check(limits.ConnectionsPerSecond >= 0 && limits.ConnectionBurst >= 0, "rateLimit connectionsPerSecond and connectionBurst cannot be negative")
+	check(limits.MuteAfter >= 0, "rateLimit muteAfter cannot be negative")
+	check(isDuration(limits.MuteDuration) && !isZeroDuration(limits.MuteDuration),
+		"rateLimit muteDuration %q must be a positive duration, e.g. 30s", limits.MuteDuration)
+
+	connections := config.Connections
+	check(connections.MaxConnections >= 0, "connections maxConnections cannot be negative")
+	check(connections.MaxPerIP >= 0, "connections maxPerIP cannot be negative")
+	for _, address := range append(append([]string{}, connections.Allow...), connections.Deny...) {
+		check(isAddress(address), "connections %q is not an IP or a CIDR", address)
+	}
+
+	for name, password := range config.Admins {
+		check(strings.TrimSpace(name) != "", "admins cannot have an empty name")
+		check(password != "", "admins %q password is required", name)
+	}
+
+	for i, bot := range config.Bots {
+		check(bot.Type != "", "bots[%d] type is required", i)
+	}
+
+	for i, hook := range config.Webhooks {
+		target, err := url.Parse(hook.URL)
+		check(err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "",
+			"webhooks[%d] url %q must be an http or https URL", i, hook.URL)
+		for _, event := range hook.Events {
+			check(webhookEvents[event], "webhooks[%d] event %q must be message, join, leave or roomCreated", i, event)
+		}
+		check(hook.MaxRetries == nil || *hook.MaxRetries >= 0, "webhooks[%d] maxRetries cannot be negative", i)
+		check(isDuration(hook.Backoff), "webhooks[%d] backoff %q must be a duration, e.g. 1s", i, hook.Backoff)
+		check(isDuration(hook.Timeout), "webhooks[%d] timeout %q must be a duration, e.g. 5s", i, hook.Timeout)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// isPort checks if the text is a TCP port
+func isPort(text string) bool {
+	port, err := strconv.Atoi(text)
+	return err == nil && port > 0 && port <= 65535
+}
+
+// isDuration checks if the text is empty or a duration which is not negative
+func isDuration(text string) bool {
+	if text == "" {
+		return true
+	}
+	duration, err := time.ParseDuration(text)
+	return err == nil && duration >= 0
+}
+
+// isZeroDuration checks if the text is a duration of zero, e.g. 0s
+func isZeroDuration(text string) bool {
+	duration, err := time.ParseDuration(text)
+	return err == nil && duration == 0
+}
+
+// isAddress checks if the text is an IP or a CIDR
+func isAddress(text string) bool {
+	if _, _, err := net.ParseCIDR(text); err == nil {
+		return true
+	}
+	return net.ParseIP(text) != nil
+}
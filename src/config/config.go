@@ -2,8 +2,68 @@ package config
 
 // Config struct contains the basic configuration settings
 type Config struct {
-	Host                 string      `json:"host"`
-	Port                 string      `json:"port"`
-	ConnectionType       string      `json:"connectionType"`
-	LogFilePath          string      `json:"logFilePath"`
-}
\ No newline at end of file
+	Host                  string            `json:"host"`
+	Port                  string            `json:"port"`
+	ConnectionType        string            `json:"connectionType"`
+	LogFilePath           string            `json:"logFilePath"`
+	IRCPort               string            `json:"ircPort"`    // the IRC listener is disabled when the port is empty
+	APIAddress            string            `json:"apiAddress"` // host:port of the REST API, e.g. ":3000"
+	Bots                  []BotConfig       `json:"bots"`
+	Webhooks              []WebhookConfig   `json:"webhooks"`
+	WebhookDeadLetterPath string            `json:"webhookDeadLetterPath"` // failed deliveries are appended to this file
+	RateLimit             RateLimitConfig   `json:"rateLimit"`
+	Connections           ConnectionsConfig `json:"connections"`
+	ShutdownTimeout       string            `json:"shutdownTimeout"` // time given to the clients and the api requests when stopping, 10s when empty
+	AdminToken            string            `json:"adminToken"`      // bearer token of the admin endpoints, they are disabled when empty
+	Log                   LogConfig         `json:"log"`
+	Admins                map[string]string `json:"admins"`   // names of the users that can become admins with /oper and their passwords
+	MOTDPath              string            `json:"motdPath"`  // message of the day shown at login, relative to the server root, none when empty
+	AwayAfter             string            `json:"awayAfter"` // idle time after which the users are marked away, never when empty
+}
+
+// LogConfig configures the server log
+type LogConfig struct {
+	Level  string `json:"level"`  // debug, info, warn or error, info when empty
+	Format string `json:"format"` // text or json, text when empty
+}
+
+// BotConfig configures a bot that runs inside the chat server
+type BotConfig struct {
+	Type     string            `json:"type"` // echo, dice, reminder or a type registered by a plugin
+	Name     string            `json:"name"` // name of the bot user, the type when empty
+	Enabled  bool              `json:"enabled"`
+	Settings map[string]string `json:"settings"`
+}
+
+// WebhookConfig configures an outgoing webhook
+type WebhookConfig struct {
+	URL        string   `json:"url"`
+	Room       string   `json:"room"`       // name or id of the room, every room when empty
+	Events     []string `json:"events"`     // message, join, leave or roomCreated, every event when empty
+	Secret     string   `json:"secret"`     // signs the payloads with HMAC-SHA256 when set
+	MaxRetries *int     `json:"maxRetries"` // 3 when not set
+	Backoff    string   `json:"backoff"`    // delay before the first retry, doubled for each retry, 1s when empty
+	Timeout    string   `json:"timeout"`    // timeout of a single attempt, 5s when empty
+}
+
+// RateLimitConfig configures the token buckets limiting the clients, a rate that is not positive disables the limit
+type RateLimitConfig struct {
+	MessagesPerSecond    float64 `json:"messagesPerSecond"` // per user, for telnet and IRC lines and API messages
+	MessageBurst         int     `json:"messageBurst"`
+	IPMessagesPerSecond  float64 `json:"ipMessagesPerSecond"` // per IP, shared by all the users of the IP
+	IPMessageBurst       int     `json:"ipMessageBurst"`
+	MuteAfter            int     `json:"muteAfter"`            // violations within a minute that mute the user, 0 never mutes
+	MuteDuration         string  `json:"muteDuration"`         // 30s when empty
+	APIRequestsPerSecond float64 `json:"apiRequestsPerSecond"` // per IP
+	APIBurst             int     `json:"apiBurst"`
+	ConnectionsPerSecond float64 `json:"connectionsPerSecond"` // new telnet and IRC connections per IP
+	ConnectionBurst      int     `json:"connectionBurst"`
+}
+
+// ConnectionsConfig configures which telnet and IRC connections are accepted
+type ConnectionsConfig struct {
+	MaxConnections int      `json:"maxConnections"` // open connections of all the listeners, 0 is unlimited
+	MaxPerIP       int      `json:"maxPerIP"`       // open connections per IP, 0 is unlimited
+	Allow          []string `json:"allow"`          // IPs or CIDRs, only these can connect when the list is not empty
+	Deny           []string `json:"deny"`           // IPs or CIDRs that cannot connect
+}
@@ -0,0 +1,80 @@
+package connections
+
+import (
+	"strconv"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+)
+
+// registerAdminCommands registers /oper and the commands of the server admins
+func (service *ServiceImpl) registerAdminCommands() {
+	user := Argument{Name: "@user"}
+	commands := []Command{
+		{Name: "oper", Args: []Argument{{Name: "password"}},
+			Help: "becomes a server admin, the name must be configured as admin", Handler: service.oper},
+		{Name: "who", Permission: PermissionAdmin,
+			Help: "lists the connected users", Handler: service.who},
+		{Name: "kill", Args: []Argument{user, {Name: "reason", Optional: true, Rest: true}}, Permission: PermissionAdmin,
+			Help: "disconnects a user", Handler: service.kill},
+		{Name: "broadcast", Args: []Argument{{Name: "text", Rest: true}}, Permission: PermissionAdmin,
+			Help: "sends a system message to every room", Handler: service.broadcast},
+		{Name: "stats", Permission: PermissionAdmin,
+			Help: "shows the state of the server", Handler: service.stats},
+	}
+	for _, command := range commands {
+		service.commands.Register(command)
+	}
+}
+
+// oper gives the admin role to the user when the password is the one configured for its name
+func (service *ServiceImpl) oper(ctx *CommandContext) {
+	if _, err := service.chatService.Oper(ctx.User.ID, ctx.Arg(0)); err != nil {
+		ctx.session.logger.Warn("Failed admin login")
+		ctx.Error(err.Error() + "!!!\n")
+		return
+	}
+	ctx.session.logger.Info("The user became an admin")
+	ctx.Reply("You are now a server admin, type /help to list the admin commands!!\n")
+}
+
+// who lists the users connected to every listener
+func (service *ServiceImpl) who(ctx *CommandContext) {
+	ctx.Reply(formatWho(service.monitor.Who()))
+}
+
+// kill disconnects a user, the reason is shown to the user
+func (service *ServiceImpl) kill(ctx *CommandContext) {
+	user, found := service.resolveUser(ctx)
+	if !found {
+		return
+	}
+	if err := service.chatService.Disconnect(user.ID, ctx.Arg(1)); err != nil {
+		ctx.Error("Cannot disconnect @" + user.Name + ": " + err.Error() + "!!!\n")
+		return
+	}
+	ctx.session.logger.Warn("Disconnected a user", "target", user.Name, "targetId", user.ID, "reason", ctx.Arg(1))
+	ctx.Reply("Disconnected @" + user.Name + "!!\n")
+}
+
+// broadcast sends a system message to every room
+func (service *ServiceImpl) broadcast(ctx *CommandContext) {
+	messages := service.chatService.Broadcast(ctx.Arg(0))
+	ctx.session.logger.Info("Broadcast a message", "rooms", len(messages))
+	ctx.Reply("Broadcast to " + strconv.Itoa(len(messages)) + " rooms!!\n")
+}
+
+// stats shows the state of the server
+func (service *ServiceImpl) stats(ctx *CommandContext) {
+	ctx.Reply(formatStats(service.monitor.Status()))
+}
+
+// resolveUser finds the user named by the first argument and tells the user who ran the command when it is not found
+func (service *ServiceImpl) resolveUser(ctx *CommandContext) (data.User, bool) {
+	user, found := service.chatService.FindUser(ctx.Arg(0))
+	if !found {
+		ctx.Error("User " + ctx.Arg(0) + " not found!!" +
+			chatserver.FormatSuggestions(service.chatService.SuggestUsers(ctx.Arg(0)), "@") + "\n")
+	}
+	return user, found
+}
@@ -0,0 +1,467 @@
+package connections
+
+import (
+	"strconv"
+	"strings"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+)
+
+// registerCommands registers the commands that are built into the server
+func (service *ServiceImpl) registerCommands() {
+	room := Argument{Name: "#room"}
+	password := Argument{Name: "password", Optional: true}
+	commands := []Command{
+		{Name: "help", Args: []Argument{{Name: "command", Optional: true}},
+			Help: "lists all the available commands or shows the details of a command", Handler: service.help},
+		{Name: "proto", Args: []Argument{{Name: "text|json", Optional: true}},
+			Help: "shows or switches the protocol, json writes every event as a JSON line and accepts JSON commands", Handler: service.proto},
+		{Name: "rooms", Aliases: []string{"list"},
+			Help: "lists all the available rooms", Handler: service.rooms},
+		{Name: "createroom", Args: []Argument{{Name: "roomName"}, {Name: "visibility", Optional: true}, password},
+			Help: "creates new room, visibility is public, private, invite or password", Handler: service.createRoom},
+		{Name: "subscribe", Aliases: []string{"sub"}, Args: []Argument{room, password},
+			Help: "subscribes to a room", Handler: service.subscribe},
+		{Name: "join", Aliases: []string{"j"}, Args: []Argument{room, password},
+			Help: "subscribes to a room if needed and switches to it", Handler: service.join},
+		{Name: "invite", Args: []Argument{{Name: "@userName"}, room},
+			Help: "invites a user to a room", Handler: service.invite},
+		{Name: "accept", Args: []Argument{{Name: "#room", Optional: true}},
+			Help: "accepts an invitation, the latest one when no room is given", Handler: service.accept},
+		{Name: "unsubscribe", Aliases: []string{"unsub", "leave"}, Args: []Argument{room},
+			Help: "unsubscribes from a room", Handler: service.unsubscribe},
+		{Name: "switch", Args: []Argument{room},
+			Help: "switches to a room", Handler: service.switchRoom},
+		{Name: "topic", Args: []Argument{{Name: "topic", Optional: true, Rest: true}},
+			Help: "shows or changes the topic of the active room", Handler: service.topic},
+		{Name: "describe", Args: []Argument{{Name: "description", Rest: true}},
+			Help: "changes the description of the active room", Handler: service.describe},
+		{Name: "welcome", Args: []Argument{{Name: "message", Optional: true, Rest: true}},
+			Help: "shows or changes the message shown to the users who subscribe to the active room", Handler: service.welcome},
+		{Name: "motd",
+			Help: "shows the message of the day", Handler: service.motd},
+		{Name: "meta", Args: []Argument{{Name: "key"}, {Name: "value", Optional: true, Rest: true}},
+			Help: "sets or removes a metadata key of the active room", Handler: service.meta},
+		{Name: "renameroom", Args: []Argument{room, {Name: "newName"}},
+			Help: "renames a room you created", Handler: service.renameRoom},
+		{Name: "archive", Args: []Argument{room},
+			Help: "archives a room you created, it becomes read only", Handler: service.archive},
+		{Name: "unarchive", Args: []Argument{room},
+			Help: "restores an archived room", Handler: service.unarchive},
+		{Name: "deleteroom", Args: []Argument{room},
+			Help: "deletes a room you created", Handler: service.deleteRoom},
+		{Name: "away", Args: []Argument{{Name: "message", Optional: true, Rest: true}},
+			Help: "marks you away for the members of your rooms", Handler: service.away},
+		{Name: "busy", Args: []Argument{{Name: "message", Optional: true, Rest: true}},
+			Help: "marks you busy for the members of your rooms", Handler: service.busy},
+		{Name: "back",
+			Help: "marks you online again", Handler: service.back},
+		{Name: "typing", Args: []Argument{{Name: "on|off", Optional: true}, {Name: "#room", Optional: true}}, Ephemeral: true,
+			Help: "shows the members of the active room or a room that you are typing, without on or off it toggles, it stops by itself after a few seconds", Handler: service.typing},
+		{Name: "typingnotices", Args: []Argument{{Name: "on|off", Optional: true}},
+			Help: "shows or changes whether you are told when the members of your rooms are typing", Handler: service.typingNotices},
+		{Name: "nick", Args: []Argument{{Name: "newName"}},
+			Help: "changes your name, it must not be used by another connected user", Handler: service.nick},
+		{Name: "profile", Args: []Argument{{Name: "displayname|bio|timezone", Optional: true}, {Name: "value", Optional: true, Rest: true}},
+			Help: "shows your profile or changes a field of it, a field without a value is cleared", Handler: service.profile},
+		{Name: "whois", Args: []Argument{{Name: "@user"}},
+			Help: "shows the profile and the presence of a user, admins also see its connection and rooms", Handler: service.whois},
+		{Name: "activeroom",
+			Help: "displays the active room of a user", Handler: service.activeRoom},
+		{Name: "quit", Aliases: []string{"exit"},
+			Help: "closes the connection", Handler: service.quit},
+	}
+	for _, command := range commands {
+		service.commands.Register(command)
+	}
+}
+
+// help lists the commands the user can run or shows the details of a command
+func (service *ServiceImpl) help(ctx *CommandContext) {
+	if ctx.Arg(0) == "" {
+		service.showCommands(ctx.session)
+		return
+	}
+	command, found := service.commands.Find(ctx.Arg(0))
+	if !found || !service.hasPermission(ctx.session, command.Permission) {
+		ctx.Error("Unknown Command " + ctx.Arg(0) + "!!!\n")
+		return
+	}
+	details := "Usage: " + command.Usage() + "\n"
+	if len(command.Aliases) > 0 {
+		details = details + "Aliases: /" + strings.Join(command.Aliases, ", /") + "\n"
+	}
+	if command.Permission != "" {
+		details = details + "Requires: " + command.Permission + "\n"
+	}
+	ctx.Reply(details + command.Help + "\n")
+}
+
+// proto shows or switches the protocol of the connection
+func (service *ServiceImpl) proto(ctx *CommandContext) {
+	s := ctx.session
+	switch protocol := ctx.Arg(0); protocol {
+	case "":
+		ctx.Reply("Protocol is " + s.protocol + "!!\n")
+	case protocolText, protocolJSON:
+		// the writer switches only after it has written the events sent before
+		s.protocol = protocol
+		ctx.User.Output <- data.Event{Type: eventProtocol, Text: protocol}
+		ctx.Reply("Switched to " + protocol + " protocol!!\n")
+	default:
+		ctx.Error("Protocol must be text or json!!!\n")
+	}
+}
+
+// rooms lists the rooms visible to the user
+func (service *ServiceImpl) rooms(ctx *CommandContext) {
+	rooms := service.chatService.GetVisibleRooms(ctx.User.ID)
+	creators := make(map[int]string)
+	for _, room := range rooms {
+		if _, found := creators[room.CreatorID]; !found {
+			creators[room.CreatorID] = service.creatorOf(room)
+		}
+	}
+	ctx.Reply(formatRooms(rooms, creators))
+}
+
+// createRoom creates a new room
+func (service *ServiceImpl) createRoom(ctx *CommandContext) {
+	roomName := strings.TrimPrefix(ctx.Arg(0), "#")
+	room, err := service.chatService.CreateRoom(roomName, ctx.User.ID, ctx.Arg(1), ctx.Arg(2))
+	sendResult(ctx.User, "Room "+room.Name+" created!!\n", err, roomName)
+}
+
+// subscribe subscribes the user to a room
+func (service *ServiceImpl) subscribe(ctx *CommandContext) {
+	if room, ok := service.resolveRoom(ctx.User, ctx.Arg(0)); ok {
+		subscribed, err := service.chatService.Subscribe(ctx.User.ID, room.ID, ctx.Arg(1))
+		sendResult(ctx.User, "Subscribed to "+room.Name+"!!\n"+formatWelcome(subscribed), err, room.Name)
+	}
+}
+
+// join subscribes the user to the room when needed and switches to it
+func (service *ServiceImpl) join(ctx *CommandContext) {
+	room, ok := service.resolveRoom(ctx.User, ctx.Arg(0))
+	if !ok {
+		return
+	}
+	if _, member := room.Users[ctx.User.ID]; !member {
+		subscribed, err := service.chatService.Subscribe(ctx.User.ID, room.ID, ctx.Arg(1))
+		if err != nil {
+			ctx.Error(formatError(err, room.Name))
+			return
+		}
+		if welcome := formatWelcome(subscribed); welcome != "" {
+			ctx.Reply(welcome)
+		}
+	}
+	service.sendSwitch(ctx.User, room)
+}
+
+// invite invites a user to a room, suggesting names when the user is not found
+func (service *ServiceImpl) invite(ctx *CommandContext) {
+	room, ok := service.resolveRoom(ctx.User, ctx.Arg(1))
+	if !ok {
+		return
+	}
+	inviteeName := strings.TrimPrefix(ctx.Arg(0), "@")
+	_, err := service.chatService.Invite(ctx.User.ID, inviteeName, room.ID)
+	switch err {
+	case chatserver.ErrUserNotFound:
+		ctx.Error("User " + inviteeName + " not found!!" +
+			chatserver.FormatSuggestions(service.chatService.SuggestUsers(inviteeName), "@") + "\n")
+	case chatserver.ErrAlreadySubscribed:
+		ctx.Error(inviteeName + " is already subscribed to " + room.Name + "!!\n")
+	case chatserver.ErrAlreadyInvited:
+		ctx.Error(inviteeName + " is already invited to " + room.Name + "!!\n")
+	default:
+		sendResult(ctx.User, "Invited "+inviteeName+" to "+room.Name+"!!\n", err, room.Name)
+	}
+}
+
+// accept accepts an invitation, the most recent one when no room is given
+func (service *ServiceImpl) accept(ctx *CommandContext) {
+	var roomID int
+	if ctx.Arg(0) != "" {
+		room, ok := service.resolveRoom(ctx.User, ctx.Arg(0))
+		if !ok {
+			return
+		}
+		roomID = room.ID
+	} else {
+		user, _ := service.chatService.GetUser(ctx.User.ID)
+		if len(user.Invitations) == 0 {
+			ctx.Error("No pending invitations!!\n")
+			return
+		}
+		roomID = user.Invitations[len(user.Invitations)-1]
+	}
+	room, err := service.chatService.AcceptInvite(ctx.User.ID, roomID)
+	if err != nil {
+		room, _ = service.chatService.GetRoom(roomID)
+	}
+	sendResult(ctx.User, "Subscribed to "+room.Name+"!!\n"+formatWelcome(room), err, room.Name)
+}
+
+// unsubscribe unsubscribes the user from a room
+func (service *ServiceImpl) unsubscribe(ctx *CommandContext) {
+	if room, ok := service.resolveRoom(ctx.User, ctx.Arg(0)); ok {
+		_, err := service.chatService.UnSubscribe(ctx.User.ID, room.ID)
+		sendResult(ctx.User, "Unsubscribed "+room.Name+"!!\n", err, room.Name)
+	}
+}
+
+// switchRoom switches the active room of the user
+func (service *ServiceImpl) switchRoom(ctx *CommandContext) {
+	if room, ok := service.resolveRoom(ctx.User, ctx.Arg(0)); ok {
+		service.sendSwitch(ctx.User, room)
+	}
+}
+
+// topic shows or changes the topic of the active room
+func (service *ServiceImpl) topic(ctx *CommandContext) {
+	room := service.activeRoomOf(ctx.User)
+	if ctx.Arg(0) == "" {
+		ctx.Reply("Topic of " + room.Name + ": " + room.Topic + "\n")
+		return
+	}
+	_, err := service.chatService.SetTopic(ctx.User.ID, room.ID, ctx.Arg(0))
+	sendResult(ctx.User, "", err, room.Name)
+}
+
+// welcome shows or changes the welcome message of the active room
+func (service *ServiceImpl) welcome(ctx *CommandContext) {
+	room := service.activeRoomOf(ctx.User)
+	if ctx.Arg(0) == "" {
+		ctx.Reply("Welcome message of " + room.Name + ": " + room.Welcome + "\n")
+		return
+	}
+	_, err := service.chatService.SetWelcome(ctx.User.ID, room.ID, ctx.Arg(0))
+	sendResult(ctx.User, "Welcome message of "+room.Name+" updated!!\n", err, room.Name)
+}
+
+// motd shows the message of the day
+func (service *ServiceImpl) motd(ctx *CommandContext) {
+	motd := service.chatService.GetMOTD()
+	if motd == "" {
+		ctx.Reply("There is no message of the day!!\n")
+		return
+	}
+	ctx.Reply(motd + "\n")
+}
+
+// away marks the user away with an optional message
+func (service *ServiceImpl) away(ctx *CommandContext) {
+	service.setPresence(ctx, data.PresenceAway, "You are now away!!\n")
+}
+
+// busy marks the user busy with an optional message
+func (service *ServiceImpl) busy(ctx *CommandContext) {
+	service.setPresence(ctx, data.PresenceBusy, "You are now busy!!\n")
+}
+
+// back marks the user online
+func (service *ServiceImpl) back(ctx *CommandContext) {
+	service.setPresence(ctx, data.PresenceOnline, "You are back online!!\n")
+}
+
+// setPresence changes the presence of the user, the first argument is the status text
+func (service *ServiceImpl) setPresence(ctx *CommandContext, state string, info string) {
+	_, err := service.chatService.SetPresence(ctx.User.ID, state, ctx.Arg(0))
+	sendResult(ctx.User, info, err, "")
+}
+
+// typing starts, stops or toggles the typing of the user in the active room or the given room,
+// clients in the JSON protocol send it on every key stroke so only the text protocol gets a reply
+func (service *ServiceImpl) typing(ctx *CommandContext) {
+	state, reference := ctx.Arg(0), ctx.Arg(1)
+	if state != "on" && state != "off" && state != "" {
+		if reference != "" {
+			ctx.Error("Options missing!!! Usage: /typing [on|off] [#room]\n")
+			return
+		}
+		state, reference = "", state
+	}
+	room := service.activeRoomOf(ctx.User)
+	if reference != "" {
+		var found bool
+		if room, found = service.resolveRoom(ctx.User, reference); !found {
+			return
+		}
+	}
+	if !service.limits.CheckEphemeral(strconv.Itoa(ctx.User.ID), "typing/"+strconv.Itoa(room.ID)) {
+		return // dropped without a reply like the typing of the other users, the client sends it again
+	}
+	typing := state == "on" || (state == "" && !service.chatService.IsTyping(ctx.User.ID, room.ID))
+	err := service.chatService.SetTyping(ctx.User.ID, room.ID, typing)
+	info := ""
+	if ctx.session.protocol == protocolText {
+		info = "You stopped typing in " + room.Name + "!!\n"
+		if typing {
+			info = "You are typing in " + room.Name + "!!\n"
+		}
+	}
+	sendResult(ctx.User, info, err, room.Name)
+}
+
+// typingNotices shows or changes whether the user is told when the members of its rooms are typing
+func (service *ServiceImpl) typingNotices(ctx *CommandContext) {
+	switch ctx.Arg(0) {
+	case "":
+		user, _ := service.chatService.GetUser(ctx.User.ID)
+		if user.HideTyping {
+			ctx.Reply("Typing notices are off!!\n")
+		} else {
+			ctx.Reply("Typing notices are on!!\n")
+		}
+	case "on", "off":
+		_, err := service.chatService.ShowTyping(ctx.User.ID, ctx.Arg(0) == "on")
+		sendResult(ctx.User, "Typing notices are "+ctx.Arg(0)+"!!\n", err, "")
+	default:
+		ctx.Error("Typing notices must be on or off!!!\n")
+	}
+}
+
+// nick changes the name of the user, the members of its rooms are told by the chat server
+func (service *ServiceImpl) nick(ctx *CommandContext) {
+	oldName := ctx.User.Name
+	user, err := service.chatService.Rename(ctx.User.ID, strings.TrimPrefix(ctx.Arg(0), "@"))
+	if err != nil {
+		ctx.Error(err.Error() + "!!!\n")
+		return
+	}
+	service.Lock() // the connections are listed under the lock
+	ctx.session.user.Name = user.Name
+	service.Unlock()
+	ctx.session.logger.Info("The user changed its name", "from", oldName, "to", user.Name)
+	ctx.Reply("You are now known as " + user.Name + "!!\n")
+}
+
+// profile shows the profile of the user or changes one of its fields
+func (service *ServiceImpl) profile(ctx *CommandContext) {
+	value := ctx.Arg(1)
+	var update data.ProfileUpdate
+	switch strings.ToLower(ctx.Arg(0)) {
+	case "":
+		profile, _ := service.chatService.GetProfile(ctx.User.ID)
+		ctx.Reply(formatProfile(profile))
+		return
+	case "displayname":
+		update.DisplayName = &value
+	case "bio":
+		update.Bio = &value
+	case "timezone":
+		update.Timezone = &value
+	default:
+		ctx.Error("Profile field must be displayname, bio or timezone!!!\n")
+		return
+	}
+	profile, err := service.chatService.SetProfile(ctx.User.ID, update)
+	if err != nil {
+		ctx.Error(err.Error() + "!!!\n")
+		return
+	}
+	ctx.Reply("Profile updated!!\n" + formatProfile(profile))
+}
+
+// whois shows the profile and the presence of a user, the admins also see its connection and rooms
+func (service *ServiceImpl) whois(ctx *CommandContext) {
+	user, found := service.resolveUser(ctx)
+	if !found {
+		return
+	}
+	details, found := service.monitor.Whois(user.ID)
+	if !found {
+		ctx.Error("User " + ctx.Arg(0) + " not found!!\n")
+		return
+	}
+	if !service.hasPermission(ctx.session, PermissionAdmin) {
+		details.Connection, details.ActiveRoom, details.Rooms = nil, "", nil
+	}
+	ctx.Reply(formatWhois(details))
+}
+
+// describe changes the description of the active room
+func (service *ServiceImpl) describe(ctx *CommandContext) {
+	room := service.activeRoomOf(ctx.User)
+	_, err := service.chatService.SetDescription(ctx.User.ID, room.ID, ctx.Arg(0))
+	sendResult(ctx.User, "Description of "+room.Name+" updated!!\n", err, room.Name)
+}
+
+// meta sets or removes a metadata key of the active room
+func (service *ServiceImpl) meta(ctx *CommandContext) {
+	room := service.activeRoomOf(ctx.User)
+	_, err := service.chatService.SetMetadata(ctx.User.ID, room.ID, ctx.Arg(0), ctx.Arg(1))
+	sendResult(ctx.User, "Metadata "+ctx.Arg(0)+" of "+room.Name+" updated!!\n", err, room.Name)
+}
+
+// renameRoom renames a room
+func (service *ServiceImpl) renameRoom(ctx *CommandContext) {
+	room, ok := service.resolveRoom(ctx.User, ctx.Arg(0))
+	if !ok {
+		return
+	}
+	roomName := strings.TrimPrefix(ctx.Arg(1), "#")
+	_, err := service.chatService.RenameRoom(ctx.User.ID, room.ID, roomName)
+	if err == chatserver.ErrRoomNameInvalid {
+		ctx.Error(formatError(err, roomName))
+	} else {
+		sendResult(ctx.User, "", err, room.Name)
+	}
+}
+
+// archive archives a room
+func (service *ServiceImpl) archive(ctx *CommandContext) {
+	if room, ok := service.resolveRoom(ctx.User, ctx.Arg(0)); ok {
+		_, err := service.chatService.ArchiveRoom(ctx.User.ID, room.ID, true)
+		sendResult(ctx.User, "", err, room.Name)
+	}
+}
+
+// unarchive restores an archived room
+func (service *ServiceImpl) unarchive(ctx *CommandContext) {
+	if room, ok := service.resolveRoom(ctx.User, ctx.Arg(0)); ok {
+		_, err := service.chatService.ArchiveRoom(ctx.User.ID, room.ID, false)
+		sendResult(ctx.User, "", err, room.Name)
+	}
+}
+
+// deleteRoom deletes a room
+func (service *ServiceImpl) deleteRoom(ctx *CommandContext) {
+	if room, ok := service.resolveRoom(ctx.User, ctx.Arg(0)); ok {
+		_, err := service.chatService.DeleteRoom(ctx.User.ID, room.ID)
+		sendResult(ctx.User, "", err, room.Name)
+	}
+}
+
+// activeRoom shows the active room of the user
+func (service *ServiceImpl) activeRoom(ctx *CommandContext) {
+	room := service.activeRoomOf(ctx.User)
+	ctx.Reply("Active room is " + room.Name + " - " + strconv.Itoa(room.ID) + "!!\n")
+}
+
+// quit closes the connection of the user
+func (service *ServiceImpl) quit(ctx *CommandContext) {
+	// closing the connection ends the read loop, which removes the user
+	ctx.session.conn.Close()
+}
+
+// sendSwitch switches the user to the room and shows its details
+func (service *ServiceImpl) sendSwitch(user data.User, room data.Room) {
+	switched, err := service.chatService.SwitchRoom(user.ID, room.ID)
+	sendResult(user, "Switched to "+switched.Name+"!!\n"+formatRoomDetails(switched, service.creatorOf(switched)), err, room.Name)
+}
+
+// creatorOf returns the name of the creator of the room, the users who left are still known by their last name
+func (service *ServiceImpl) creatorOf(room data.Room) string {
+	creator, _ := service.chatService.GetUser(room.CreatorID)
+	return creator.Name
+}
+
+// activeRoomOf returns the active room of the user
+func (service *ServiceImpl) activeRoomOf(user data.User) data.Room {
+	room, _ := service.chatService.GetActiveRoom(user.ID)
+	return room
+}
@@ -0,0 +1,248 @@
+package connections
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"chatServer/src/chatserver/data"
+)
+
+// PermissionAdmin is required by the commands meant for server operators
+const PermissionAdmin = "admin"
+
+// Errors returned by the command registry and the argument parser
+var (
+	ErrCommandExists     = errors.New("Command already exists")
+	ErrCommandInvalid    = errors.New("Command needs a name and a handler")
+	ErrUnterminatedQuote = errors.New("Quote is not terminated")
+)
+
+// Argument describes an argument of a command
+type Argument struct {
+	Name     string
+	Optional bool
+	Rest     bool // takes the rest of the line as free text, only allowed for the last argument
+}
+
+// Command describes a telnet command
+type Command struct {
+	Name       string
+	Aliases    []string
+	Args       []Argument
+	Help       string
+	Permission string // empty when every user can run the command
+	Ephemeral  bool   // sent on every key stroke by some clients, e.g. typing, it is not logged and its handler checks the ephemeral limit instead of the message limits
+	Handler    func(ctx *CommandContext)
+}
+
+// CommandContext is passed to the handler of a command
+type CommandContext struct {
+	User    data.User
+	Args    []string
+	session *session
+}
+
+// CommandRegistry holds the commands available to telnet users
+type CommandRegistry struct {
+	commands map[string]*Command
+	aliases  map[string]string
+	sync.RWMutex
+}
+
+// NewCommandRegistry returns an empty CommandRegistry
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]*Command),
+		aliases:  make(map[string]string),
+	}
+}
+
+// Register adds a command, names and aliases are given without the leading slash
+func (registry *CommandRegistry) Register(command Command) error {
+	registry.Lock()
+	defer registry.Unlock()
+	if command.Name == "" || command.Handler == nil {
+		return ErrCommandInvalid
+	}
+	for _, name := range append([]string{command.Name}, command.Aliases...) {
+		if registry.exists(name) {
+			return ErrCommandExists
+		}
+	}
+	registry.commands[strings.ToLower(command.Name)] = &command
+	for _, alias := range command.Aliases {
+		registry.aliases[strings.ToLower(alias)] = strings.ToLower(command.Name)
+	}
+	return nil
+}
+
+// Unregister removes a command and its aliases
+func (registry *CommandRegistry) Unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	name = strings.ToLower(name)
+	command, ok := registry.commands[name]
+	if !ok {
+		return
+	}
+	for _, alias := range command.Aliases {
+		delete(registry.aliases, strings.ToLower(alias))
+	}
+	delete(registry.commands, name)
+}
+
+// Find finds a command by name or alias
+func (registry *CommandRegistry) Find(name string) (Command, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if target, ok := registry.aliases[name]; ok {
+		name = target
+	}
+	if command, ok := registry.commands[name]; ok {
+		return *command, true
+	}
+	return Command{}, false
+}
+
+// Commands returns the registered commands ordered by name
+func (registry *CommandRegistry) Commands() []Command {
+	registry.RLock()
+	defer registry.RUnlock()
+	commands := []Command{}
+	for _, command := range registry.commands {
+		commands = append(commands, *command)
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+	return commands
+}
+
+// exists checks if the name is used by a command or an alias, the caller must hold the lock
+func (registry *CommandRegistry) exists(name string) bool {
+	name = strings.ToLower(name)
+	_, command := registry.commands[name]
+	_, alias := registry.aliases[name]
+	return command || alias
+}
+
+// Usage returns the usage of the command, optional arguments are shown in brackets
+func (command Command) Usage() string {
+	usage := "/" + command.Name
+	for _, arg := range command.Args {
+		name := arg.Name
+		if arg.Rest {
+			name = name + "..."
+		}
+		if arg.Optional {
+			usage = usage + " [" + name + "]"
+		} else {
+			usage = usage + " <" + name + ">"
+		}
+	}
+	return usage
+}
+
+// parseArgs splits the text following the command name into the arguments of the command,
+// it returns false when the number of arguments does not match the command
+func (command Command) parseArgs(text string) ([]string, bool, error) {
+	args := []string{}
+	position := 0
+	for _, arg := range command.Args {
+		if arg.Rest {
+			if rest := strings.TrimSpace(text[position:]); rest != "" {
+				args = append(args, unquote(rest))
+			}
+			position = len(text)
+			break
+		}
+		value, end, found, err := nextToken(text, position)
+		if err != nil {
+			return nil, false, err
+		}
+		if !found {
+			break
+		}
+		args = append(args, value)
+		position = end
+	}
+
+	if _, _, found, _ := nextToken(text, position); found { // too many arguments
+		return nil, false, nil
+	}
+	for i, arg := range command.Args {
+		if i >= len(args) && !arg.Optional {
+			return nil, false, nil
+		}
+	}
+	return args, true, nil
+}
+
+// checkArgs checks arguments that are already split, e.g. sent as JSON, the arguments beyond a rest argument are
+// joined to it, it returns false when the number of arguments does not match the command
+func (command Command) checkArgs(args []string) ([]string, bool) {
+	if len(args) > len(command.Args) {
+		last := len(command.Args) - 1
+		if last < 0 || !command.Args[last].Rest {
+			return nil, false
+		}
+		args = append(append([]string{}, args[:last]...), strings.Join(args[last:], " "))
+	}
+	for i, arg := range command.Args {
+		if i >= len(args) && !arg.Optional {
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+// nextToken returns the token starting at the position and where it ends,
+// a token starting with a double or single quote runs until the closing quote
+func nextToken(text string, position int) (string, int, bool, error) {
+	for position < len(text) && (text[position] == ' ' || text[position] == '\t') {
+		position++
+	}
+	if position >= len(text) {
+		return "", position, false, nil
+	}
+
+	if quote := text[position]; quote == '"' || quote == '\'' {
+		end := strings.IndexByte(text[position+1:], quote)
+		if end == -1 {
+			return "", position, false, ErrUnterminatedQuote
+		}
+		return text[position+1 : position+1+end], position + end + 2, true, nil
+	}
+	end := strings.IndexAny(text[position:], " \t")
+	if end == -1 {
+		return text[position:], len(text), true, nil
+	}
+	return text[position : position+end], position + end, true, nil
+}
+
+// unquote removes the quotes around text that is entirely quoted
+func unquote(text string) string {
+	if len(text) >= 2 && (text[0] == '"' || text[0] == '\'') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
+
+// Arg returns the argument at the index or an empty string when it was not given
+func (ctx *CommandContext) Arg(index int) string {
+	if index < len(ctx.Args) {
+		return ctx.Args[index]
+	}
+	return ""
+}
+
+// Reply sends information to the user who ran the command
+func (ctx *CommandContext) Reply(info string) {
+	sendInfo(ctx.User, info)
+}
+
+// Error sends a failed command to the user who ran the command
+func (ctx *CommandContext) Error(text string) {
+	sendError(ctx.User, text)
+}
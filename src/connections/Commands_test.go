@@ -0,0 +1,108 @@
+package connections
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("CommandRegistry", func() {
+	var registry *CommandRegistry
+	handler := func(ctx *CommandContext) {}
+
+	ginkgo.BeforeEach(func() {
+		registry = NewCommandRegistry()
+		gomega.Expect(registry.Register(Command{Name: "join", Aliases: []string{"j"}, Handler: handler})).To(gomega.BeNil())
+	})
+
+	ginkgo.Context("Register", func() {
+		ginkgo.It("should reject duplicate names and aliases", func() {
+			gomega.Expect(registry.Register(Command{Name: "JOIN", Handler: handler})).To(gomega.Equal(ErrCommandExists))
+			gomega.Expect(registry.Register(Command{Name: "j", Handler: handler})).To(gomega.Equal(ErrCommandExists))
+			gomega.Expect(registry.Register(Command{Name: "jump", Aliases: []string{"j"}, Handler: handler})).To(gomega.Equal(ErrCommandExists))
+		})
+		ginkgo.It("should reject commands without a name or a handler", func() {
+			gomega.Expect(registry.Register(Command{Handler: handler})).To(gomega.Equal(ErrCommandInvalid))
+			gomega.Expect(registry.Register(Command{Name: "rooms"})).To(gomega.Equal(ErrCommandInvalid))
+		})
+	})
+
+	ginkgo.Context("Find", func() {
+		ginkgo.It("should find commands by name or alias", func() {
+			command, found := registry.Find("/join")
+			gomega.Expect(found).To(gomega.BeTrue())
+			gomega.Expect(command.Name).To(gomega.Equal("join"))
+			command, found = registry.Find("/J")
+			gomega.Expect(found).To(gomega.BeTrue())
+			gomega.Expect(command.Name).To(gomega.Equal("join"))
+		})
+		ginkgo.It("should not find unknown commands", func() {
+			_, found := registry.Find("/jump")
+			gomega.Expect(found).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("Unregister", func() {
+		ginkgo.It("should remove the command and its aliases", func() {
+			registry.Unregister("JOIN")
+			_, found := registry.Find("/join")
+			gomega.Expect(found).To(gomega.BeFalse())
+			_, found = registry.Find("/j")
+			gomega.Expect(found).To(gomega.BeFalse())
+			gomega.Expect(registry.Register(Command{Name: "j", Handler: handler})).To(gomega.BeNil())
+		})
+	})
+
+	ginkgo.Context("Commands", func() {
+		ginkgo.It("should return the commands ordered by name", func() {
+			registry.Register(Command{Name: "accept", Handler: handler})
+			commands := registry.Commands()
+			gomega.Expect(commands).To(gomega.HaveLen(2))
+			gomega.Expect(commands[0].Name).To(gomega.Equal("accept"))
+			gomega.Expect(commands[1].Name).To(gomega.Equal("join"))
+		})
+	})
+})
+
+var _ = ginkgo.Describe("Command", func() {
+	command := Command{Name: "createroom", Args: []Argument{{Name: "roomName"}, {Name: "visibility", Optional: true}}}
+	meta := Command{Name: "meta", Args: []Argument{{Name: "key"}, {Name: "value", Optional: true, Rest: true}}}
+
+	ginkgo.Context("Usage", func() {
+		ginkgo.It("should show required and optional arguments", func() {
+			gomega.Expect(command.Usage()).To(gomega.Equal("/createroom <roomName> [visibility]"))
+			gomega.Expect(meta.Usage()).To(gomega.Equal("/meta <key> [value...]"))
+		})
+	})
+
+	ginkgo.Context("parseArgs", func() {
+		ginkgo.It("should split the arguments", func() {
+			args, valid, err := command.parseArgs(" general  private")
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(valid).To(gomega.BeTrue())
+			gomega.Expect(args).To(gomega.Equal([]string{"general", "private"}))
+		})
+		ginkgo.It("should keep quoted arguments together", func() {
+			args, valid, _ := command.parseArgs(` "team chat" 'invite'`)
+			gomega.Expect(valid).To(gomega.BeTrue())
+			gomega.Expect(args).To(gomega.Equal([]string{"team chat", "invite"}))
+		})
+		ginkgo.It("should give the rest of the line to the last argument", func() {
+			args, valid, _ := meta.parseArgs(` owner "the ops team"`)
+			gomega.Expect(valid).To(gomega.BeTrue())
+			gomega.Expect(args).To(gomega.Equal([]string{"owner", "the ops team"}))
+			args, valid, _ = meta.parseArgs(" owner the ops team")
+			gomega.Expect(valid).To(gomega.BeTrue())
+			gomega.Expect(args).To(gomega.Equal([]string{"owner", "the ops team"}))
+		})
+		ginkgo.It("should reject missing and extra arguments", func() {
+			_, valid, _ := command.parseArgs("")
+			gomega.Expect(valid).To(gomega.BeFalse())
+			_, valid, _ = command.parseArgs(" general private secret")
+			gomega.Expect(valid).To(gomega.BeFalse())
+		})
+		ginkgo.It("should reject unterminated quotes", func() {
+			_, _, err := command.parseArgs(` "team chat`)
+			gomega.Expect(err).To(gomega.Equal(ErrUnterminatedQuote))
+		})
+	})
+})
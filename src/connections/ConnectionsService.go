@@ -1,6 +1,16 @@
 package connections
 
+import (
+	"context"
+
+	"chatServer/src/chatserver/data"
+)
+
 // Service interface for api
 type Service interface {
 	HandleConnections()
+	StopAccepting()
+	Shutdown(ctx context.Context) error
+	Listening() bool
+	Connections() []data.Connection
 }
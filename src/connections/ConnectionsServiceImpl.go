@@ -2,68 +2,250 @@ package connections
 
 import (
 	"bufio"
+	"context"
 	"io"
-	"log"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"chatServer/src/admission"
 	"chatServer/src/chatserver"
 	"chatServer/src/chatserver/data"
 	"chatServer/src/config"
+	"chatServer/src/health"
+	"chatServer/src/logging"
+	"chatServer/src/metrics"
+	"chatServer/src/ratelimit"
 )
 
 // ServiceImpl struct for connections service
 type ServiceImpl struct {
 	chatService chatserver.Service
 	config      *config.Config
+	commands    *CommandRegistry
+	limits      *ratelimit.Guard
+	admission   *admission.Controller
+	monitor     *health.Monitor
+	logger      *logging.Logger
+	listener    net.Listener
+	conns       map[net.Conn]*session // the session is nil until the client has chosen a name
+	sessions    sync.WaitGroup
+	stopping    bool
+	closing     bool
+	sync.Mutex
 }
 
-// NewServiceImpl returns ServiceImpl
-func NewServiceImpl(chatService chatserver.Service, config *config.Config) *ServiceImpl{
-	return &ServiceImpl{
+// NewServiceImpl returns ServiceImpl, the limits and the admission are shared with the other listeners and nil disables them,
+// the monitor answers the admin commands and a nil logger discards the server log
+func NewServiceImpl(chatService chatserver.Service, config *config.Config, limits *ratelimit.Guard, admission *admission.Controller, monitor *health.Monitor, logger *logging.Logger) *ServiceImpl{
+	service := &ServiceImpl{
 		chatService: chatService,
 		config:	config,
+		commands: NewCommandRegistry(),
+		limits: limits,
+		admission: admission,
+		monitor: monitor,
+		logger: logger.With("transport", transport),
+		conns: map[net.Conn]*session{},
 	}
+	service.registerCommands()
+	service.registerAdminCommands()
+	return service
 }
 
-// HandleConnections handles the incoming connections
+// Commands returns the registry of the telnet commands
+func (service *ServiceImpl) Commands() *CommandRegistry {
+	return service.commands
+}
+
+// HandleConnections handles the incoming connections until StopAccepting is called
 func (service *ServiceImpl) HandleConnections() {
 	// listen for incoming tcp connections
 	ln, err := net.Listen(service.config.ConnectionType, service.config.Host+":"+service.config.Port)
 	if err != nil {
-		log.Println("Error listening:", err.Error())
+		service.logger.Error("Error listening", "address", service.config.Host+":"+service.config.Port, "error", err)
 		os.Exit(1)
 	}
 
 	defer ln.Close()
 
+	service.Lock()
+	service.listener = ln
+	if service.stopping { // stopped before the listener was ready
+		ln.Close()
+	}
+	service.Unlock()
+	service.logger.Info("Listening for telnet clients", "address", ln.Addr().String())
+
 	// handle the incoming connections
-	for {
-		conn, err := ln.Accept()
-		if err !=nil {
-			log.Println("Error handling connection:", err.Error())
+	err = service.admission.Serve(ln, rejectConnection, service.handleConnection)
+	service.logger.Info("Stopped accepting connections", "reason", err)
+}
+
+
+// Listening checks if the listener accepts connections
+func (service *ServiceImpl) Listening() bool {
+	service.Lock()
+	defer service.Unlock()
+	return service.listener != nil && !service.stopping
+}
+
+// Connections returns the users connected over telnet
+func (service *ServiceImpl) Connections() []data.Connection {
+	service.Lock()
+	defer service.Unlock()
+	connections := []data.Connection{}
+	for _, s := range service.conns {
+		if s == nil { // still choosing a name
+			continue
+		}
+		connections = append(connections, data.Connection{
+			UserID: s.user.ID,
+			UserName: s.user.Name,
+			Transport: transport,
+			Address: s.conn.RemoteAddr().String(),
+			ConnectedAt: s.connectedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(connections, func(i, j int) bool { return connections[i].UserID < connections[j].UserID })
+	return connections
+}
+
+// StopAccepting closes the listener, the connected clients stay connected
+func (service *ServiceImpl) StopAccepting() {
+	service.Lock()
+	defer service.Unlock()
+	service.stopping = true
+	if service.listener != nil {
+		service.listener.Close()
+	}
+}
+
+// Shutdown stops accepting and closes every connection once its pending output has been written,
+// the connections left when the context is done are closed right away
+func (service *ServiceImpl) Shutdown(ctx context.Context) error {
+	service.StopAccepting()
+
+	service.Lock()
+	if !service.closing {
+		service.closing = true
+		for conn, s := range service.conns {
+			service.stopConnection(conn, s)
 		}
+	}
+	service.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		service.sessions.Wait()
+		close(finished)
+	}()
+	select {
+		case <- finished:
+			return nil
+		case <- ctx.Done():
+			service.Lock()
+			for conn := range service.conns {
+				conn.Close()
+			}
+			service.Unlock()
+			return ctx.Err()
+	}
+}
+
+// stopConnection asks the writer of the session to flush and close the connection, clients without a session are closed right away
+func (service *ServiceImpl) stopConnection(conn net.Conn, s *session) {
+	if s == nil {
+		conn.Close()
+		return
+	}
+	close(s.stop)
+}
+
+// track records a new connection, it returns false when the server is shutting down
+func (service *ServiceImpl) track(conn net.Conn) bool {
+	service.Lock()
+	defer service.Unlock()
+	if service.stopping {
+		return false
+	}
+	service.conns[conn] = nil
+	service.sessions.Add(1)
+	return true
+}
+
+// untrack forgets a connection which has been closed
+func (service *ServiceImpl) untrack(conn net.Conn) {
+	service.Lock()
+	delete(service.conns, conn)
+	service.Unlock()
+	service.sessions.Done()
+}
 
-		go service.handleConnection(conn)
+// startSession attaches the session to its connection, it is stopped right away when the server is already closing
+func (service *ServiceImpl) startSession(s *session) {
+	service.Lock()
+	defer service.Unlock()
+	service.conns[s.conn] = s
+	if service.closing {
+		service.stopConnection(s.conn, s)
 	}
 }
 
+// rejectConnection tells a client why its connection is closed
+func rejectConnection(conn net.Conn, err error) {
+	metrics.ConnectionsRejected.Inc(transport)
+	io.WriteString(conn, err.Error() + "!!!\n")
+}
+
 
 func (service *ServiceImpl) handleConnection(conn net.Conn) {
-	log.Println("A new client joined")
+	logger := service.logger.With("conn", logging.NewID(), "remote", conn.RemoteAddr().String())
+	logger.Info("A new client connected")
 	defer conn.Close()
+	if !service.track(conn) {
+		return
+	}
+	defer service.untrack(conn)
+	metrics.ConnectionsAccepted.Inc(transport)
+	metrics.ConnectionsOpen.Inc(transport)
+	defer metrics.ConnectionsOpen.Dec(transport)
 
 	io.WriteString(conn, "Enter your username: ")
 	scanner := bufio.NewScanner(conn)
-	scanner.Scan()
-
-	user := service.chatService.CreateUser(scanner.Text())
-	service.showCommands(conn)
+	var user data.User
+	for {
+		if !scanner.Scan() { // the client left before choosing a name
+			logger.Info("The client left before choosing a name")
+			return
+		}
+		var err error
+		if user, err = service.chatService.RegisterUser(strings.TrimSpace(scanner.Text())); err == nil {
+			break
+		}
+		io.WriteString(conn, err.Error() + "!!!\nEnter your username: ")
+	}
+	s := &session{
+		conn:     conn,
+		user:     user,
+		protocol: protocolText,
+		ip:       ratelimit.HostIP(conn.RemoteAddr().String()),
+		done:     make(chan struct{}),
+		stop:     make(chan struct{}),
+		connectedAt: time.Now(),
+		logger:   logger.With("userId", user.ID, "user", user.Name),
+	}
+	s.logger.Info("The client joined")
+	defer service.closeSession(s)
+	service.showLogin(s)
 
 	// handle writing back to connection
-	go service.handleWriteToConnection(user, conn)
+	go service.handleWriteToConnection(s)
+	service.startSession(s)
 
 	// handle messages from the client
 	for  {
@@ -72,104 +254,254 @@ func (service *ServiceImpl) handleConnection(conn net.Conn) {
 		message = strings.TrimSpace(message)
 
 		if len(message) > 0 {
-			if message[0] == '/' { // check if it is a command
-				service.handleCommands(message, conn, user)
-			} else { // handle messages
+			metrics.LinesReceived.Inc(transport)
+			service.chatService.Touch(user.ID)
+			requestID := ""
+			var command *jsonCommand // a command sent as a JSON object, its arguments are not split again
+			if s.protocol == protocolJSON && message[0] == '{' { // commands can be sent as JSON objects
+				parsed, valid := parseJSONCommand(message)
+				if !valid {
+					sendError(user, "Invalid JSON command!!!\n")
+					message = ""
+				} else if requestID = parsed.ID; parsed.Command != "" {
+					command, message = &parsed, "/" + parsed.Command
+				} else {
+					message = parsed.Text
+				}
+			}
+			if requestID != "" {
+				user.Output <- data.Event{Type: eventRequestStart, Text: requestID}
+			}
+
+			if message != "" && !service.isEphemeral(message) && !service.checkLimits(s) { // flooding clients are warned and muted
+				message, command = "", nil
+			}
+
+			if command != nil {
+				service.handleJSONCommand(*command, s)
+			} else if strings.HasPrefix(message, "/") { // check if it is a command
+				service.handleCommands(message, s)
+			} else if len(message) > 0 { // handle messages
 				userStruct, _ := service.chatService.GetUser(user.ID)
-				service.chatService.Publish(data.Input{
+				_, err := service.chatService.Publish(data.Input{
 					Text: message,
 					Room: userStruct.ActiveRoom,
 				}, user.ID, false)
+				if err != nil {
+					room, _ := service.chatService.GetRoom(userStruct.ActiveRoom)
+					sendError(user, formatError(err, room.Name))
+				}
 			}
 
-			if !ok {
-				break;
+			if requestID != "" {
+				user.Output <- data.Event{Type: eventRequestDone, Text: requestID}
 			}
 		}
+
+		if !ok { // the client disconnected or quit
+			break
+		}
 	}
 }
 
-// handleWriteToConnection writes back to connection
-func (service *ServiceImpl) handleWriteToConnection(user data.User, conn net.Conn) {
+// closeSession removes the user of the session and stops its writer
+func (service *ServiceImpl) closeSession(s *session) {
+	s.logger.Info("The client left", "duration", time.Since(s.connectedAt).Round(time.Millisecond))
+	service.chatService.RemoveUser(s.user.ID)
+	close(s.done)
+}
+
+// handleWriteToConnection writes back to connection until the session is closed
+func (service *ServiceImpl) handleWriteToConnection(s *session) {
+	service.Lock() // the name of the user changes with /nick under the lock
+	user, conn := s.user, s.conn
+	service.Unlock()
+	protocol := protocolText
+	requestID := ""
+	write := func(event data.Event) {
+		switch event.Type {
+		case eventProtocol:
+			protocol = event.Text
+		case eventRequestStart:
+			requestID = event.Text
+		case eventRequestDone:
+			if protocol == protocolJSON {
+				writeJSON(conn, jsonEvent{Type: eventDone, ID: requestID})
+			}
+			requestID = ""
+		case data.EventKilled:
+			// closing the connection ends the read loop which removes the user
+			writeEvent(conn, protocol, event, requestID)
+			s.logger.Warn("The client has been disconnected by an admin", "reason", event.Text)
+			conn.Close()
+		default:
+			writeEvent(conn, protocol, event, requestID)
+		}
+	}
 	for {
 		select {
-			case msg := <- user.Output:
-				io.WriteString(conn, msg)
-			case <- user.Close:
+			case event := <- user.Output:
+				write(event)
+			case <- s.stop:
+				// flush what was sent before the shutdown, e.g. the shutdown notice, closing the connection ends the read loop
+				for {
+					select {
+						case event := <- user.Output:
+							write(event)
+						default:
+							conn.Close()
+							return
+					}
+				}
+			case <- s.done:
 				return
 		}
 	}
 }
 
-// handleCommands handles the commands by user and performs necessary actions
-func (service *ServiceImpl) handleCommands(command string, conn net.Conn, user data.User) {
-	switch {
-	case command == "/help":
-		service.showCommands(conn)
-	case command == "/rooms":
-		service.chatService.ListRooms(user.ID)
-	case strings.HasPrefix(command, "/createroom"):
-		if isCommandValid(command) {
-			service.chatService.CreateRoom(strings.Replace(command, "/createroom ", "", -1), user.ID, user.Name)
-		} else {
-			sendOptionsMissingInfo(conn)
-		}
-	case strings.HasPrefix(command, "/subscribe"):
-		if isCommandValid(command) {
-			roomID, _ := strconv.Atoi(strings.Replace(command, "/subscribe ", "", -1))
-			service.chatService.Subscribe(user.ID, roomID)
-		} else {
-			sendOptionsMissingInfo(conn)
-		}
-	case strings.HasPrefix(command, "/unsubscribe"):
-		if isCommandValid(command) {
-			roomID, _ := strconv.Atoi(strings.Replace(command, "/unsubscribe ", "", -1))
-			service.chatService.UnSubscribe(user.ID, roomID)
-		} else {
-			sendOptionsMissingInfo(conn)
-		}
-	case strings.HasPrefix(command, "/switch"):
-		if isCommandValid(command) {
-			roomID, _ := strconv.Atoi(strings.Replace(command, "/switch ", "", -1))
-			service.chatService.SwitchRoom(user.ID, roomID)
-		} else {
-			sendOptionsMissingInfo(conn)
-		}
-	case strings.HasPrefix(command, "/activeroom"):
-		service.chatService.GetActiveRoom(user.ID)
-	case command == "/quit":
-		service.chatService.RemoveUser(user.ID)
-		conn.Close()
-		user.Close <- struct{} {}
-	default:
-		io.WriteString(conn, "Unknown Command!!!\n")
+// handleCommands finds the command of the line in the registry, splits and checks its arguments and runs it
+func (service *ServiceImpl) handleCommands(line string, s *session) {
+	name := strings.Fields(line)[0]
+	command, found := service.findCommand(name, s)
+	if !found {
+		return
+	}
+	args, valid, err := command.parseArgs(line[len(name):])
+	if err != nil {
+		sendError(s.user, err.Error() + "!!!\n")
+		return
+	}
+	service.runCommand(command, args, valid, s)
+}
+
+// handleJSONCommand runs a command sent as a JSON object, its arguments are taken as they were sent
+func (service *ServiceImpl) handleJSONCommand(jsonCommand jsonCommand, s *session) {
+	command, found := service.findCommand(jsonCommand.Command, s)
+	if !found {
+		return
+	}
+	args, valid := command.checkArgs(jsonCommand.arguments())
+	service.runCommand(command, args, valid, s)
+}
+
+// findCommand finds a command the user of the session can run and tells the user when there is none
+func (service *ServiceImpl) findCommand(name string, s *session) (Command, bool) {
+	command, found := service.commands.Find(name)
+	if !found || !service.hasPermission(s, command.Permission) {
+		metrics.CommandsHandled.Inc(transport, "unknown")
+		s.logger.Debug("Unknown command", "command", name)
+		sendError(s.user, "Unknown Command!!! Type /help to list the commands\n")
+		return Command{}, false
+	}
+	metrics.CommandsHandled.Inc(transport, command.Name)
+	if !command.Ephemeral {
+		s.logger.Debug("Command", "command", command.Name)
+	}
+	return command, true
+}
+
+// runCommand runs the command with its arguments or tells the user its usage when they do not match
+func (service *ServiceImpl) runCommand(command Command, args []string, valid bool, s *session) {
+	if !valid {
+		sendError(s.user, "Options missing!!! Usage: " + command.Usage() + "\n")
+		return
 	}
+	command.Handler(&CommandContext{
+		User: s.user,
+		Args: args,
+		session: s,
+	})
 }
 
-// isCommandValid checks if command is valid or not
-func isCommandValid(command string) bool {
-	if len(strings.Split(command, " ")) != 2 {
+// isEphemeral checks if the line runs an ephemeral command, they are sent too often to count against the message limits
+func (service *ServiceImpl) isEphemeral(line string) bool {
+	if !strings.HasPrefix(line, "/") {
+		return false
+	}
+	command, found := service.commands.Find(strings.Fields(line)[0])
+	return found && command.Ephemeral
+}
+
+// checkLimits checks the rate limits for a line sent by the user and tells the user when it is dropped
+func (service *ServiceImpl) checkLimits(s *session) bool {
+	decision, remaining := service.limits.CheckMessage(strconv.Itoa(s.user.ID), s.ip)
+	if decision != ratelimit.Allow {
+		metrics.LinesRateLimited.Inc(transport)
+		if decision == ratelimit.Mute {
+			s.logger.Warn("The user is muted for flooding", "duration", remaining.Round(time.Second))
+		}
+		sendError(s.user, decision.Notice(remaining) + "!!!\n")
 		return false
 	}
 	return true
 }
 
-// sendOptionsMissingInfo checks if the options are missing or not in a command
-func sendOptionsMissingInfo(conn net.Conn) {
-	io.WriteString(conn, "Options missing!!!\n")
+// resolveRoom finds the room referenced by id, name or #name and tells the user when it is not found
+func (service *ServiceImpl) resolveRoom(user data.User, reference string) (data.Room, bool) {
+	room, found := service.chatService.FindRoom(user.ID, reference)
+	if !found {
+		sendError(user, "Room " + reference + " not found!!" +
+			chatserver.FormatSuggestions(service.chatService.SuggestRooms(user.ID, reference), "#") + "\n")
+	}
+	return room, found
+}
+
+// hasPermission checks if the user of the session has the permission, empty permissions are granted to everyone
+// and the admin permission follows the admin role of the user
+func (service *ServiceImpl) hasPermission(s *session, permission string) bool {
+	if permission == "" || s.permissions[permission] {
+		return true
+	}
+	if permission == PermissionAdmin {
+		user, _ := service.chatService.GetUser(s.user.ID)
+		return user.Admin
+	}
+	return false
+}
+
+// sendResult sends the information about a successful command or the error rendered for the room
+func sendResult(user data.User, info string, err error, roomName string) {
+	if err != nil {
+		sendError(user, formatError(err, roomName))
+	} else if info != "" {
+		sendInfo(user, info)
+	}
+}
+
+// sendInfo sends information to the user through the writer of the connection
+func sendInfo(user data.User, info string) {
+	user.Output <- data.Event{Type: data.EventInfo, Text: info}
+}
+
+// sendError sends a failed command to the user through the writer of the connection
+func sendError(user data.User, text string) {
+	user.Output <- data.Event{Type: data.EventError, Text: text}
+}
+
+// showLogin shows the message of the day, the rooms of the user with their unread messages and who is online
+func (service *ServiceImpl) showLogin(s *session) {
+	var login strings.Builder
+	if motd := service.chatService.GetMOTD(); motd != "" {
+		login.WriteString(motd + "\n")
+	}
+	login.WriteString(formatSummary(service.chatService.GetSummary(s.user.ID)))
+	if room, found := service.chatService.GetRoom(chatserver.DefaultRoomID); found { // users start in the Default room
+		login.WriteString(formatWelcome(room))
+	}
+	login.WriteString("Type /help to list the commands!!\n")
+	sendInfo(s.user, login.String())
 }
 
 // showCommands shows the commands that are available to the user
-func (service *ServiceImpl) showCommands(conn net.Conn) {
-	commands :=
-		`***Available commands***
-/help - lists all the available commands
-/rooms - lists all the available rooms
-/createroom - creates new room - Ex: /createroom roomName
-/subscribe - subscribes to a room - Ex: /subscribe roomId
-/unsubscribe - unsubscribes from a room - Ex: /unsubscribe roomId
-/switch - switches to a room - Ex: /switch roomId
-/activeroom - displays the active room of a user - Ex: /activeroom
-/quit` + "\n"
-	io.WriteString(conn, commands)
+func (service *ServiceImpl) showCommands(s *session) {
+	var commands strings.Builder
+	commands.WriteString("***Available commands***\n")
+	commands.WriteString("Rooms can be given as #name, name or id and users as @name or name, quote arguments with spaces\n")
+	for _, command := range service.commands.Commands() {
+		if service.hasPermission(s, command.Permission) {
+			commands.WriteString(command.Usage() + " - " + command.Help + "\n")
+		}
+	}
+	commands.WriteString("Type /help <command> for the details of a command\n")
+	sendInfo(s.user, commands.String())
 }
@@ -0,0 +1,407 @@
+package connections
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/src/health"
+	"chatServer/src/ratelimit"
+	"chatServer/testhelpers"
+)
+
+func createService() (*ServiceImpl, chatserver.Service) {
+	chatService := chatserver.NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+	chatService.Run()
+	monitor := health.NewMonitor("test", chatService)
+	service := NewServiceImpl(chatService, &config.Config{}, nil, nil, monitor, nil)
+	monitor.AddTransport(transport, service)
+	return service, chatService
+}
+
+// connect runs a session over an in memory connection, the channel is closed when the session ends
+func connect(service *ServiceImpl) (net.Conn, chan struct{}) {
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		service.handleConnection(server)
+		close(done)
+	}()
+	go io.Copy(ioutil.Discard, client)
+	return client, done
+}
+
+// safeBuffer collects the output of a connection while the test reads it
+type safeBuffer struct {
+	buffer bytes.Buffer
+	sync.Mutex
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.String()
+}
+
+var _ = ginkgo.Describe("ServiceImpl", func() {
+
+	ginkgo.Context("handleConnection", func() {
+		ginkgo.It("should end the session and remove the user when the client disconnects", func() {
+			service, chatService := createService()
+			client, done := connect(service)
+			io.WriteString(client, "alice\n")
+			gomega.Eventually(func() int { return len(chatService.GetUsers()) }).Should(gomega.Equal(2))
+
+			client.Close()
+			gomega.Eventually(done, time.Second).Should(gomega.BeClosed())
+			user, _ := chatService.GetUser(1)
+			gomega.Expect(user.Dead).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should end the session when the user quits", func() {
+			service, chatService := createService()
+			client, done := connect(service)
+			defer client.Close()
+			io.WriteString(client, "alice\n/quit\n")
+
+			gomega.Eventually(done, time.Second).Should(gomega.BeClosed())
+			user, _ := chatService.GetUser(1)
+			gomega.Expect(user.Dead).To(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Context("Connections", func() {
+		ginkgo.It("should list the users once they have chosen a name", func() {
+			service, _ := createService()
+			client, _ := connect(service)
+			defer client.Close()
+			gomega.Expect(service.Connections()).To(gomega.BeEmpty())
+
+			io.WriteString(client, "alice\n")
+			gomega.Eventually(service.Connections).Should(gomega.HaveLen(1))
+			gomega.Expect(service.Connections()[0].UserName).To(gomega.Equal("alice"))
+			gomega.Expect(service.Connections()[0].Transport).To(gomega.Equal("telnet"))
+		})
+	})
+
+	ginkgo.Context("Login", func() {
+		ginkgo.It("should show the message of the day, the rooms and the welcome messages", func() {
+			service, chatService := createService()
+			chatService.SetMOTD("Be nice")
+			bob := chatService.CreateUser("bob")
+			room, _ := chatService.CreateRoom("Tech", bob.ID, "", "")
+			chatService.SetWelcome(bob.ID, room.ID, "Builds are discussed here")
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Type /help to list the commands!!"))
+			gomega.Expect(output.String()).To(gomega.ContainSubstring("Be nice\nYour rooms:\n"))
+			gomega.Expect(output.String()).To(gomega.MatchRegexp(`#Default\s+0\s+@bob`))
+
+			io.WriteString(client, "/subscribe #Tech\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Subscribed to Tech!!\n#Tech: Builds are discussed here\n"))
+		})
+	})
+
+	ginkgo.Context("Rooms", func() {
+		ginkgo.It("should show the creator, the creation time and the metadata of the rooms", func() {
+			service, chatService := createService()
+			bob := chatService.CreateUser("bob")
+			room, _ := chatService.CreateRoom("Tech", bob.ID, "", "")
+			chatService.SetTopic(bob.ID, room.ID, "Builds")
+			chatService.SetMetadata(bob.ID, room.ID, "team", "core")
+			chatService.SetMetadata(bob.ID, room.ID, "lang", "go")
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n/rooms\n")
+			gomega.Eventually(output.String).Should(gomega.MatchRegexp(`ID\s+NAME\s+VISIBILITY\s+CREATOR\s+CREATED\s+TOPIC\s+METADATA`))
+			gomega.Expect(output.String()).To(gomega.MatchRegexp(`#Tech\s+public\s+@bob\s+\d{4}-\d{2}-\d{2} \d{2}:\d{2}\s+Builds\s+lang=go, team=core`))
+
+			io.WriteString(client, "/join #Tech\n")
+			gomega.Eventually(output.String).Should(gomega.MatchRegexp(
+				`Switched to Tech!!\nTopic: Builds\nCreated by @bob on \d{4}-\d{2}-\d{2} \d{2}:\d{2}\nMetadata: lang=go, team=core\n`))
+		})
+	})
+
+	ginkgo.Context("JSON protocol", func() {
+		ginkgo.It("should pass the arguments of the commands as they were sent", func() {
+			service, chatService := createService()
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n/proto json\n")
+			io.WriteString(client, `{"id": "1", "command": "createroom", "args": ["team chat"]}`+"\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring(`{"type":"done","id":"1"}`))
+			_, found := chatService.FindRoom(1, "team chat")
+			gomega.Expect(found).To(gomega.BeTrue())
+
+			io.WriteString(client, `{"id": "2", "command": "switch", "args": ["#team chat"]}`+"\n")
+			io.WriteString(client, `{"id": "3", "command": "topic", "args": ["Builds", "and releases"]}`+"\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring(`{"type":"done","id":"3"}`))
+			room, _ := chatService.FindRoom(1, "team chat")
+			gomega.Expect(room.Topic).To(gomega.Equal("Builds and releases"))
+		})
+	})
+
+	ginkgo.Context("Presence", func() {
+		ginkgo.It("should tell the members of the shared rooms when a user is away and back", func() {
+			service, chatService := createService()
+			bob := chatService.CreateUser("bob")
+			client, _ := connect(service)
+			defer client.Close()
+			io.WriteString(client, "alice\n")
+			gomega.Eventually(func() int { return len(chatService.GetUsers()) }).Should(gomega.Equal(3))
+
+			// presence returns the text and the state of the next presence event sent to bob
+			presence := func() string {
+				for event := range bob.Output {
+					if event.Type == data.EventPresence {
+						return event.Presence + ":" + event.Text
+					}
+				}
+				return ""
+			}
+			io.WriteString(client, "/away out for lunch\n")
+			gomega.Expect(presence()).To(gomega.Equal("online:")) // alice connected
+			gomega.Expect(presence()).To(gomega.Equal("away:out for lunch"))
+			alice, _ := chatService.FindUser("alice")
+			gomega.Expect(alice.Presence).To(gomega.Equal(data.PresenceAway))
+
+			io.WriteString(client, "/back\n")
+			gomega.Expect(presence()).To(gomega.Equal("online:"))
+			gomega.Expect(renderEvent(data.Event{Type: data.EventPresence, UserName: "alice", Presence: "away", Text: "lunch"}).Text).
+				To(gomega.Equal("alice is now away: lunch!!\n"))
+		})
+	})
+
+	ginkgo.Context("Typing", func() {
+		ginkgo.It("should toggle the typing of the user in the active room", func() {
+			service, chatService := createService()
+			bob := chatService.CreateUser("bob")
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n/typing\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("You are typing in Default!!"))
+			alice, _ := chatService.FindUser("alice")
+			gomega.Expect(chatService.IsTyping(alice.ID, 0)).To(gomega.BeTrue())
+			io.WriteString(client, "/typing\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("You stopped typing in Default!!"))
+
+			// typing returns the next typing event sent to bob as text
+			typing := func() string {
+				for event := range bob.Output {
+					if event.Type == data.EventTyping {
+						return renderEvent(event).Text
+					}
+				}
+				return ""
+			}
+			gomega.Expect(typing()).To(gomega.Equal("alice is typing in Default!!\n"))
+			gomega.Expect(typing()).To(gomega.Equal("alice stopped typing in Default!!\n"))
+
+			io.WriteString(client, "/typing on #missing\n/typingnotices off\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Typing notices are off!!"))
+			gomega.Expect(output.String()).To(gomega.ContainSubstring("Room #missing not found!!"))
+			alice, _ = chatService.GetUser(alice.ID)
+			gomega.Expect(alice.HideTyping).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should drop the typing sent again within a second", func() {
+			service, _ := createService()
+			service.limits = ratelimit.NewGuard(config.RateLimitConfig{})
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n/typing on\n/typing off\n/activeroom\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Active room is Default"))
+			gomega.Expect(output.String()).To(gomega.ContainSubstring("You are typing in Default!!"))
+			gomega.Expect(output.String()).NotTo(gomega.ContainSubstring("You stopped typing"))
+		})
+
+		ginkgo.It("should not count the typing against the rate limits", func() {
+			service, _ := createService()
+			gomega.Expect(service.isEphemeral("/typing on #general")).To(gomega.BeTrue())
+			gomega.Expect(service.isEphemeral("/typingnotices off")).To(gomega.BeFalse())
+			gomega.Expect(service.isEphemeral("typing")).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("Profile", func() {
+		ginkgo.It("should ask again for a name that is not valid or in use", func() {
+			service, chatService := createService()
+			chatService.CreateUser("bob")
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "Bob\n1234\nalice\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Type /help to list the commands!!"))
+			gomega.Expect(output.String()).To(gomega.HavePrefix("Enter your username: Name is already in use!!!\n" +
+				"Enter your username: Name must have 1 to 30 characters"))
+			_, found := chatService.FindUser("alice")
+			gomega.Expect(found).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should change the name and show the profile with /whois", func() {
+			service, chatService := createService()
+			bob := chatService.CreateUser("bob")
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n/nick bob\n/nick ally\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("You are now known as ally!!"))
+			gomega.Expect(output.String()).To(gomega.ContainSubstring("Name is already in use!!!"))
+			gomega.Expect(service.Connections()[0].UserName).To(gomega.Equal("ally"))
+			for event := range bob.Output {
+				if event.Type == data.EventNick {
+					gomega.Expect(renderEvent(event).Text).To(gomega.Equal("alice is now known as ally!!\n"))
+					break
+				}
+			}
+
+			io.WriteString(client, "/profile bio likes Go\n/profile color blue\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Profile field must be displayname, bio or timezone!!!"))
+			gomega.Expect(output.String()).To(gomega.ContainSubstring("Profile updated!!\nProfile of @ally\nBio: likes Go\n"))
+
+			io.WriteString(client, "/whois @ally\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("User @ally (id 2)"))
+			gomega.Expect(output.String()).NotTo(gomega.ContainSubstring("Connection: telnet"))
+			gomega.Expect(output.String()).NotTo(gomega.ContainSubstring("Rooms: "))
+		})
+	})
+
+	ginkgo.Context("Admin commands", func() {
+		ginkgo.It("should only be run by the users who became admins", func() {
+			service, chatService := createService()
+			chatService.SetAdmins(map[string]string{"Alice": "secret"})
+			server, client := net.Pipe()
+			defer client.Close()
+			go service.handleConnection(server)
+			var output safeBuffer
+			go io.Copy(&output, client)
+
+			io.WriteString(client, "alice\n/who\n/oper wrong\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Name or password is not valid!!!"))
+			gomega.Expect(output.String()).To(gomega.ContainSubstring("Unknown Command!!!"))
+
+			io.WriteString(client, "/oper secret\n/who\n")
+			gomega.Eventually(output.String).Should(gomega.ContainSubstring("Connected users: 1"))
+			gomega.Expect(output.String()).To(gomega.MatchRegexp(`1\s+@alice\s+telnet`))
+		})
+
+		ginkgo.It("should disconnect the killed users with the reason", func() {
+			service, chatService := createService()
+			chatService.SetAdmins(map[string]string{"alice": "secret"})
+			admin, _ := connect(service)
+			defer admin.Close()
+			server, client := net.Pipe()
+			done := make(chan struct{})
+			go func() {
+				service.handleConnection(server)
+				close(done)
+			}()
+			output := make(chan string, 1)
+			go func() {
+				text, _ := ioutil.ReadAll(client)
+				output <- string(text)
+			}()
+			io.WriteString(client, "bob\n")
+			gomega.Eventually(func() int { return len(chatService.GetUsers()) }).Should(gomega.Equal(2))
+
+			io.WriteString(admin, "alice\n/oper secret\n/kill @bob spamming the room\n")
+			gomega.Eventually(done, time.Second).Should(gomega.BeClosed())
+			gomega.Eventually(output, time.Second).Should(gomega.Receive(
+				gomega.ContainSubstring("You have been disconnected by an admin: spamming the room!!!")))
+			user, _ := chatService.GetUser(1)
+			gomega.Expect(user.Dead).To(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Context("Shutdown", func() {
+		ginkgo.It("should write the pending output before closing the connections", func() {
+			service, chatService := createService()
+			server, client := net.Pipe()
+			go service.handleConnection(server)
+			output := make(chan string, 1)
+			go func() {
+				text, _ := ioutil.ReadAll(client)
+				output <- string(text)
+			}()
+			io.WriteString(client, "alice\n")
+			gomega.Eventually(func() int { return len(chatService.GetUsers()) }).Should(gomega.Equal(2))
+
+			chatService.Broadcast("Server is shutting down")
+			gomega.Expect(service.Shutdown(context.Background())).To(gomega.Succeed())
+			gomega.Eventually(output, time.Second).Should(gomega.Receive(gomega.ContainSubstring("Server is shutting down")))
+			user, _ := chatService.GetUser(1)
+			gomega.Expect(user.Dead).To(gomega.BeTrue())
+		})
+
+		ginkgo.It("should close the connections left when the context is done", func() {
+			service, chatService := createService()
+			server, client := net.Pipe() // the client never reads, the writer stays blocked
+			defer client.Close()
+			done := make(chan struct{})
+			go func() {
+				service.handleConnection(server)
+				close(done)
+			}()
+			client.Read(make([]byte, 64)) // the username prompt
+			io.WriteString(client, "alice\n")
+			gomega.Eventually(func() int { return len(chatService.GetUsers()) }).Should(gomega.Equal(2))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			gomega.Expect(service.Shutdown(ctx)).To(gomega.Equal(context.DeadlineExceeded))
+			gomega.Eventually(done, time.Second).Should(gomega.BeClosed())
+		})
+
+		ginkgo.It("should refuse the connections accepted after it stopped accepting", func() {
+			service, chatService := createService()
+			service.StopAccepting()
+			_, done := connect(service)
+			gomega.Eventually(done, time.Second).Should(gomega.BeClosed())
+			gomega.Expect(len(chatService.GetUsers())).To(gomega.Equal(1))
+		})
+	})
+})
@@ -0,0 +1,4 @@
+package connections
+
+// transport is the transport label of the telnet listener in the listener metrics
+const transport = "telnet"
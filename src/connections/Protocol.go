@@ -0,0 +1,121 @@
+package connections
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"chatServer/src/chatserver/data"
+	"chatServer/src/logging"
+)
+
+// Protocols a client can negotiate with /proto
+const (
+	protocolText = "text"
+	protocolJSON = "json"
+)
+
+// Events used only between the reader and the writer of a connection, they are never written to the client
+const (
+	eventProtocol     = "protocol"     // switches the protocol of the writer
+	eventRequestStart = "requestStart" // events until eventRequestDone belong to the request id in the text
+	eventRequestDone  = "requestDone"  // the request has been handled
+)
+
+// eventDone is the type of the JSON event that ends the events of a request
+const eventDone = "done"
+
+// jsonEvent is a server event written as a JSON line
+type jsonEvent struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Text    string        `json:"text,omitempty"`
+	Room    string        `json:"room,omitempty"`
+	User    string        `json:"user,omitempty"`
+	Message *data.Message `json:"message,omitempty"`
+	State   string        `json:"state,omitempty"`   // presence state of the user of a presence event
+	Status  string        `json:"status,omitempty"`  // status text of a presence event
+	Typing  *bool         `json:"typing,omitempty"`  // whether the user of a typing event started or stopped typing
+	OldName string        `json:"oldName,omitempty"` // name of the user of a nick event before the change
+}
+
+// jsonCommand is a command or message sent by a client as a JSON line
+type jsonCommand struct {
+	ID      string   `json:"id"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Text    string   `json:"text"`
+}
+
+// session holds the state of the reading side of a telnet connection
+type session struct {
+	conn        net.Conn
+	user        data.User
+	protocol    string
+	permissions map[string]bool
+	ip          string
+	done        chan struct{} // closed when the client has left, it stops the writer
+	stop        chan struct{} // closed when the server shuts down, the writer flushes the output and closes the connection
+	connectedAt time.Time
+	logger      *logging.Logger // correlates the entries of the connection
+}
+
+// parseJSONCommand decodes a command or a message sent as a JSON line, the leading slash of the command is optional
+func parseJSONCommand(line string) (jsonCommand, bool) {
+	var command jsonCommand
+	if err := json.Unmarshal([]byte(line), &command); err != nil {
+		return jsonCommand{}, false
+	}
+	command.Command = strings.TrimPrefix(command.Command, "/")
+	return command, true
+}
+
+// arguments returns the arguments of the command as they were sent, the text is the last one
+func (command jsonCommand) arguments() []string {
+	args := append([]string{}, command.Args...)
+	if command.Text != "" {
+		args = append(args, command.Text)
+	}
+	return args
+}
+
+// writeEvent renders the event and writes it to the client in the negotiated protocol, the JSON protocol
+// also carries the fields of the presence, typing and nick events so that clients do not parse the text and
+// the request id on the replies and errors, the other events are not produced by the request even when they
+// arrive while it is handled
+func writeEvent(conn net.Conn, protocol string, event data.Event, requestID string) {
+	rendered := renderEvent(event)
+	if protocol != protocolJSON {
+		io.WriteString(conn, rendered.Text)
+		return
+	}
+
+	output := jsonEvent{
+		Type: event.Type,
+		Text: strings.TrimRight(rendered.Text, "\n"),
+		Room: event.RoomName,
+		User: event.UserName,
+	}
+	switch event.Type {
+	case data.EventMessage:
+		output.Message = &event.Message
+	case data.EventPresence:
+		output.State, output.Status = event.Presence, event.Text
+	case data.EventTyping:
+		typing := event.Typing
+		output.Typing = &typing
+	case data.EventNick:
+		output.OldName = event.Text
+	case data.EventInfo, data.EventError:
+		output.ID = requestID
+	}
+	writeJSON(conn, output)
+}
+
+// writeJSON writes a JSON event followed by a new line
+func writeJSON(conn net.Conn, event jsonEvent) {
+	line, _ := json.Marshal(event)
+	io.WriteString(conn, string(line)+"\n")
+}
@@ -0,0 +1,101 @@
+package connections
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/chatserver/data"
+)
+
+func TestConnections(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Chat connections unit Test Suite")
+}
+
+// readEvent writes the event on one end of a pipe and returns the line read on the other end
+func readEvent(protocol string, event data.Event, requestID string) string {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		writeEvent(server, protocol, event, requestID)
+		server.Close()
+	}()
+	line, _ := bufio.NewReader(client).ReadString('\n')
+	return line
+}
+
+var _ = ginkgo.Describe("Protocol", func() {
+
+	ginkgo.Context("parseJSONCommand", func() {
+
+		ginkgo.It("Decodes a command with its arguments", func() {
+			command, ok := parseJSONCommand(`{"id": "7", "command": "join", "args": ["#Tech", "secret"]}`)
+			gomega.Expect(ok).To(gomega.BeTrue())
+			gomega.Expect(command.ID).To(gomega.Equal("7"))
+			gomega.Expect(command.Command).To(gomega.Equal("join"))
+			gomega.Expect(command.arguments()).To(gomega.Equal([]string{"#Tech", "secret"}))
+		})
+
+		ginkgo.It("Appends the text of a command and keeps the spaces of the arguments", func() {
+			command, _ := parseJSONCommand(`{"command": "/createroom", "args": ["team chat", "private"], "text": "Go talk"}`)
+			gomega.Expect(command.Command).To(gomega.Equal("createroom"))
+			gomega.Expect(command.arguments()).To(gomega.Equal([]string{"team chat", "private", "Go talk"}))
+		})
+
+		ginkgo.It("Treats a JSON object without command as a message", func() {
+			command, ok := parseJSONCommand(`{"id": "8", "text": "hello"}`)
+			gomega.Expect(ok).To(gomega.BeTrue())
+			gomega.Expect(command.ID).To(gomega.Equal("8"))
+			gomega.Expect(command.Command).To(gomega.BeEmpty())
+			gomega.Expect(command.Text).To(gomega.Equal("hello"))
+		})
+
+		ginkgo.It("Rejects invalid JSON", func() {
+			_, ok := parseJSONCommand(`{"command": `)
+			gomega.Expect(ok).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("writeEvent", func() {
+
+		ginkgo.It("Writes the text as is in the text protocol", func() {
+			line := readEvent(protocolText, data.Event{Type: data.EventInfo, Text: "Switched to Tech!!\n"}, "")
+			gomega.Expect(line).To(gomega.Equal("Switched to Tech!!\n"))
+		})
+
+		ginkgo.It("Writes command results with the request id in the json protocol", func() {
+			line := readEvent(protocolJSON, data.Event{Type: data.EventError, Text: "Options missing!!!\n"}, "7")
+			gomega.Expect(line).To(gomega.Equal(`{"type":"error","id":"7","text":"Options missing!!!"}` + "\n"))
+		})
+
+		ginkgo.It("Writes messages without request id in the json protocol", func() {
+			event := data.Event{
+				Type:    data.EventMessage,
+				Text:    "formatted\n",
+				Message: data.Message{ID: 1, UserName: "bob", RoomName: "Tech", Text: "hi"},
+			}
+			line := readEvent(protocolJSON, event, "7")
+			gomega.Expect(line).To(gomega.ContainSubstring(`"type":"message","text":"formatted","message":{"id":1`))
+			gomega.Expect(line).NotTo(gomega.ContainSubstring(`"id":"7"`))
+		})
+
+		ginkgo.It("Writes the events of other users without request id in the json protocol", func() {
+			for _, eventType := range []string{data.EventInvitation, data.EventRoomDeleted, data.EventRoomSwitched, data.EventKilled} {
+				line := readEvent(protocolJSON, data.Event{Type: eventType, RoomName: "Tech", Text: "Bye!!\n"}, "7")
+				gomega.Expect(line).To(gomega.HavePrefix(`{"type":"` + eventType + `","text":`))
+				gomega.Expect(line).NotTo(gomega.ContainSubstring(`"id":"7"`))
+			}
+		})
+
+		ginkgo.It("Writes the state of the presence and typing events in the json protocol", func() {
+			line := readEvent(protocolJSON, data.Event{Type: data.EventPresence, UserName: "bob", Presence: "away", Text: "lunch"}, "")
+			gomega.Expect(line).To(gomega.Equal(`{"type":"presence","text":"bob is now away: lunch!!","user":"bob","state":"away","status":"lunch"}` + "\n"))
+			line = readEvent(protocolJSON, data.Event{Type: data.EventTyping, UserName: "bob", RoomName: "Tech"}, "")
+			gomega.Expect(line).To(gomega.ContainSubstring(`"room":"Tech","user":"bob","typing":false}`))
+		})
+	})
+})
@@ -0,0 +1,260 @@
+package connections
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/health"
+)
+
+// formatError renders an error of the chat server for the room the command referred to
+func formatError(err error, roomName string) string {
+	switch err {
+	case chatserver.ErrRoomNotFound:
+		return "Room " + roomName + " not found!!\n"
+	case chatserver.ErrRoomArchived:
+		return "Room " + roomName + " is archived!!\n"
+	case chatserver.ErrRoomNotArchived:
+		return "Room " + roomName + " is not archived!!\n"
+	case chatserver.ErrAlreadySubscribed:
+		return "Already subscribed to room " + roomName + "!!\n"
+	case chatserver.ErrNotSubscribed:
+		return "Not subscribed to " + roomName + "!!\n"
+	case chatserver.ErrAlreadyInRoom:
+		return "Already in room " + roomName + "!!\n"
+	case chatserver.ErrInviteOnly:
+		return "Room " + roomName + " is invite only!!\n"
+	case chatserver.ErrIncorrectPassword:
+		return "Incorrect password for room " + roomName + "!!\n"
+	case chatserver.ErrPasswordMissing:
+		return "Password missing for room " + roomName + "!!\n"
+	case chatserver.ErrNoInvitation:
+		return "No invitation to room " + roomName + "!!\n"
+	case chatserver.ErrNotCreator:
+		return "Only the creator of " + roomName + " can change it!!\n"
+	case chatserver.ErrRoomNameInvalid:
+		return "Room name " + roomName + " is not valid, it cannot be empty or a number!!\n"
+	}
+	return err.Error() + "!!\n"
+}
+
+// formatRooms renders the rooms as a table, the creators are the names of the creators of the rooms by id
+func formatRooms(rooms []data.Room, creators map[int]string) string {
+	var info bytes.Buffer
+	info.WriteString("List of rooms: \n")
+	writer := tabwriter.NewWriter(&info, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tVISIBILITY\tCREATOR\tCREATED\tTOPIC\tMETADATA")
+	for _, room := range rooms {
+		fmt.Fprintf(writer, "%d\t#%s\t%s\t@%s\t%s\t%s\t%s\n", room.ID, room.Name, room.Visibility,
+			creators[room.CreatorID], formatCreatedAt(room.CreatedAt), room.Topic, formatMetadata(room.Metadata))
+	}
+	writer.Flush()
+	return info.String()
+}
+
+// formatRoomDetails renders the topic, the description, the creator, the creation time and the metadata of a room
+func formatRoomDetails(room data.Room, creator string) string {
+	var details string
+	if room.Topic != "" {
+		details = details + "Topic: " + room.Topic + "\n"
+	}
+	if room.Description != "" {
+		details = details + "Description: " + room.Description + "\n"
+	}
+	details = details + "Created by @" + creator + " on " + formatCreatedAt(room.CreatedAt) + "\n"
+	if len(room.Metadata) > 0 {
+		details = details + "Metadata: " + formatMetadata(room.Metadata) + "\n"
+	}
+	return details
+}
+
+// formatCreatedAt renders the creation time of a room, the time stamps of the chat server are kept as they are
+// when they cannot be parsed
+func formatCreatedAt(createdAt string) string {
+	created, err := time.ParseInLocation("20060102150405", createdAt, time.Local)
+	if err != nil {
+		return createdAt
+	}
+	return created.Format("2006-01-02 15:04")
+}
+
+// formatMetadata renders the metadata of a room as key=value pairs sorted by key
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + metadata[key]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// formatWelcome renders the welcome message of a room, it is empty when the room has none
+func formatWelcome(room data.Room) string {
+	if room.Welcome == "" {
+		return ""
+	}
+	return "#" + room.Name + ": " + room.Welcome + "\n"
+}
+
+// formatSummary renders the rooms of a user who logs in with the unread messages and the members who are online
+func formatSummary(summaries []data.RoomSummary) string {
+	var info bytes.Buffer
+	info.WriteString("Your rooms:\n")
+	writer := tabwriter.NewWriter(&info, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "ROOM\tUNREAD\tONLINE")
+	for _, summary := range summaries {
+		online := make([]string, len(summary.Online))
+		for i, name := range summary.Online {
+			online[i] = "@" + name
+		}
+		fmt.Fprintf(writer, "#%s\t%d\t%s\n", summary.Name, summary.Unread, strings.Join(online, ", "))
+	}
+	writer.Flush()
+	return info.String()
+}
+
+// renderEvent renders the text of the events the chat server sends without text
+func renderEvent(event data.Event) data.Event {
+	switch event.Type {
+	case data.EventInvitation:
+		event.Text = event.UserName + " invited you to " + event.RoomName +
+			", type /accept " + strconv.Itoa(event.RoomID) + " to join!!\n"
+	case data.EventRoomDeleted:
+		event.Text = "Room " + event.RoomName + " has been deleted!!\n"
+	case data.EventRoomSwitched:
+		event.Text = "Switched to " + event.RoomName + "!!\n"
+	case data.EventPresence:
+		event.Text = formatPresence(event.UserName, event.Presence, event.Text)
+	case data.EventNick:
+		event.Text = event.Text + " is now known as " + event.UserName + "!!\n"
+	case data.EventTyping:
+		event.Text = formatTyping(event.UserName, event.RoomName, event.Typing)
+	case data.EventKilled:
+		reason := event.Text
+		event.Text = "You have been disconnected by an admin"
+		if reason != "" {
+			event.Text = event.Text + ": " + reason
+		}
+		event.Text = event.Text + "!!!\n"
+	}
+	return event
+}
+
+// formatPresence renders a presence change of a user
+func formatPresence(userName string, state string, status string) string {
+	text := userName + " is now " + state
+	if status != "" {
+		text = text + ": " + status
+	}
+	return text + "!!\n"
+}
+
+// formatTyping renders a member of a room who started or stopped typing
+func formatTyping(userName string, roomName string, typing bool) string {
+	if typing {
+		return userName + " is typing in " + roomName + "!!\n"
+	}
+	return userName + " stopped typing in " + roomName + "!!\n"
+}
+
+// formatWho renders the connected users as a table
+func formatWho(connections []data.Connection) string {
+	var info bytes.Buffer
+	info.WriteString("Connected users: " + strconv.Itoa(len(connections)) + "\n")
+	writer := tabwriter.NewWriter(&info, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tTRANSPORT\tADDRESS\tCONNECTED")
+	for _, connection := range connections {
+		fmt.Fprintf(writer, "%d\t@%s\t%s\t%s\t%s\n", connection.UserID, connection.UserName, connection.Transport,
+			connection.Address, connection.ConnectedAt)
+	}
+	writer.Flush()
+	return info.String()
+}
+
+// formatWhois renders the details of a user
+func formatWhois(details data.UserDetails) string {
+	var info bytes.Buffer
+	fmt.Fprintf(&info, "User @%s (id %d)\n", details.Name, details.ID)
+	status := details.Presence
+	if details.Status != "" {
+		status = status + " (" + details.Status + ")"
+	}
+	if details.Bot {
+		status = status + ", bot"
+	}
+	if details.Admin {
+		status = status + ", admin"
+	}
+	fmt.Fprintf(&info, "Status: %s\nLast seen: %s\n", status, details.LastSeen)
+	info.WriteString(formatProfileFields(details.DisplayName, details.Bio, details.Timezone, details.LocalTime))
+	if details.Connection != nil {
+		fmt.Fprintf(&info, "Connection: %s from %s since %s\n", details.Connection.Transport, details.Connection.Address,
+			details.Connection.ConnectedAt)
+	}
+	if details.ActiveRoom != "" {
+		fmt.Fprintf(&info, "Active room: #%s\n", details.ActiveRoom)
+	}
+	if details.Rooms != nil { // only shown to the admins
+		fmt.Fprintf(&info, "Rooms: %d\n", len(details.Rooms))
+		for _, room := range details.Rooms {
+			fmt.Fprintf(&info, "  #%s\n", room)
+		}
+	}
+	return info.String()
+}
+
+// formatProfile renders the profile of a user
+func formatProfile(profile data.Profile) string {
+	return "Profile of @" + profile.Name + "\n" +
+		formatProfileFields(profile.DisplayName, profile.Bio, profile.Timezone, profile.LocalTime)
+}
+
+// formatProfileFields renders the fields of a profile that are set, with the local time of the user
+func formatProfileFields(displayName string, bio string, timezone string, localTime string) string {
+	var info bytes.Buffer
+	if displayName != "" {
+		fmt.Fprintf(&info, "Display name: %s\n", displayName)
+	}
+	if bio != "" {
+		fmt.Fprintf(&info, "Bio: %s\n", bio)
+	}
+	if timezone != "" {
+		if local, err := time.Parse(time.RFC3339, localTime); err == nil {
+			timezone = timezone + " (local time " + local.Format("15:04") + ")"
+		}
+		fmt.Fprintf(&info, "Timezone: %s\n", timezone)
+	}
+	return info.String()
+}
+
+// formatStats renders the state of the server
+func formatStats(status health.Status) string {
+	var info bytes.Buffer
+	fmt.Fprintf(&info, "Version: %s\nUptime: %s\nGoroutines: %d\nMessages: %d\n", status.Version, status.Uptime,
+		status.Goroutines, status.Messages)
+	transports := make([]string, 0, len(status.Users))
+	for name := range status.Users {
+		transports = append(transports, name)
+	}
+	sort.Strings(transports)
+	for _, name := range transports {
+		fmt.Fprintf(&info, "Users on %s: %d\n", name, status.Users[name])
+	}
+	members := 0
+	for _, room := range status.Rooms {
+		members = members + room.Members
+	}
+	fmt.Fprintf(&info, "Rooms: %d with %d members\n", len(status.Rooms), members)
+	return info.String()
+}
@@ -0,0 +1,204 @@
+package health
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+)
+
+// Check returns an error when a dependency of the server is not ready
+type Check func() error
+
+// Transport is a listener whose connected users are reported, e.g. the telnet and IRC listeners
+type Transport interface {
+	Connections() []data.Connection
+}
+
+// RoomStatus is the membership count of a room
+type RoomStatus struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Members  int    `json:"members"`
+	Archived bool   `json:"archived"`
+}
+
+// Status describes the running server for the operators
+type Status struct {
+	Version       string                       `json:"version"`
+	StartedAt     string                       `json:"startedAt"`
+	Uptime        string                       `json:"uptime"`
+	UptimeSeconds int64                        `json:"uptimeSeconds"`
+	Goroutines    int                          `json:"goroutines"`
+	Users         map[string]int               `json:"users"` // connected users per transport
+	Connections   map[string][]data.Connection `json:"connections"`
+	Rooms         []RoomStatus                 `json:"rooms"`
+	Messages      int                          `json:"messages"` // published since the start
+	Checks        map[string]string            `json:"checks"`
+}
+
+// Monitor tells whether the server is ready and describes its state, a nil Monitor is always ready
+type Monitor struct {
+	version     string
+	startedAt   time.Time
+	chatService chatserver.Service
+	checkNames  []string
+	checks      map[string]Check
+	transports  map[string]Transport
+	sync.Mutex
+}
+
+// NewMonitor returns a Monitor of the chat server, the uptime is counted from now
+func NewMonitor(version string, chatService chatserver.Service) *Monitor {
+	return &Monitor{
+		version:     version,
+		startedAt:   time.Now(),
+		chatService: chatService,
+		checks:      map[string]Check{},
+		transports:  map[string]Transport{},
+	}
+}
+
+// AddCheck adds a check the server must pass to be ready
+func (monitor *Monitor) AddCheck(name string, check Check) {
+	monitor.Lock()
+	defer monitor.Unlock()
+	if _, found := monitor.checks[name]; !found {
+		monitor.checkNames = append(monitor.checkNames, name)
+	}
+	monitor.checks[name] = check
+}
+
+// AddTransport adds a listener whose connected users are reported under the name
+func (monitor *Monitor) AddTransport(name string, transport Transport) {
+	monitor.Lock()
+	defer monitor.Unlock()
+	monitor.transports[name] = transport
+}
+
+// Ready runs the checks, it returns the result of each check, "ok" or the error
+func (monitor *Monitor) Ready() (bool, map[string]string) {
+	results := map[string]string{}
+	if monitor == nil {
+		return true, results
+	}
+	monitor.Lock()
+	names := append([]string{}, monitor.checkNames...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = monitor.checks[name]
+	}
+	monitor.Unlock()
+
+	ready := true
+	for i, name := range names {
+		if err := checks[i](); err != nil {
+			results[name] = err.Error()
+			ready = false
+		} else {
+			results[name] = "ok"
+		}
+	}
+	return ready, results
+}
+
+// Status returns the state of the server
+func (monitor *Monitor) Status() Status {
+	if monitor == nil {
+		return Status{}
+	}
+	uptime := time.Since(monitor.startedAt)
+	_, checks := monitor.Ready()
+	status := Status{
+		Version:       monitor.version,
+		StartedAt:     monitor.startedAt.UTC().Format(time.RFC3339),
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		Users:         map[string]int{},
+		Connections:   map[string][]data.Connection{},
+		Rooms:         []RoomStatus{},
+		Checks:        checks,
+	}
+
+	monitor.Lock()
+	transports := map[string]Transport{}
+	for name, transport := range monitor.transports {
+		transports[name] = transport
+	}
+	monitor.Unlock()
+	for name, transport := range transports {
+		connections := transport.Connections()
+		status.Users[name] = len(connections)
+		status.Connections[name] = connections
+	}
+
+	for _, room := range monitor.chatService.GetRooms() {
+		status.Rooms = append(status.Rooms, RoomStatus{ID: room.ID, Name: room.Name, Members: len(room.Users), Archived: room.Archived})
+	}
+	sort.Slice(status.Rooms, func(i, j int) bool { return status.Rooms[i].ID < status.Rooms[j].ID })
+	status.Messages = len(monitor.chatService.GetMessages())
+	return status
+}
+
+// Who returns the users connected to every transport ordered by user id
+func (monitor *Monitor) Who() []data.Connection {
+	connections := []data.Connection{}
+	if monitor == nil {
+		return connections
+	}
+	monitor.Lock()
+	transports := make([]Transport, 0, len(monitor.transports))
+	for _, transport := range monitor.transports {
+		transports = append(transports, transport)
+	}
+	monitor.Unlock()
+	for _, transport := range transports {
+		connections = append(connections, transport.Connections()...)
+	}
+	sort.Slice(connections, func(i, j int) bool { return connections[i].UserID < connections[j].UserID })
+	return connections
+}
+
+// Whois describes a user with its profile, the rooms it is a member of and its connection
+func (monitor *Monitor) Whois(userID int) (data.UserDetails, bool) {
+	if monitor == nil {
+		return data.UserDetails{}, false
+	}
+	user, found := monitor.chatService.GetUser(userID)
+	if !found {
+		return data.UserDetails{}, false
+	}
+	details := data.UserDetails{
+		ID:     user.ID,
+		Name:   user.Name,
+		Online: !user.Dead,
+		Bot:    user.Bot,
+		Admin:  user.Admin,
+		Rooms:  []string{},
+	}
+	if profile, found := monitor.chatService.GetProfile(user.ID); found {
+		details.DisplayName, details.Bio, details.Timezone, details.LocalTime = profile.DisplayName, profile.Bio, profile.Timezone, profile.LocalTime
+	}
+	if presence, found := monitor.chatService.GetPresence(user.ID); found {
+		details.Presence, details.Status, details.LastSeen = presence.State, presence.Status, presence.LastSeen
+	}
+	for _, room := range monitor.chatService.GetRooms() {
+		if _, member := room.Users[user.ID]; member {
+			details.Rooms = append(details.Rooms, room.Name)
+		}
+		if room.ID == user.ActiveRoom {
+			details.ActiveRoom = room.Name
+		}
+	}
+	for _, connection := range monitor.Who() {
+		if connection.UserID == user.ID && !user.Dead {
+			connection := connection
+			details.Connection = &connection
+		}
+	}
+	return details, true
+}
@@ -0,0 +1,59 @@
+package health
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+)
+
+func TestMonitor(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Health Monitor unit Test Suite")
+}
+
+// transportStub reports fixed connections
+type transportStub []data.Connection
+
+func (stub transportStub) Connections() []data.Connection {
+	return stub
+}
+
+var _ = ginkgo.Describe("Monitor", func() {
+
+	ginkgo.It("should be ready only when every check passes", func() {
+		monitor := NewMonitor("1.0.0", &chatserver.ServiceMock{})
+		monitor.AddCheck("telnet", func() error { return nil })
+		ready, checks := monitor.Ready()
+		gomega.Expect(ready).To(gomega.BeTrue())
+		gomega.Expect(checks).To(gomega.Equal(map[string]string{"telnet": "ok"}))
+
+		monitor.AddCheck("storage", func() error { return errors.New("read-only file system") })
+		ready, checks = monitor.Ready()
+		gomega.Expect(ready).To(gomega.BeFalse())
+		gomega.Expect(checks).To(gomega.Equal(map[string]string{"telnet": "ok", "storage": "read-only file system"}))
+	})
+
+	ginkgo.It("should report the users per transport and the rooms", func() {
+		monitor := NewMonitor("1.0.0", &chatserver.ServiceMock{})
+		monitor.AddTransport("telnet", transportStub{{UserID: 3, UserName: "ann", Transport: "telnet"}})
+		monitor.AddTransport("irc", transportStub{})
+
+		status := monitor.Status()
+		gomega.Expect(status.Version).To(gomega.Equal("1.0.0"))
+		gomega.Expect(status.Goroutines).To(gomega.BeNumerically(">", 0))
+		gomega.Expect(status.Users).To(gomega.Equal(map[string]int{"telnet": 1, "irc": 0}))
+		gomega.Expect(status.Connections["telnet"][0].UserName).To(gomega.Equal("ann"))
+		gomega.Expect(status.Rooms).To(gomega.Equal([]RoomStatus{{ID: 0, Name: "Default"}, {ID: 1, Name: "Tech"}}))
+	})
+
+	ginkgo.It("should always be ready when it is nil", func() {
+		var monitor *Monitor
+		ready, _ := monitor.Ready()
+		gomega.Expect(ready).To(gomega.BeTrue())
+	})
+})
@@ -0,0 +1,129 @@
+package irc
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Numeric replies used by the IRC listener
+const (
+	rplWelcome          = "001"
+	rplYourHost         = "002"
+	rplUnAway           = "305"
+	rplNowAway          = "306"
+	rplListStart        = "321"
+	rplList             = "322"
+	rplListEnd          = "323"
+	rplNoTopic          = "331"
+	rplTopic            = "332"
+	rplNameReply        = "353"
+	rplEndOfNames       = "366"
+	rplMotd             = "372"
+	rplMotdStart        = "375"
+	rplEndOfMotd        = "376"
+	rplYoureOper        = "381"
+	errNoSuchNick       = "401"
+	errNoSuchChannel    = "403"
+	errCannotSendToChan = "404"
+	errUnknownCommand   = "421"
+	errNoMotd           = "422"
+	errNoNicknameGiven  = "431"
+	errErroneusNickname = "432"
+	errNicknameInUse    = "433"
+	errNotOnChannel     = "442"
+	errNotRegistered    = "451"
+	errNeedMoreParams   = "461"
+	errAlreadyRegistred = "462"
+	errPasswdMismatch   = "464"
+	errBadChannelKey    = "475"
+	errInviteOnlyChan   = "473"
+)
+
+// message is a parsed IRC message
+type message struct {
+	prefix  string
+	command string
+	params  []string
+}
+
+// parseMessage parses a line sent by an IRC client
+func parseMessage(line string) (message, bool) {
+	var msg message
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, ":") {
+		parts := strings.SplitN(line[1:], " ", 2)
+		if len(parts) < 2 {
+			return msg, false
+		}
+		msg.prefix = parts[0]
+		line = parts[1]
+	}
+
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, ":") && msg.command != "" { // the trailing parameter takes the rest of the line
+			msg.params = append(msg.params, line[1:])
+			break
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if msg.command == "" {
+			msg.command = strings.ToUpper(parts[0])
+		} else if parts[0] != "" {
+			msg.params = append(msg.params, parts[0])
+		}
+		if len(parts) < 2 {
+			break
+		}
+		line = parts[1]
+	}
+	return msg, msg.command != ""
+}
+
+// formatMessage formats an IRC message, the last parameter is always sent as the trailing parameter
+func formatMessage(prefix string, command string, params ...string) string {
+	var line strings.Builder
+	if prefix != "" {
+		line.WriteString(":" + prefix + " ")
+	}
+	line.WriteString(command)
+	for i, param := range params {
+		if i == len(params)-1 {
+			line.WriteString(" :" + param)
+		} else {
+			line.WriteString(" " + param)
+		}
+	}
+	line.WriteString("\r\n")
+	return line.String()
+}
+
+// toNick turns a chat server user name into a valid nick
+func toNick(name string) string {
+	return strings.Replace(name, " ", "_", -1)
+}
+
+// channelEscaper escapes the characters of the room names that cannot be part of a channel name, and the escape
+// character itself so that fromChannel gets the room name back
+var channelEscaper = strings.NewReplacer("%", "%25", " ", "%20", ",", "%2C", ":", "%3A", "\a", "%07")
+
+// toChannel turns a chat server room name into a channel name
+func toChannel(roomName string) string {
+	return "#" + channelEscaper.Replace(roomName)
+}
+
+// fromChannel turns a channel name sent by a client back into the reference of a chat server room,
+// channels that were not escaped by toChannel are kept as they are
+func fromChannel(channel string) string {
+	if reference, err := url.PathUnescape(channel); err == nil {
+		return reference
+	}
+	return channel
+}
+
+// isNickValid checks if the nick can be used as a chat server user name
+func isNickValid(nick string) bool {
+	if nick == "" || len(nick) > 30 || strings.ContainsAny(nick, " ,*?!@#:") {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,11 @@
+package irc
+
+// transport is the transport label of the IRC listener in the listener metrics
+const transport = "irc"
+
+// knownCommands are counted by name, the others as unknown
+var knownCommands = map[string]bool{
+	"PING": true, "PONG": true, "QUIT": true, "NICK": true, "USER": true, "JOIN": true,
+	"PART": true, "PRIVMSG": true, "NOTICE": true, "LIST": true, "NAMES": true, "TOPIC": true,
+	"OPER": true, "MOTD": true, "AWAY": true,
+}
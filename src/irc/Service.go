@@ -0,0 +1,16 @@
+package irc
+
+import (
+	"context"
+
+	"chatServer/src/chatserver/data"
+)
+
+// Service interface for the IRC listener
+type Service interface {
+	HandleConnections()
+	StopAccepting()
+	Shutdown(ctx context.Context) error
+	Listening() bool
+	Connections() []data.Connection
+}
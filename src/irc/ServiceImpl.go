@@ -0,0 +1,756 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chatServer/src/admission"
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/src/logging"
+	"chatServer/src/metrics"
+	"chatServer/src/ratelimit"
+)
+
+// ServiceImpl struct for the IRC listener
+type ServiceImpl struct {
+	chatService chatserver.Service
+	config      *config.Config
+	limits      *ratelimit.Guard
+	admission   *admission.Controller
+	logger      *logging.Logger
+	listener    net.Listener
+	sessions    map[*session]bool
+	running     sync.WaitGroup
+	stopping    bool
+	closing     bool
+	sync.Mutex
+}
+
+// session holds the state of a single IRC client connection
+type session struct {
+	conn       net.Conn
+	server     string
+	nick       string
+	userName   string
+	registered bool
+	user       data.User
+	done       chan struct{}
+	stop       chan struct{} // closed when the server shuts down, the writer flushes the output and closes the connection
+	writing    bool          // guarded by the lock of the service
+	connected  time.Time
+	logger     *logging.Logger // correlates the entries of the connection
+	sync.Mutex
+}
+
+// NewServiceImpl returns ServiceImpl, the limits and the admission are shared with the other listeners and nil disables them,
+// a nil logger discards the server log
+func NewServiceImpl(chatService chatserver.Service, config *config.Config, limits *ratelimit.Guard, admission *admission.Controller, logger *logging.Logger) *ServiceImpl {
+	service := &ServiceImpl{
+		chatService: chatService,
+		config:      config,
+		limits:      limits,
+		admission:   admission,
+		logger:      logger.With("transport", transport),
+		sessions:    map[*session]bool{},
+	}
+	chatService.AddObserver(service.relayMembership)
+	return service
+}
+
+// relayMembership passes the joins and leaves of the rooms to the other registered members of the room, they are
+// only sent to observers by the chat server and are dropped rather than delayed for slow clients
+func (service *ServiceImpl) relayMembership(event data.Event) {
+	if event.Type != data.EventJoin && event.Type != data.EventLeave {
+		return
+	}
+	room, found := service.chatService.GetRoom(event.RoomID)
+	if !found {
+		return
+	}
+	service.Lock()
+	defer service.Unlock()
+	for s := range service.sessions {
+		if !s.writing { // the user of the session is set once it registered
+			continue
+		}
+		if _, member := room.Users[s.user.ID]; !member || s.user.ID == event.UserID {
+			continue
+		}
+		select {
+		case s.user.Output <- event:
+		default:
+		}
+	}
+}
+
+// HandleConnections listens on the IRC port and handles the incoming connections until StopAccepting is called
+func (service *ServiceImpl) HandleConnections() {
+	ln, err := net.Listen(service.config.ConnectionType, service.config.Host+":"+service.config.IRCPort)
+	if err != nil {
+		service.logger.Error("Error listening for IRC clients", "address", service.config.Host+":"+service.config.IRCPort, "error", err)
+		return
+	}
+
+	defer ln.Close()
+
+	service.Lock()
+	service.listener = ln
+	if service.stopping { // stopped before the listener was ready
+		ln.Close()
+	}
+	service.Unlock()
+	service.logger.Info("Listening for IRC clients", "address", ln.Addr().String())
+
+	err = service.admission.Serve(ln, rejectConnection, service.handleConnection)
+	service.logger.Info("Stopped accepting IRC connections", "reason", err)
+}
+
+// Listening checks if the listener accepts connections
+func (service *ServiceImpl) Listening() bool {
+	service.Lock()
+	defer service.Unlock()
+	return service.listener != nil && !service.stopping
+}
+
+// Connections returns the users connected over IRC, the clients that have not registered yet are left out
+func (service *ServiceImpl) Connections() []data.Connection {
+	service.Lock()
+	defer service.Unlock()
+	connections := []data.Connection{}
+	for s := range service.sessions {
+		if !s.writing { // the writer starts once the client has registered
+			continue
+		}
+		connections = append(connections, data.Connection{
+			UserID:      s.user.ID,
+			UserName:    s.currentNick(),
+			Transport:   transport,
+			Address:     s.conn.RemoteAddr().String(),
+			ConnectedAt: s.connected.UTC().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(connections, func(i, j int) bool { return connections[i].UserID < connections[j].UserID })
+	return connections
+}
+
+// StopAccepting closes the listener, the connected clients stay connected
+func (service *ServiceImpl) StopAccepting() {
+	service.Lock()
+	defer service.Unlock()
+	service.stopping = true
+	if service.listener != nil {
+		service.listener.Close()
+	}
+}
+
+// Shutdown stops accepting and closes every connection once its pending output has been written,
+// the connections left when the context is done are closed right away
+func (service *ServiceImpl) Shutdown(ctx context.Context) error {
+	service.StopAccepting()
+
+	service.Lock()
+	if !service.closing {
+		service.closing = true
+		for s := range service.sessions {
+			service.stopSession(s)
+		}
+	}
+	service.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		service.running.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		service.Lock()
+		for s := range service.sessions {
+			s.conn.Close()
+		}
+		service.Unlock()
+		return ctx.Err()
+	}
+}
+
+// stopSession asks the writer of a registered session to flush and close the connection, the others are closed right away
+func (service *ServiceImpl) stopSession(s *session) {
+	if s.writing {
+		close(s.stop)
+		return
+	}
+	go func() {
+		s.send(formatMessage("", "ERROR", "Server is shutting down"))
+		s.conn.Close()
+	}()
+}
+
+// track records a new session, it returns false when the server is shutting down
+func (service *ServiceImpl) track(s *session) bool {
+	service.Lock()
+	defer service.Unlock()
+	if service.stopping {
+		return false
+	}
+	service.sessions[s] = true
+	service.running.Add(1)
+	return true
+}
+
+// untrack forgets a session which has been closed
+func (service *ServiceImpl) untrack(s *session) {
+	service.Lock()
+	delete(service.sessions, s)
+	service.Unlock()
+	service.running.Done()
+}
+
+// startWriter starts writing the events of a registered user, it is stopped right away when the server is already closing
+func (service *ServiceImpl) startWriter(s *session) {
+	service.Lock()
+	defer service.Unlock()
+	s.writing = true
+	go service.handleWriteToConnection(s, s.user.Output)
+	if service.closing {
+		close(s.stop)
+	}
+}
+
+// rejectConnection tells an IRC client why its connection is closed
+func rejectConnection(conn net.Conn, err error) {
+	metrics.ConnectionsRejected.Inc(transport)
+	io.WriteString(conn, formatMessage("", "ERROR", err.Error()))
+}
+
+// handleConnection reads the messages of an IRC client until it quits or disconnects
+func (service *ServiceImpl) handleConnection(conn net.Conn) {
+	s := &session{
+		conn:      conn,
+		server:    service.config.Host,
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+		connected: time.Now(),
+		logger:    service.logger.With("conn", logging.NewID(), "remote", conn.RemoteAddr().String()),
+	}
+	s.logger.Info("A new IRC client connected")
+	if !service.track(s) {
+		conn.Close()
+		return
+	}
+	defer service.untrack(s)
+	defer service.closeSession(s)
+	metrics.ConnectionsAccepted.Inc(transport)
+	metrics.ConnectionsOpen.Inc(transport)
+	defer metrics.ConnectionsOpen.Dec(transport)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		msg, ok := parseMessage(scanner.Text())
+		if !ok {
+			continue
+		}
+		metrics.LinesReceived.Inc(transport)
+		if knownCommands[msg.command] {
+			metrics.CommandsHandled.Inc(transport, msg.command)
+		} else {
+			metrics.CommandsHandled.Inc(transport, "unknown")
+		}
+		if !service.handleMessage(s, msg) {
+			return
+		}
+	}
+}
+
+// closeSession removes the user of the session and closes the connection
+func (service *ServiceImpl) closeSession(s *session) {
+	s.logger.Info("The IRC client left", "duration", time.Since(s.connected).Round(time.Millisecond))
+	if s.registered {
+		service.chatService.RemoveUser(s.user.ID)
+		close(s.done)
+	}
+	s.conn.Close()
+}
+
+// handleMessage handles a single IRC message, it returns false when the client quits
+func (service *ServiceImpl) handleMessage(s *session, msg message) bool {
+	switch msg.command {
+	case "PING":
+		s.send(formatMessage(s.server, "PONG", s.server, getParam(msg, 0)))
+		return true
+	case "PONG":
+		return true
+	case "QUIT":
+		s.send(formatMessage("", "ERROR", "Closing link: "+s.nick))
+		return false
+	case "NICK", "USER":
+		service.register(s, msg)
+		return true
+	}
+
+	if !s.registered {
+		s.reply(errNotRegistered, "You have not registered")
+		return true
+	}
+	service.chatService.Touch(s.user.ID)
+
+	switch msg.command {
+	case "JOIN":
+		if len(msg.params) < 1 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			break
+		}
+		keys := strings.Split(getParam(msg, 1), ",")
+		for i, channel := range strings.Split(msg.params[0], ",") {
+			key := ""
+			if i < len(keys) {
+				key = keys[i]
+			}
+			service.join(s, channel, key)
+		}
+	case "PART":
+		if len(msg.params) < 1 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			break
+		}
+		for _, channel := range strings.Split(msg.params[0], ",") {
+			service.part(s, channel)
+		}
+	case "PRIVMSG", "NOTICE":
+		if len(msg.params) < 2 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			break
+		}
+		service.privmsg(s, msg.params[0], msg.params[1])
+	case "LIST":
+		service.list(s)
+	case "NAMES":
+		if len(msg.params) < 1 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			break
+		}
+		for _, channel := range strings.Split(msg.params[0], ",") {
+			service.names(s, channel)
+		}
+	case "TOPIC":
+		if len(msg.params) < 1 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			break
+		}
+		service.topic(s, msg)
+	case "MOTD":
+		service.sendMOTD(s)
+	case "AWAY":
+		service.away(s, getParam(msg, 0))
+	case "OPER":
+		if len(msg.params) < 2 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			break
+		}
+		service.oper(s, msg.params[0], msg.params[1])
+	default:
+		s.reply(errUnknownCommand, msg.command, "Unknown command")
+	}
+	return true
+}
+
+// register handles NICK and USER, the user is created once both have been received
+func (service *ServiceImpl) register(s *session, msg message) {
+	if msg.command == "USER" {
+		if s.registered {
+			s.reply(errAlreadyRegistred, "You may not reregister")
+			return
+		}
+		if len(msg.params) < 4 {
+			s.reply(errNeedMoreParams, msg.command, "Not enough parameters")
+			return
+		}
+		s.userName = msg.params[0]
+	} else {
+		nick := getParam(msg, 0)
+		if nick == "" {
+			s.reply(errNoNicknameGiven, "No nickname given")
+			return
+		}
+		if s.registered {
+			service.changeNick(s, nick)
+			return
+		}
+		if !isNickValid(nick) {
+			s.reply(errErroneusNickname, nick, "Erroneous nickname")
+			return
+		}
+		if !service.checkNick(s, nick, service.chatService.CheckName(nick)) {
+			return
+		}
+		s.nick = nick
+	}
+
+	if s.nick == "" || s.userName == "" {
+		return
+	}
+	user, err := service.chatService.RegisterUser(s.nick)
+	if !service.checkNick(s, s.nick, err) { // taken since the NICK, the client sends another one
+		s.nick = ""
+		return
+	}
+	s.user = user
+	s.registered = true
+	s.logger = s.logger.With("userId", s.user.ID, "user", s.user.Name)
+	s.logger.Info("The IRC client registered")
+	service.startWriter(s)
+
+	s.reply(rplWelcome, "Welcome to the chat server "+s.nick)
+	s.reply(rplYourHost, "Your host is "+s.server)
+	service.sendMOTD(s)
+	// users start in the Default room
+	if room, found := service.chatService.GetRoom(chatserver.DefaultRoomID); found {
+		service.sendJoin(s, room)
+	}
+	for _, summary := range service.chatService.GetSummary(s.user.ID) {
+		if summary.Unread > 0 {
+			s.send(formatMessage(s.server, "NOTICE", s.nick,
+				toChannel(summary.Name)+" has "+strconv.Itoa(summary.Unread)+" unread messages since you left"))
+		}
+	}
+}
+
+// changeNick renames the user, the client and the members of its channels get a NICK message
+func (service *ServiceImpl) changeNick(s *session, nick string) {
+	if !isNickValid(nick) {
+		s.reply(errErroneusNickname, nick, "Erroneous nickname")
+		return
+	}
+	prefix := s.prefix()
+	user, err := service.chatService.Rename(s.user.ID, nick)
+	if !service.checkNick(s, nick, err) {
+		return
+	}
+	service.Lock() // the user of the session is read under the lock by the relay of the joins and leaves
+	s.user = user
+	service.Unlock()
+	s.setNick(user.Name)
+	s.logger.Info("The IRC client changed its nick", "to", user.Name)
+	s.send(formatMessage(prefix, "NICK", user.Name))
+}
+
+// sendMOTD sends the message of the day, one reply per line
+func (service *ServiceImpl) sendMOTD(s *session) {
+	motd := service.chatService.GetMOTD()
+	if motd == "" {
+		s.reply(errNoMotd, "MOTD File is missing")
+		return
+	}
+	s.reply(rplMotdStart, "- "+s.server+" Message of the day - ")
+	for _, line := range strings.Split(motd, "\n") {
+		s.reply(rplMotd, "- "+strings.TrimRight(line, "\r"))
+	}
+	s.reply(rplEndOfMotd, "End of MOTD command")
+}
+
+// checkNick replies to the client when the nick was refused by the chat server, it returns false in that case
+func (service *ServiceImpl) checkNick(s *session, nick string, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case chatserver.ErrNameInUse:
+		s.reply(errNicknameInUse, nick, "Nickname is already in use")
+	default:
+		s.reply(errErroneusNickname, nick, "Erroneous nickname")
+	}
+	return false
+}
+
+// join subscribes the user to the room of the channel, creating the room when it does not exist
+func (service *ServiceImpl) join(s *session, channel string, key string) {
+	if !strings.HasPrefix(channel, "#") || len(channel) < 2 {
+		s.reply(errNoSuchChannel, channel, "No such channel")
+		return
+	}
+	room, found := service.chatService.FindRoom(s.user.ID, fromChannel(channel))
+	var err error
+	if !found {
+		room, err = service.chatService.CreateRoom(strings.TrimPrefix(fromChannel(channel), "#"), s.user.ID, "", "")
+	} else if _, member := room.Users[s.user.ID]; !member {
+		room, err = service.chatService.Subscribe(s.user.ID, room.ID, key)
+	}
+
+	switch err {
+	case nil:
+		service.sendJoin(s, room)
+	case chatserver.ErrIncorrectPassword:
+		s.reply(errBadChannelKey, channel, "Cannot join channel (+k)")
+	case chatserver.ErrInviteOnly:
+		s.reply(errInviteOnlyChan, channel, "Cannot join channel (+i)")
+	default:
+		s.reply(errNoSuchChannel, channel, err.Error())
+	}
+}
+
+// sendJoin confirms the join to the client followed by the topic and names of the channel
+func (service *ServiceImpl) sendJoin(s *session, room data.Room) {
+	s.send(formatMessage(s.prefix(), "JOIN", toChannel(room.Name)))
+	service.sendTopic(s, room)
+	service.names(s, toChannel(room.Name))
+	if room.Welcome != "" {
+		s.send(formatMessage(s.server, "NOTICE", toChannel(room.Name), room.Welcome))
+	}
+}
+
+// part unsubscribes the user from the room of the channel
+func (service *ServiceImpl) part(s *session, channel string) {
+	room, found := service.chatService.FindRoom(s.user.ID, fromChannel(channel))
+	if !found {
+		s.reply(errNoSuchChannel, channel, "No such channel")
+		return
+	}
+	if _, err := service.chatService.UnSubscribe(s.user.ID, room.ID); err != nil {
+		s.reply(errNotOnChannel, channel, "You're not on that channel")
+		return
+	}
+	s.send(formatMessage(s.prefix(), "PART", toChannel(room.Name)))
+}
+
+// privmsg publishes the text to the room of the channel
+func (service *ServiceImpl) privmsg(s *session, target string, text string) {
+	if !strings.HasPrefix(target, "#") {
+		s.reply(errNoSuchNick, target, "Direct messages are not supported")
+		return
+	}
+	room, found := service.chatService.FindRoom(s.user.ID, fromChannel(target))
+	if !found {
+		s.reply(errNoSuchChannel, target, "No such channel")
+		return
+	}
+	if _, member := room.Users[s.user.ID]; !member {
+		s.reply(errCannotSendToChan, target, "Cannot send to channel")
+		return
+	}
+	decision, remaining := service.limits.CheckMessage(strconv.Itoa(s.user.ID), ratelimit.HostIP(s.conn.RemoteAddr().String()))
+	if decision != ratelimit.Allow {
+		metrics.LinesRateLimited.Inc(transport)
+		if decision == ratelimit.Mute {
+			s.logger.Warn("The user is muted for flooding", "duration", remaining.Round(time.Second))
+		}
+		s.send(formatMessage(s.server, "NOTICE", s.nick, decision.Notice(remaining)))
+		return
+	}
+	if _, err := service.chatService.Publish(data.Input{
+		Room: room.ID,
+		Text: text,
+	}, s.user.ID, false); err != nil {
+		s.reply(errCannotSendToChan, target, err.Error())
+	}
+}
+
+// list lists the channels visible to the user
+func (service *ServiceImpl) list(s *session) {
+	s.reply(rplListStart, "Channel", "Users  Name")
+	for _, room := range service.chatService.GetVisibleRooms(s.user.ID) {
+		s.reply(rplList, toChannel(room.Name), strconv.Itoa(len(service.memberNicks(room))), room.Topic)
+	}
+	s.reply(rplListEnd, "End of /LIST")
+}
+
+// names lists the nicks of the members of the channel
+func (service *ServiceImpl) names(s *session, channel string) {
+	if room, found := service.chatService.FindRoom(s.user.ID, fromChannel(channel)); found {
+		s.reply(rplNameReply, "=", toChannel(room.Name), strings.Join(service.memberNicks(room), " "))
+	}
+	s.reply(rplEndOfNames, channel, "End of /NAMES list")
+}
+
+// topic shows the topic of the channel or changes it when a new topic is given
+func (service *ServiceImpl) topic(s *session, msg message) {
+	room, found := service.chatService.FindRoom(s.user.ID, fromChannel(msg.params[0]))
+	if !found {
+		s.reply(errNoSuchChannel, msg.params[0], "No such channel")
+		return
+	}
+	if len(msg.params) < 2 {
+		service.sendTopic(s, room)
+		return
+	}
+	if _, err := service.chatService.SetTopic(s.user.ID, room.ID, msg.params[1]); err != nil {
+		s.reply(errNotOnChannel, msg.params[0], err.Error())
+	}
+}
+
+// sendTopic sends the topic of the room to the client
+func (service *ServiceImpl) sendTopic(s *session, room data.Room) {
+	if room.Topic == "" {
+		s.reply(rplNoTopic, toChannel(room.Name), "No topic is set")
+	} else {
+		s.reply(rplTopic, toChannel(room.Name), room.Topic)
+	}
+}
+
+// memberNicks returns the nicks of the connected members of the room
+func (service *ServiceImpl) memberNicks(room data.Room) []string {
+	nicks := []string{}
+	for _, user := range service.chatService.GetUsers() {
+		if _, member := room.Users[user.ID]; member && !user.Dead && user.ID != chatserver.SystemUserID {
+			nicks = append(nicks, toNick(user.Name))
+		}
+	}
+	return nicks
+}
+
+// away marks the user away with the message, or online again without a message
+func (service *ServiceImpl) away(s *session, text string) {
+	if text == "" {
+		service.chatService.SetPresence(s.user.ID, data.PresenceOnline, "")
+		s.reply(rplUnAway, "You are no longer marked as being away")
+		return
+	}
+	service.chatService.SetPresence(s.user.ID, data.PresenceAway, text)
+	s.reply(rplNowAway, "You have been marked as being away")
+}
+
+// oper gives the admin role to the user when the name is the nick of the user and the password is the one configured for it
+func (service *ServiceImpl) oper(s *session, name string, password string) {
+	if !strings.EqualFold(name, s.nick) {
+		s.reply(errPasswdMismatch, "Password incorrect")
+		return
+	}
+	if _, err := service.chatService.Oper(s.user.ID, password); err != nil {
+		s.logger.Warn("Failed admin login")
+		s.reply(errPasswdMismatch, "Password incorrect")
+		return
+	}
+	s.logger.Info("The user became an admin")
+	s.reply(rplYoureOper, "You are now an IRC operator")
+}
+
+// handleWriteToConnection writes the events of the output of the user to the client as IRC messages, the output is
+// passed because the user of the session is replaced when it changes its nick
+func (service *ServiceImpl) handleWriteToConnection(s *session, output chan data.Event) {
+	for {
+		select {
+		case event := <-output:
+			service.writeEvent(s, event)
+		case <-s.stop:
+			// flush what was sent before the shutdown, e.g. the shutdown notice, closing the connection ends the read loop
+			for {
+				select {
+				case event := <-output:
+					service.writeEvent(s, event)
+				default:
+					s.send(formatMessage("", "ERROR", "Server is shutting down"))
+					s.conn.Close()
+					return
+				}
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// writeEvent translates an event into IRC messages
+func (service *ServiceImpl) writeEvent(s *session, event data.Event) {
+	if event.Type == data.EventMessage {
+		msg := event.Message
+		if msg.UserID == chatserver.SystemUserID {
+			s.send(formatMessage(s.server, "NOTICE", toChannel(msg.RoomName), msg.Text))
+		} else {
+			nick := toNick(msg.UserName)
+			s.send(formatMessage(nick+"!"+nick+"@"+s.server, "PRIVMSG", toChannel(msg.RoomName), msg.Text))
+		}
+		return
+	}
+	text := event.Text
+	switch event.Type {
+	case data.EventInvitation:
+		s.send(formatMessage(toNick(event.UserName)+"!"+toNick(event.UserName)+"@"+s.server, "INVITE", s.currentNick(), toChannel(event.RoomName)))
+		return
+	case data.EventNick:
+		s.send(formatMessage(toNick(event.Text)+"!"+toNick(event.Text)+"@"+s.server, "NICK", toNick(event.UserName)))
+		return
+	case data.EventJoin:
+		s.send(formatMessage(toNick(event.UserName)+"!"+toNick(event.UserName)+"@"+s.server, "JOIN", toChannel(event.RoomName)))
+		return
+	case data.EventLeave:
+		s.send(formatMessage(toNick(event.UserName)+"!"+toNick(event.UserName)+"@"+s.server, "PART", toChannel(event.RoomName)))
+		return
+	case data.EventRoomDeleted:
+		text = "Channel " + toChannel(event.RoomName) + " has been deleted"
+	case data.EventRoomSwitched:
+		return // IRC clients have no active channel
+	case data.EventTyping:
+		return // typing needs the message tags of IRCv3 which are not supported
+	case data.EventPresence:
+		text = toNick(event.UserName) + " is now " + event.Presence
+		if event.Text != "" {
+			text = text + ": " + event.Text
+		}
+	case data.EventKilled:
+		// closing the connection ends the read loop which removes the user
+		reason := "Killed"
+		if event.Text != "" {
+			reason = reason + " (" + event.Text + ")"
+		}
+		s.send(formatMessage("", "ERROR", reason))
+		s.logger.Warn("The client has been disconnected by an admin", "reason", event.Text)
+		s.conn.Close()
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			s.send(formatMessage(s.server, "NOTICE", s.currentNick(), line))
+		}
+	}
+}
+
+// getParam returns the parameter at the index or an empty string when it is missing
+func getParam(msg message, index int) string {
+	if index < len(msg.params) {
+		return msg.params[index]
+	}
+	return ""
+}
+
+// prefix returns the prefix identifying the user of the session
+func (s *session) prefix() string {
+	return s.currentNick() + "!" + s.userName + "@" + s.server
+}
+
+// currentNick returns the nick of the user, it changes with NICK while the writer uses it
+func (s *session) currentNick() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.nick
+}
+
+// setNick changes the nick of the user
+func (s *session) setNick(nick string) {
+	s.Lock()
+	defer s.Unlock()
+	s.nick = nick
+}
+
+// reply sends a numeric reply to the client
+func (s *session) reply(numeric string, params ...string) {
+	nick := s.currentNick()
+	if nick == "" {
+		nick = "*"
+	}
+	s.send(formatMessage(s.server, numeric, append([]string{nick}, params...)...))
+}
+
+// send writes a raw IRC line to the client
+func (s *session) send(line string) {
+	s.Lock()
+	defer s.Unlock()
+	io.WriteString(s.conn, line)
+}
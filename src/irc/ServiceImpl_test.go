@@ -0,0 +1,275 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/testhelpers"
+)
+
+func TestServiceImpl(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Chat irc ServiceImpl unit Test Suite")
+}
+
+func createService() (*ServiceImpl, chatserver.Service) {
+	chatService := chatserver.NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+	chatService.Run()
+	return NewServiceImpl(chatService, &config.Config{Host: "localhost"}, nil, nil, nil), chatService
+}
+
+// connect starts a session over an in memory connection and returns the client side
+func connect(service *ServiceImpl) (net.Conn, *bufio.Reader) {
+	server, client := net.Pipe()
+	go service.handleConnection(server)
+	return client, bufio.NewReader(client)
+}
+
+// write sends lines to the server without waiting for it to read them
+func write(client net.Conn, lines string) {
+	go io.WriteString(client, lines)
+}
+
+// readUntil reads lines from the server until one contains the text
+func readUntil(reader *bufio.Reader, text string) string {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		if strings.Contains(line, text) {
+			return line
+		}
+	}
+}
+
+var _ = ginkgo.Describe("ServiceImpl", func() {
+
+	ginkgo.Context("parseMessage", func() {
+
+		ginkgo.It("Parses the prefix, command and trailing parameter", func() {
+			msg, ok := parseMessage(":bob!bob@host privmsg #Tech :hello there\r\n")
+			gomega.Expect(ok).To(gomega.BeTrue())
+			gomega.Expect(msg.prefix).To(gomega.Equal("bob!bob@host"))
+			gomega.Expect(msg.command).To(gomega.Equal("PRIVMSG"))
+			gomega.Expect(msg.params).To(gomega.Equal([]string{"#Tech", "hello there"}))
+		})
+
+		ginkgo.It("Rejects empty lines", func() {
+			_, ok := parseMessage("\r\n")
+			gomega.Expect(ok).To(gomega.BeFalse())
+		})
+	})
+
+	ginkgo.Context("formatMessage", func() {
+
+		ginkgo.It("Sends the last parameter as trailing parameter", func() {
+			gomega.Expect(formatMessage("server", "332", "bob", "#Tech", "Go talk")).
+				To(gomega.Equal(":server 332 bob #Tech :Go talk\r\n"))
+		})
+	})
+
+	ginkgo.Context("toChannel", func() {
+
+		ginkgo.It("Escapes the characters that cannot be part of a channel name and gets the room name back", func() {
+			gomega.Expect(toChannel("team chat, ops: 100%")).To(gomega.Equal("#team%20chat%2C%20ops%3A%20100%25"))
+			gomega.Expect(fromChannel(toChannel("team chat, ops: 100%"))).To(gomega.Equal("#team chat, ops: 100%"))
+			gomega.Expect(fromChannel("#100%")).To(gomega.Equal("#100%"))
+		})
+	})
+
+	ginkgo.Context("Sessions", func() {
+
+		ginkgo.It("Registers the user and joins the Default room", func() {
+			service, _ := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\n")
+			gomega.Expect(readUntil(reader, " 001 ")).To(gomega.ContainSubstring("bob"))
+			gomega.Expect(readUntil(reader, "JOIN")).To(gomega.Equal(":bob!bob@localhost JOIN :#Default\r\n"))
+			gomega.Expect(readUntil(reader, " 353 ")).To(gomega.ContainSubstring(":bob"))
+		})
+
+		ginkgo.It("Sends the message of the day and the welcome message of the channels", func() {
+			service, chatService := createService()
+			chatService.SetMOTD("Be nice\nNo spam")
+			chatService.SetWelcome(chatserver.SystemUserID, chatserver.DefaultRoomID, "Say hi")
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\n")
+			gomega.Expect(readUntil(reader, " 375 ")).To(gomega.ContainSubstring("Message of the day"))
+			gomega.Expect(readUntil(reader, " 372 ")).To(gomega.Equal(":localhost 372 bob :- Be nice\r\n"))
+			gomega.Expect(readUntil(reader, " 372 ")).To(gomega.Equal(":localhost 372 bob :- No spam\r\n"))
+			readUntil(reader, " 376 ")
+			gomega.Expect(readUntil(reader, "NOTICE #Default")).To(gomega.Equal(":localhost NOTICE #Default :Say hi\r\n"))
+		})
+
+		ginkgo.It("Marks the user away and back", func() {
+			service, chatService := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\nAWAY :meeting\r\n")
+			gomega.Expect(readUntil(reader, " 306 ")).To(gomega.ContainSubstring("You have been marked as being away"))
+			bob, _ := chatService.FindUser("bob")
+			gomega.Expect(bob.StatusText).To(gomega.Equal("meeting"))
+			write(client, "AWAY\r\n")
+			gomega.Expect(readUntil(reader, " 305 ")).To(gomega.ContainSubstring("You are no longer marked as being away"))
+		})
+
+		ginkgo.It("Rejects a nick that is already in use", func() {
+			service, chatService := createService()
+			chatService.CreateUser("alice")
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK alice\r\n")
+			gomega.Expect(readUntil(reader, " 433 ")).To(gomega.ContainSubstring("Nickname is already in use"))
+		})
+
+		ginkgo.It("Changes the nick of a registered user", func() {
+			service, chatService := createService()
+			alice := chatService.CreateUser("alice")
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\nNICK alice\r\n")
+			gomega.Expect(readUntil(reader, " 433 ")).To(gomega.ContainSubstring("Nickname is already in use"))
+			write(client, "NICK robert\r\n")
+			gomega.Expect(readUntil(reader, " NICK ")).To(gomega.Equal(":bob!bob@localhost NICK :robert\r\n"))
+			robert, found := chatService.FindUser("robert")
+			gomega.Expect(found).To(gomega.BeTrue())
+			room, _ := chatService.GetRoom(chatserver.DefaultRoomID)
+			gomega.Expect(room.Users[robert.ID]).To(gomega.Equal("robert"))
+			write(client, "JOIN #Ops\r\n")
+			readUntil(reader, "JOIN :#Ops")
+			room, _ = chatService.FindRoom(robert.ID, "#Ops")
+			gomega.Expect(room.Users[robert.ID]).To(gomega.Equal("robert"))
+			for event := range alice.Output {
+				if event.Type == data.EventNick {
+					gomega.Expect(event.Text + ">" + event.UserName).To(gomega.Equal("bob>robert"))
+					break
+				}
+			}
+		})
+
+		ginkgo.It("Requires registration before other commands", func() {
+			service, _ := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "JOIN #Default\r\n")
+			gomega.Expect(readUntil(reader, " 451 ")).To(gomega.ContainSubstring("You have not registered"))
+		})
+
+		ginkgo.It("Answers PING with PONG", func() {
+			service, _ := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "PING :token\r\n")
+			gomega.Expect(readUntil(reader, "PONG")).To(gomega.Equal(":localhost PONG localhost :token\r\n"))
+		})
+
+		ginkgo.It("Creates rooms on JOIN and delivers messages of other users", func() {
+			service, chatService := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\nJOIN #Tech\r\n")
+			readUntil(reader, "JOIN :#Tech")
+			room, found := chatService.FindRoom(0, "#Tech")
+			gomega.Expect(found).To(gomega.BeTrue())
+
+			alice := chatService.CreateUser("alice")
+			chatService.Subscribe(alice.ID, room.ID, "")
+			go func() {
+				for range alice.Output {
+				}
+			}()
+			chatService.Publish(data.Input{Room: room.ID, Text: "hi bob"}, alice.ID, false)
+			gomega.Expect(readUntil(reader, "PRIVMSG")).To(gomega.Equal(":alice!alice@localhost PRIVMSG #Tech :hi bob\r\n"))
+		})
+
+		ginkgo.It("Joins the rooms whose names are not valid channel names", func() {
+			service, chatService := createService()
+			bob := chatService.CreateUser("bob")
+			chatService.CreateRoom("team chat", bob.ID, "", "")
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK alice\r\nUSER alice 0 * :Alice\r\nJOIN #team%20chat\r\n")
+			gomega.Expect(readUntil(reader, "JOIN :#team")).To(gomega.Equal(":alice!alice@localhost JOIN :#team%20chat\r\n"))
+			room, _ := chatService.FindRoom(bob.ID, "#team chat")
+			gomega.Expect(room.Users).To(gomega.HaveLen(2))
+		})
+
+		ginkgo.It("Relays the joins and leaves of the other members", func() {
+			service, chatService := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\nJOIN #Tech\r\n")
+			readUntil(reader, "JOIN :#Tech")
+			room, _ := chatService.FindRoom(0, "#Tech")
+
+			alice := chatService.CreateUser("alice")
+			go func() {
+				for range alice.Output {
+				}
+			}()
+			chatService.Subscribe(alice.ID, room.ID, "")
+			gomega.Expect(readUntil(reader, "JOIN")).To(gomega.Equal(":alice!alice@localhost JOIN :#Tech\r\n"))
+			chatService.UnSubscribe(alice.ID, room.ID)
+			gomega.Expect(readUntil(reader, "PART")).To(gomega.Equal(":alice!alice@localhost PART :#Tech\r\n"))
+		})
+
+		ginkgo.It("Publishes PRIVMSG to the room", func() {
+			service, chatService := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\nPRIVMSG #Default :hello\r\nPING :sync\r\n")
+			readUntil(reader, "PONG")
+			messages := chatService.GetMessages()
+			gomega.Expect(messages[len(messages)-1].Text).To(gomega.Equal("hello"))
+			gomega.Expect(messages[len(messages)-1].UserName).To(gomega.Equal("bob"))
+		})
+
+		ginkgo.It("Makes the configured users operators and closes the link of killed users", func() {
+			service, chatService := createService()
+			chatService.SetAdmins(map[string]string{"bob": "secret"})
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\nOPER bob wrong\r\n")
+			gomega.Expect(readUntil(reader, " 464 ")).To(gomega.ContainSubstring("Password incorrect"))
+			write(client, "OPER bob secret\r\n")
+			gomega.Expect(readUntil(reader, " 381 ")).To(gomega.ContainSubstring("You are now an IRC operator"))
+
+			bob, _ := chatService.FindUser("bob")
+			gomega.Expect(bob.Admin).To(gomega.BeTrue())
+			gomega.Expect(chatService.Disconnect(bob.ID, "flooding")).To(gomega.Succeed())
+			gomega.Expect(readUntil(reader, "ERROR")).To(gomega.Equal("ERROR :Killed (flooding)\r\n"))
+		})
+	})
+
+	ginkgo.Context("Shutdown", func() {
+
+		ginkgo.It("Writes the pending notices before closing the connection", func() {
+			service, chatService := createService()
+			client, reader := connect(service)
+			defer client.Close()
+			write(client, "NICK bob\r\nUSER bob 0 * :Bob\r\n")
+			readUntil(reader, " 353 ")
+
+			chatService.Broadcast("Server is shutting down")
+			shutdown := make(chan error, 1)
+			go func() { shutdown <- service.Shutdown(context.Background()) }()
+			gomega.Expect(readUntil(reader, "NOTICE")).To(gomega.ContainSubstring("#Default :Server is shutting down"))
+			gomega.Expect(readUntil(reader, "ERROR")).To(gomega.Equal("ERROR :Server is shutting down\r\n"))
+			gomega.Eventually(shutdown).Should(gomega.Receive(gomega.BeNil()))
+		})
+	})
+})
@@ -0,0 +1,258 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"chatServer/src/config"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+// levels from the most to the least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level as used in the config
+func (level Level) String() string {
+	if level < LevelDebug || level > LevelError {
+		return "level(" + strconv.Itoa(int(level)) + ")"
+	}
+	return levelNames[level]
+}
+
+// ParseLevel parses debug, info, warn or error, an empty level is info
+func ParseLevel(text string) (Level, error) {
+	if text == "" {
+		return LevelInfo, nil
+	}
+	for i, name := range levelNames {
+		if strings.EqualFold(text, name) {
+			return Level(i), nil
+		}
+	}
+	if strings.EqualFold(text, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelInfo, errors.New("log level " + strconv.Quote(text) + " must be debug, info, warn or error")
+}
+
+// output is the destination shared by a logger and the loggers derived from it with With
+type output struct {
+	writer io.Writer
+	level  Level
+	json   bool
+	now    func() time.Time
+	sync.Mutex
+}
+
+// Logger writes leveled entries with key value fields as text or JSON lines, a nil Logger discards everything
+type Logger struct {
+	output *output
+	fields []interface{} // key value pairs added to every entry
+}
+
+// New returns a Logger writing to the writer with the level and the format of the config
+func New(writer io.Writer, cfg config.LogConfig) (*Logger, error) {
+	level, json, err := parse(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Logger{output: &output{writer: writer, level: level, json: json, now: time.Now}}, nil
+}
+
+// parse checks the level and the format of the config
+func parse(cfg config.LogConfig) (Level, bool, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return level, false, err
+	}
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		return level, false, nil
+	case "json":
+		return level, true, nil
+	}
+	return level, false, errors.New("log format " + strconv.Quote(cfg.Format) + " must be text or json")
+}
+
+// Reconfigure checks the new level and format and returns the function switching the logger and every logger
+// derived from it to them
+func (logger *Logger) Reconfigure(cfg config.LogConfig) (func(), error) {
+	level, json, err := parse(cfg)
+	if err != nil || logger == nil {
+		return func() {}, err
+	}
+	return func() {
+		logger.output.Lock()
+		defer logger.output.Unlock()
+		logger.output.level = level
+		logger.output.json = json
+	}, nil
+}
+
+// With returns a logger adding the key value pairs to every entry, e.g. the id of a connection
+func (logger *Logger) With(keyvals ...interface{}) *Logger {
+	if logger == nil {
+		return nil
+	}
+	fields := make([]interface{}, 0, len(logger.fields)+len(keyvals))
+	fields = append(append(fields, logger.fields...), keyvals...)
+	return &Logger{output: logger.output, fields: fields}
+}
+
+// Enabled checks if the entries of the level are written
+func (logger *Logger) Enabled(level Level) bool {
+	if logger == nil {
+		return false
+	}
+	logger.output.Lock()
+	defer logger.output.Unlock()
+	return level >= logger.output.level
+}
+
+// Debug logs the details that help finding a problem
+func (logger *Logger) Debug(msg string, keyvals ...interface{}) {
+	logger.log(LevelDebug, msg, keyvals)
+}
+
+// Info logs the normal events of the server
+func (logger *Logger) Info(msg string, keyvals ...interface{}) {
+	logger.log(LevelInfo, msg, keyvals)
+}
+
+// Warn logs the problems the server recovers from
+func (logger *Logger) Warn(msg string, keyvals ...interface{}) {
+	logger.log(LevelWarn, msg, keyvals)
+}
+
+// Error logs the failures
+func (logger *Logger) Error(msg string, keyvals ...interface{}) {
+	logger.log(LevelError, msg, keyvals)
+}
+
+// log formats the entry and writes it as a single line
+func (logger *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if logger == nil {
+		return
+	}
+	out := logger.output
+	out.Lock()
+	defer out.Unlock()
+	if level < out.level {
+		return
+	}
+	fields := append(append([]interface{}{}, logger.fields...), keyvals...)
+	if len(fields)%2 == 1 {
+		fields = append(fields, "(missing)")
+	}
+
+	var line bytes.Buffer
+	now := out.now().UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	if out.json {
+		line.WriteString(`{"time":` + quoteJSON(now) + `,"level":` + quoteJSON(level.String()) + `,"msg":` + quoteJSON(msg))
+		for i := 0; i < len(fields); i += 2 {
+			line.WriteString("," + quoteJSON(fmt.Sprint(fields[i])) + ":" + encodeJSON(fields[i+1]))
+		}
+		line.WriteString("}\n")
+	} else {
+		line.WriteString(now + " " + strings.ToUpper(level.String()) + " " + msg)
+		for i := 0; i < len(fields); i += 2 {
+			line.WriteString(" " + fmt.Sprint(fields[i]) + "=" + quoteText(fields[i+1]))
+		}
+		line.WriteString("\n")
+	}
+	out.writer.Write(line.Bytes())
+}
+
+// quoteJSON encodes a string as JSON
+func quoteJSON(text string) string {
+	encoded, _ := json.Marshal(text)
+	return string(encoded)
+}
+
+// encodeJSON encodes a field value, errors and values that cannot be encoded are written as strings
+func encodeJSON(value interface{}) string {
+	switch v := value.(type) {
+	case error:
+		return quoteJSON(v.Error())
+	case time.Duration:
+		return quoteJSON(v.String())
+	case fmt.Stringer:
+		return quoteJSON(v.String())
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return quoteJSON(fmt.Sprint(value))
+	}
+	return string(encoded)
+}
+
+// quoteText formats a field value for the text format, it is quoted when it is empty or has spaces or quotes
+func quoteText(value interface{}) string {
+	text := fmt.Sprint(value)
+	if err, ok := value.(error); ok {
+		text = err.Error()
+	}
+	if text == "" || strings.IndexFunc(text, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == '=' }) >= 0 {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+// NewID returns a random id correlating the entries of a connection or a request
+func NewID() string {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(id)
+}
+
+// contextKey is the key of the logger in a context
+type contextKey struct{}
+
+// NewContext returns a context carrying the logger, e.g. the logger of an API request with its id
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the context, nil when it has none
+func FromContext(ctx context.Context) *Logger {
+	logger, _ := ctx.Value(contextKey{}).(*Logger)
+	return logger
+}
+
+// Writer returns a writer logging each line at the level, it lets the standard log package write through the logger
+func (logger *Logger) Writer(level Level) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+			logger.log(level, line, nil)
+		}
+		return len(p), nil
+	})
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/config"
+)
+
+func TestLogger(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Logging Logger unit Test Suite")
+}
+
+// createLogger returns a logger writing to the buffer at a fixed time
+func createLogger(cfg config.LogConfig) (*Logger, *bytes.Buffer) {
+	var out bytes.Buffer
+	logger, err := New(&out, cfg)
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	logger.output.now = func() time.Time { return time.Date(2019, 6, 8, 17, 23, 7, 0, time.UTC) }
+	return logger, &out
+}
+
+var _ = ginkgo.Describe("Logger", func() {
+
+	ginkgo.It("should write text lines with the fields of the logger and of the entry", func() {
+		logger, out := createLogger(config.LogConfig{Level: "info", Format: "text"})
+		connLogger := logger.With("conn", "ab12cd34", "transport", "telnet")
+		connLogger.Info("The client joined", "user", "alice smith", "userId", 3)
+		connLogger.Warn("The user is muted", "error", errors.New("flooding"))
+		logger.Debug("Hidden below the level")
+
+		gomega.Expect(out.String()).To(gomega.Equal(
+			`2019-06-08T17:23:07.000Z INFO The client joined conn=ab12cd34 transport=telnet user="alice smith" userId=3` + "\n" +
+				`2019-06-08T17:23:07.000Z WARN The user is muted conn=ab12cd34 transport=telnet error=flooding` + "\n"))
+	})
+
+	ginkgo.It("should write JSON lines", func() {
+		logger, out := createLogger(config.LogConfig{Level: "debug", Format: "json"})
+		logger.With("request", "r1").Debug("Request", "status", 200, "duration", 1500*time.Microsecond, "path", `/a"b`)
+
+		gomega.Expect(out.String()).To(gomega.Equal(
+			`{"time":"2019-06-08T17:23:07.000Z","level":"debug","msg":"Request","request":"r1","status":200,"duration":"1.5ms","path":"/a\"b"}` + "\n"))
+	})
+
+	ginkgo.It("should switch the level and the format of the derived loggers", func() {
+		logger, out := createLogger(config.LogConfig{})
+		derived := logger.With("component", "api")
+		apply, err := logger.Reconfigure(config.LogConfig{Level: "error", Format: "json"})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		derived.Warn("Before")
+		apply()
+		derived.Warn("Hidden")
+		derived.Error("Shown")
+		gomega.Expect(out.String()).To(gomega.HavePrefix("2019-06-08T17:23:07.000Z WARN Before component=api\n{"))
+		gomega.Expect(out.String()).To(gomega.HaveSuffix(`"msg":"Shown","component":"api"}` + "\n"))
+		gomega.Expect(out.String()).NotTo(gomega.ContainSubstring("Hidden"))
+	})
+
+	ginkgo.It("should reject unknown levels and formats", func() {
+		_, err := New(&bytes.Buffer{}, config.LogConfig{Level: "verbose"})
+		gomega.Expect(err).To(gomega.MatchError(`log level "verbose" must be debug, info, warn or error`))
+		logger, _ := createLogger(config.LogConfig{})
+		_, err = logger.Reconfigure(config.LogConfig{Format: "xml"})
+		gomega.Expect(err).To(gomega.MatchError(`log format "xml" must be text or json`))
+	})
+
+	ginkgo.It("should carry the logger in a context and discard the entries of a nil logger", func() {
+		logger, _ := createLogger(config.LogConfig{})
+		gomega.Expect(FromContext(NewContext(context.Background(), logger))).To(gomega.BeIdenticalTo(logger))
+		gomega.Expect(FromContext(context.Background())).To(gomega.BeNil())
+
+		var discarded *Logger
+		discarded.With("conn", "1").Info("Nothing")
+		gomega.Expect(NewID()).To(gomega.MatchRegexp(`^[0-9a-f]{8}$`))
+	})
+})
@@ -1,46 +1,254 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
+	"chatServer/src/admission"
 	"chatServer/src/api"
+	"chatServer/src/bots"
 	"chatServer/src/chatserver"
 	"chatServer/src/config"
 	"chatServer/src/connections"
+	"chatServer/src/health"
+	"chatServer/src/irc"
+	"chatServer/src/logging"
+	"chatServer/src/ratelimit"
+	"chatServer/src/reload"
+	"chatServer/src/webhooks"
 )
 
-// function that returns the path of chat server root
+// logger is the server log, it starts with the default level and format until the configuration is read
+var logger, _ = logging.New(os.Stderr, config.Defaults().Log)
+
+// getServerRootDir returns the directory holding the resources, the logs and the config of the server, relative paths of
+// the config are resolved from it: the directory of the executable or its parent when only the parent has a resources
+// directory, e.g. for the binary built in /src by the Makefile
 func getServerRootDir() string {
-	dir, err := os.Getwd()
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
 	if err != nil {
-		log.Println(err.Error())
+		logger.Error("Error finding the executable", "error", err)
 		os.Exit(1)
 	}
-	srcIndex := strings.LastIndex(dir, "/src")
-	dir = dir[:srcIndex]
+	dir := filepath.Dir(executable)
+	for _, root := range []string{dir, filepath.Dir(dir)} {
+		if info, err := os.Stat(filepath.Join(root, "resources")); err == nil && info.IsDir() {
+			return root
+		}
+	}
 	return dir
 }
 
+// shutdownTimeout returns how long the clients and the api requests are given when stopping
+func shutdownTimeout(cfg *config.Config) time.Duration {
+	if cfg.ShutdownTimeout == "" {
+		return 10 * time.Second
+	}
+	timeout, err := time.ParseDuration(cfg.ShutdownTimeout)
+	if err != nil {
+		logger.Error("Error reading the shutdown timeout", "error", err)
+		os.Exit(1)
+	}
+	return timeout
+}
+
+// readMOTD reads the message of the day, the path is relative to the server root and an empty path means no message
+func readMOTD(root string, motdPath string) (string, error) {
+	if motdPath == "" {
+		return "", nil
+	}
+	motd, err := ioutil.ReadFile(path.Join(root, motdPath))
+	return strings.TrimRight(string(motd), "\r\n"), err
+}
+
+// awayAfter returns the idle time after which the users are marked away, 0 when they are never marked
+func awayAfter(cfg *config.Config) (time.Duration, error) {
+	if cfg.AwayAfter == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(cfg.AwayAfter)
+}
+
+// version of the chat server, set when building with -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+// configWatchInterval is how often the config file is checked for changes
+const configWatchInterval = 2 * time.Second
+
+// listener is a telnet or IRC listener that can be stopped
+type listener interface {
+	StopAccepting()
+	Shutdown(ctx context.Context) error
+}
+
+// listening returns a readiness check of a listener
+func listening(l interface{ Listening() bool }) health.Check {
+	return func() error {
+		if !l.Listening() {
+			return errors.New("not accepting connections")
+		}
+		return nil
+	}
+}
+
 func main() {
-	log.Println("Starting the chat server!!!")
+	// the standard log of the libraries, e.g. net/http, goes to the server log
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.LevelInfo))
+	logger.Info("Starting the chat server", "version", version)
 
-	// read the config
-	reader := config.NewReaderImpl()
-	cfg := reader.Read(path.Join(getServerRootDir(), "/resources/config/config.json"))
+	// read the config, the environment and the flags override the config file
+	root := getServerRootDir()
+	loader := config.NewLoader(config.NewReaderImpl(), path.Join(root, "/resources/config/config.json"), os.Args[1:], os.LookupEnv)
+	cfg, err := loader.Load()
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		logger.Error("Error reading the configuration", "error", err)
+		os.Exit(1)
+	}
+	applyLog, err := logger.Reconfigure(cfg.Log)
+	if err != nil {
+		logger.Error("Error reading the log settings", "error", err)
+		os.Exit(1)
+	}
+	applyLog()
+	logger.Info("Configuration read", "file", loader.File(), "logLevel", cfg.Log.Level, "logFormat", cfg.Log.Format)
 
 	// start the chat server
-	chatService := chatserver.NewServiceImpl(path.Join(getServerRootDir(), cfg.LogFilePath))
+	chatService := chatserver.NewServiceImpl(path.Join(root, cfg.LogFilePath), logger)
+	chatService.SetAdmins(cfg.Admins)
+	motd, err := readMOTD(root, cfg.MOTDPath)
+	if err != nil {
+		logger.Error("Error reading the message of the day", "error", err)
+		os.Exit(1)
+	}
+	chatService.SetMOTD(motd)
+	idle, err := awayAfter(cfg)
+	if err != nil {
+		logger.Error("Error reading the away time", "error", err)
+		os.Exit(1)
+	}
+	chatService.SetAwayAfter(idle)
 	chatService.Run()
 
+	// the rate limits are shared by the telnet and IRC listeners and the api
+	limits := ratelimit.NewGuard(cfg.RateLimit)
+	connectionAdmission, err := admission.NewController(cfg.Connections, limits, logger)
+	if err != nil {
+		logger.Error("Error reading the connection settings", "error", err)
+		os.Exit(1)
+	}
+
+	// start the outgoing webhooks
+	webhooksService := webhooks.NewServiceImpl(chatService, cfg.Webhooks, path.Join(root, cfg.WebhookDeadLetterPath), logger)
+	webhooksService.Start()
+
+	// reload the live settings on SIGHUP and when the config file changes
+	reloader := reload.NewReloader(loader, cfg, logger,
+		func(cfg *config.Config) (func(), error) { return logger.Reconfigure(cfg.Log) },
+		func(cfg *config.Config) (func(), error) { return limits.Reconfigure(cfg.RateLimit) },
+		func(cfg *config.Config) (func(), error) { return connectionAdmission.Reconfigure(cfg.Connections) },
+		func(cfg *config.Config) (func(), error) {
+			return webhooksService.Reconfigure(cfg.Webhooks, path.Join(root, cfg.WebhookDeadLetterPath))
+		},
+		func(cfg *config.Config) (func(), error) {
+			return func() { chatService.SetLogFilePath(path.Join(root, cfg.LogFilePath)) }, nil
+		},
+		func(cfg *config.Config) (func(), error) { return func() { chatService.SetAdmins(cfg.Admins) }, nil },
+		func(cfg *config.Config) (func(), error) {
+			// the file is read again on every reload, SIGHUP picks up a new message of the day
+			motd, err := readMOTD(root, cfg.MOTDPath)
+			if err != nil {
+				return nil, err
+			}
+			return func() { chatService.SetMOTD(motd) }, nil
+		},
+		func(cfg *config.Config) (func(), error) {
+			idle, err := awayAfter(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return func() { chatService.SetAwayAfter(idle) }, nil
+		},
+	)
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	stopWatching := make(chan struct{})
+	go reloader.Watch(hangups, configWatchInterval, stopWatching)
+
+	// the monitor answers the readiness probes and the admin status
+	monitor := health.NewMonitor(version, chatService)
+	monitor.AddCheck("storage", chatService.CheckStorage)
+
 	// start the api server
 	apiService := api.NewServiceImpl(chatService)
-	apiController := api.NewControllerImpl(apiService)
+	apiController := api.NewControllerImpl(apiService, limits, monitor, cfg.APIAddress, cfg.AdminToken, logger)
 	go apiController.Register()
 
+	listeners := []listener{}
+
+	// start the optional irc listener
+	if cfg.IRCPort != "" {
+		ircService := irc.NewServiceImpl(chatService, cfg, limits, connectionAdmission, logger)
+		listeners = append(listeners, ircService)
+		monitor.AddCheck("irc", listening(ircService))
+		monitor.AddTransport("irc", ircService)
+		go ircService.HandleConnections()
+	}
+
+	// start the bots, they register their commands with the telnet connections
+	connectionsService := connections.NewServiceImpl(chatService, cfg, limits, connectionAdmission, monitor, logger)
+	botsService := bots.NewServiceImpl(chatService, connectionsService.Commands(), cfg, logger)
+	botsService.Start()
+
 	// handle incoming connections
-	connectionsService := connections.NewServiceImpl(chatService, cfg)
-	connectionsService.HandleConnections()
+	listeners = append(listeners, connectionsService)
+	monitor.AddCheck("telnet", listening(connectionsService))
+	monitor.AddTransport("telnet", connectionsService)
+	go connectionsService.HandleConnections()
+
+	// stop on ctrl+c or when the process manager asks
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	received := <-signals
+	logger.Info("Shutting down the chat server", "signal", received.String())
+	close(stopWatching)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(reloader.Config()))
+	defer cancel()
+
+	for _, l := range listeners {
+		l.StopAccepting()
+	}
+	chatService.Broadcast("Server is shutting down")
+	for _, l := range listeners {
+		if err := l.Shutdown(ctx); err != nil {
+			logger.Error("Error closing the connections", "error", err)
+		}
+	}
+	if err := apiController.Shutdown(ctx); err != nil {
+		logger.Error("Error stopping the api server", "error", err)
+	}
+	botsService.Stop()
+
+	// flush the message log and deliver the last events to the webhooks
+	if err := chatService.Close(); err != nil {
+		logger.Error("Error closing the message log", "error", err)
+	}
+	webhooksService.Stop()
+	logger.Info("The chat server has stopped")
 }
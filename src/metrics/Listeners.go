@@ -0,0 +1,11 @@
+package metrics
+
+// Metrics of the telnet and IRC listeners, each listener sets the transport label to its name
+var (
+	ConnectionsAccepted = NewCounter("chat_connections_accepted_total", "Connections accepted by the listeners.", "transport")
+	ConnectionsRejected = NewCounter("chat_connections_rejected_total", "Connections rejected by the admission control.", "transport")
+	ConnectionsOpen     = NewGauge("chat_connections_open", "Connections currently open.", "transport")
+	LinesReceived       = NewCounter("chat_lines_received_total", "Lines received from the clients.", "transport")
+	LinesRateLimited    = NewCounter("chat_lines_rate_limited_total", "Lines dropped by the rate limits.", "transport")
+	CommandsHandled     = NewCounter("chat_commands_total", "Commands sent by the clients, unknown ones are counted as unknown.", "transport", "command")
+)
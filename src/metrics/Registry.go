@@ -0,0 +1,309 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default is the registry the package level functions register with, it is served by the API on /metrics
+var Default = NewRegistry()
+
+// DefaultBuckets are the histogram buckets for durations in seconds, from 100µs to 10s
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+func init() {
+	Default.GaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+}
+
+// Registry holds metrics and writes them in the Prometheus text exposition format
+type Registry struct {
+	families map[string]*family
+	sync.Mutex
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// family is a metric with all its series, one per combination of label values
+type family struct {
+	name    string
+	help    string
+	kind    string // counter, gauge or histogram
+	labels  []string
+	buckets []float64      // upper bounds of the histogram buckets
+	value   func() float64 // computes the value of a gauge func
+	series  map[string]*series
+	sync.Mutex
+}
+
+// series is the value of a family for a combination of label values
+type series struct {
+	labels []string
+	value  float64  // counters and gauges
+	counts []uint64 // histograms, per bucket and not cumulative
+	sum    float64
+	count  uint64
+}
+
+// register returns the family with the name, creating it when it is not registered yet, so packages can share
+// a metric by registering it with the same name, kind and labels
+func (registry *Registry) register(name string, help string, kind string, buckets []float64, labels []string) *family {
+	registry.Lock()
+	defer registry.Unlock()
+	if existing, found := registry.families[name]; found {
+		if existing.kind != kind || strings.Join(existing.labels, ",") != strings.Join(labels, ",") {
+			panic("metrics: " + name + " is already registered as another " + existing.kind)
+		}
+		return existing
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*series{},
+	}
+	if len(labels) == 0 { // metrics without labels are exposed before their first change
+		f.get(nil)
+	}
+	registry.families[name] = f
+	return f
+}
+
+// get returns the series for the label values, the caller must hold the lock of the family
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic("metrics: " + f.name + " takes " + strconv.Itoa(len(f.labels)) + " label values")
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, found := f.series[key]
+	if !found {
+		s = &series{labels: append([]string{}, labelValues...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up, e.g. the number of published messages, a nil Counter does nothing
+type Counter struct {
+	family *family
+}
+
+// Counter registers a counter, the label values are passed in the order of the names
+func (registry *Registry) Counter(name string, help string, labels ...string) *Counter {
+	return &Counter{family: registry.register(name, help, "counter", nil, labels)}
+}
+
+// Inc adds one to the series of the label values
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Add adds a value that is not negative to the series of the label values
+func (counter *Counter) Add(value float64, labelValues ...string) {
+	if counter == nil || value < 0 {
+		return
+	}
+	counter.family.Lock()
+	defer counter.family.Unlock()
+	counter.family.get(labelValues).value += value
+}
+
+// Gauge is a value that goes up and down, e.g. the number of open connections, a nil Gauge does nothing
+type Gauge struct {
+	family *family
+}
+
+// Gauge registers a gauge, the label values are passed in the order of the names
+func (registry *Registry) Gauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{family: registry.register(name, help, "gauge", nil, labels)}
+}
+
+// GaugeFunc registers a gauge whose value is computed by the function every time the metrics are written
+func (registry *Registry) GaugeFunc(name string, help string, value func() float64) {
+	f := registry.register(name, help, "gauge", nil, nil)
+	f.Lock()
+	defer f.Unlock()
+	f.value = value
+}
+
+// Set sets the series of the label values
+func (gauge *Gauge) Set(value float64, labelValues ...string) {
+	if gauge == nil {
+		return
+	}
+	gauge.family.Lock()
+	defer gauge.family.Unlock()
+	gauge.family.get(labelValues).value = value
+}
+
+// Add adds a value, which can be negative, to the series of the label values
+func (gauge *Gauge) Add(value float64, labelValues ...string) {
+	if gauge == nil {
+		return
+	}
+	gauge.family.Lock()
+	defer gauge.family.Unlock()
+	gauge.family.get(labelValues).value += value
+}
+
+// Inc adds one to the series of the label values
+func (gauge *Gauge) Inc(labelValues ...string) {
+	gauge.Add(1, labelValues...)
+}
+
+// Dec subtracts one from the series of the label values
+func (gauge *Gauge) Dec(labelValues ...string) {
+	gauge.Add(-1, labelValues...)
+}
+
+// Histogram counts observations in buckets, e.g. the durations of requests, a nil Histogram does nothing
+type Histogram struct {
+	family *family
+}
+
+// Histogram registers a histogram with the upper bounds of its buckets in increasing order
+func (registry *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{family: registry.register(name, help, "histogram", buckets, labels)}
+}
+
+// Observe records a value in the series of the label values
+func (histogram *Histogram) Observe(value float64, labelValues ...string) {
+	if histogram == nil {
+		return
+	}
+	histogram.family.Lock()
+	defer histogram.family.Unlock()
+	s := histogram.family.get(labelValues)
+	for i, bound := range histogram.family.buckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// NewCounter registers a counter with the Default registry
+func NewCounter(name string, help string, labels ...string) *Counter {
+	return Default.Counter(name, help, labels...)
+}
+
+// NewGauge registers a gauge with the Default registry
+func NewGauge(name string, help string, labels ...string) *Gauge {
+	return Default.Gauge(name, help, labels...)
+}
+
+// NewHistogram registers a histogram with the Default registry
+func NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return Default.Histogram(name, help, buckets, labels...)
+}
+
+// Write writes the metrics sorted by name in the text exposition format
+func (registry *Registry) Write(w io.Writer) error {
+	registry.Lock()
+	families := make([]*family, 0, len(registry.families))
+	for _, f := range registry.families {
+		families = append(families, f)
+	}
+	registry.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(out)
+	}
+	return out.Flush()
+}
+
+// Handler serves the metrics of the registry
+func (registry *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		registry.Write(w)
+	}
+}
+
+// write writes the help, the type and the series of the family
+func (f *family) write(out *bufio.Writer) {
+	f.Lock()
+	defer f.Unlock()
+	out.WriteString("# HELP " + f.name + " " + strings.Replace(strings.Replace(f.help, `\`, `\\`, -1), "\n", `\n`, -1) + "\n")
+	out.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+	if f.value != nil {
+		f.get(nil).value = f.value()
+	}
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			out.WriteString(f.name + formatLabels(f.labels, s.labels, "", "") + " " + formatValue(s.value) + "\n")
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.counts[i]
+			out.WriteString(f.name + "_bucket" + formatLabels(f.labels, s.labels, "le", formatValue(bound)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		out.WriteString(f.name + "_bucket" + formatLabels(f.labels, s.labels, "le", "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		out.WriteString(f.name + "_sum" + formatLabels(f.labels, s.labels, "", "") + " " + formatValue(s.sum) + "\n")
+		out.WriteString(f.name + "_count" + formatLabels(f.labels, s.labels, "", "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// formatLabels formats the labels of a series, the extra label is added when its name is not empty
+func formatLabels(names []string, values []string, extraName string, extraValue string) string {
+	pairs := []string{}
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if extraName != "" {
+		pairs = append(pairs, extraName+`="`+extraValue+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel escapes the backslashes, quotes and newlines of a label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatValue formats a sample value
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func TestRegistry(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Metrics Registry unit Test Suite")
+}
+
+// exposition returns the metrics of the registry in the text format
+func exposition(registry *Registry) string {
+	var out bytes.Buffer
+	gomega.Expect(registry.Write(&out)).To(gomega.Succeed())
+	return out.String()
+}
+
+var _ = ginkgo.Describe("Registry", func() {
+
+	ginkgo.It("should write counters and gauges sorted by name and labels", func() {
+		registry := NewRegistry()
+		connections := registry.Gauge("chat_connections_open", "Open connections.", "transport")
+		messages := registry.Counter("chat_messages_total", "Published messages.")
+		connections.Inc("telnet")
+		connections.Inc("irc")
+		connections.Inc("telnet")
+		connections.Dec("irc")
+		messages.Add(2.5)
+		messages.Add(-1) // counters only go up
+		registry.Counter("chat_requests_total", "Requests.", "path").Inc(`/a"b\`)
+
+		gomega.Expect(exposition(registry)).To(gomega.Equal(`# HELP chat_connections_open Open connections.
+# TYPE chat_connections_open gauge
+chat_connections_open{transport="irc"} 0
+chat_connections_open{transport="telnet"} 2
+# HELP chat_messages_total Published messages.
+# TYPE chat_messages_total counter
+chat_messages_total 2.5
+# HELP chat_requests_total Requests.
+# TYPE chat_requests_total counter
+chat_requests_total{path="/a\"b\\"} 1
+`))
+	})
+
+	ginkgo.It("should write cumulative histogram buckets with the sum and the count", func() {
+		registry := NewRegistry()
+		durations := registry.Histogram("chat_publish_duration_seconds", "Publish latency.", []float64{0.1, 1})
+		durations.Observe(0.05)
+		durations.Observe(0.5)
+		durations.Observe(3)
+
+		gomega.Expect(exposition(registry)).To(gomega.Equal(`# HELP chat_publish_duration_seconds Publish latency.
+# TYPE chat_publish_duration_seconds histogram
+chat_publish_duration_seconds_bucket{le="0.1"} 1
+chat_publish_duration_seconds_bucket{le="1"} 2
+chat_publish_duration_seconds_bucket{le="+Inf"} 3
+chat_publish_duration_seconds_sum 3.55
+chat_publish_duration_seconds_count 3
+`))
+	})
+
+	ginkgo.It("should share metrics registered twice and reject conflicting ones", func() {
+		registry := NewRegistry()
+		registry.Counter("chat_lines_total", "Lines.", "transport").Inc("telnet")
+		registry.Counter("chat_lines_total", "Lines.", "transport").Inc("telnet")
+		gomega.Expect(exposition(registry)).To(gomega.ContainSubstring(`chat_lines_total{transport="telnet"} 2`))
+		gomega.Expect(func() { registry.Gauge("chat_lines_total", "Lines.", "transport") }).To(gomega.Panic())
+		gomega.Expect(func() { registry.Counter("chat_lines_total", "Lines.", "transport").Inc() }).To(gomega.Panic())
+	})
+
+	ginkgo.It("should compute gauge funcs and serve the metrics", func() {
+		registry := NewRegistry()
+		registry.GaugeFunc("chat_rooms", "Rooms.", func() float64 { return 3 })
+		recorder := httptest.NewRecorder()
+		registry.Handler()(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		gomega.Expect(recorder.Header().Get("Content-Type")).To(gomega.Equal(ContentType))
+		gomega.Expect(recorder.Body.String()).To(gomega.ContainSubstring("chat_rooms 3\n"))
+	})
+
+	ginkgo.It("should ignore nil metrics", func() {
+		var counter *Counter
+		var gauge *Gauge
+		var histogram *Histogram
+		counter.Inc()
+		gauge.Set(1)
+		histogram.Observe(1)
+	})
+})
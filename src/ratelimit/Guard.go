@@ -0,0 +1,221 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"chatServer/src/config"
+)
+
+// Decision is the result of checking a message against the limits
+type Decision int
+
+// Decisions of the Guard, a user is warned for every message over the limit and muted after repeated violations
+const (
+	Allow Decision = iota // the message can be sent
+	Warn                  // the message is dropped and the user is warned
+	Mute                  // the message is dropped and the user is muted from now on
+	Muted                 // the message is dropped because the user is muted
+)
+
+// violationWindow is the time in which repeated violations lead to a mute
+const violationWindow = time.Minute
+
+// ephemeralPerSecond is the number of ephemeral events, e.g. typing notices, a user can send per second for the same key
+const ephemeralPerSecond = 1
+
+// Guard applies the rate limits of the config to messages, API requests and new connections,
+// a nil Guard allows everything
+type Guard struct {
+	userMessages *Limiter
+	ipMessages   *Limiter
+	requests     *Limiter
+	connections  *Limiter
+	ephemeral    *Limiter // not configured, it is kept when the limits are reconfigured
+	muteAfter    int
+	muteDuration time.Duration
+	violations   map[string][]time.Time // the newest violation first
+	mutedUntil   map[string]time.Time
+	now          func() time.Time
+	sync.Mutex
+}
+
+// NewGuard returns a Guard for the rate limits of the config
+func NewGuard(limits config.RateLimitConfig) *Guard {
+	muteDuration, err := time.ParseDuration(limits.MuteDuration)
+	if err != nil || muteDuration <= 0 {
+		muteDuration = 30 * time.Second
+	}
+	guard := &Guard{
+		ephemeral:  NewLimiter(ephemeralPerSecond, 1),
+		violations: make(map[string][]time.Time),
+		mutedUntil: make(map[string]time.Time),
+		now:        time.Now,
+	}
+	guard.setLimits(limits, muteDuration)
+	return guard
+}
+
+// Reconfigure checks new rate limits and returns the function applying them, the buckets of the limits that did not
+// change are kept and the muted users stay muted
+func (guard *Guard) Reconfigure(limits config.RateLimitConfig) (func(), error) {
+	muteDuration := 30 * time.Second
+	if limits.MuteDuration != "" {
+		var err error
+		if muteDuration, err = time.ParseDuration(limits.MuteDuration); err != nil {
+			return nil, err
+		}
+		if muteDuration <= 0 {
+			return nil, errors.New("mute duration must be positive: " + limits.MuteDuration)
+		}
+	}
+	return func() {
+		if guard == nil {
+			return
+		}
+		guard.Lock()
+		defer guard.Unlock()
+		guard.setLimits(limits, muteDuration)
+	}, nil
+}
+
+// setLimits replaces the limiters whose limits changed and the mute settings
+func (guard *Guard) setLimits(limits config.RateLimitConfig, muteDuration time.Duration) {
+	guard.userMessages = keepLimiter(guard.userMessages, limits.MessagesPerSecond, limits.MessageBurst)
+	guard.ipMessages = keepLimiter(guard.ipMessages, limits.IPMessagesPerSecond, limits.IPMessageBurst)
+	guard.requests = keepLimiter(guard.requests, limits.APIRequestsPerSecond, limits.APIBurst)
+	guard.connections = keepLimiter(guard.connections, limits.ConnectionsPerSecond, limits.ConnectionBurst)
+	guard.muteAfter = limits.MuteAfter
+	guard.muteDuration = muteDuration
+}
+
+// keepLimiter returns the limiter with its buckets when it already has the rate and the burst, a new limiter otherwise
+func keepLimiter(limiter *Limiter, rate float64, burst int) *Limiter {
+	updated := NewLimiter(rate, burst)
+	if limiter != nil && limiter.rate == updated.rate && limiter.burst == updated.burst {
+		return limiter
+	}
+	return updated
+}
+
+// CheckMessage checks a message of a user sent from an IP, for Mute and Muted it also returns how long the user stays muted
+func (guard *Guard) CheckMessage(user string, ip string) (Decision, time.Duration) {
+	if guard == nil {
+		return Allow, 0
+	}
+	guard.Lock()
+	defer guard.Unlock()
+	now := guard.now()
+	if until, ok := guard.mutedUntil[user]; ok {
+		if now.Before(until) {
+			return Muted, until.Sub(now)
+		}
+		delete(guard.mutedUntil, user)
+	}
+	// both buckets are charged so an IP cannot be used to bypass the user limit and the other way around
+	userAllowed := guard.userMessages.Allow(user)
+	ipAllowed := guard.ipMessages.Allow(ip)
+	if userAllowed && ipAllowed {
+		if violations, ok := guard.violations[user]; ok && now.Sub(violations[0]) >= violationWindow {
+			delete(guard.violations, user)
+		}
+		return Allow, 0
+	}
+
+	violations := []time.Time{now}
+	for _, violation := range guard.violations[user] {
+		if now.Sub(violation) < violationWindow {
+			violations = append(violations, violation)
+		}
+	}
+	if guard.muteAfter > 0 && len(violations) >= guard.muteAfter {
+		delete(guard.violations, user)
+		if len(guard.mutedUntil) >= pruneSize {
+			guard.prune(now)
+		}
+		guard.mutedUntil[user] = now.Add(guard.muteDuration)
+		return Mute, guard.muteDuration
+	}
+	if _, ok := guard.violations[user]; !ok && len(guard.violations) >= pruneSize {
+		guard.prune(now)
+	}
+	guard.violations[user] = violations
+	return Warn, 0
+}
+
+// prune drops the violations out of the window and the mutes that are over, e.g. of webhook keys that are not used
+// again, the caller must hold the lock
+func (guard *Guard) prune(now time.Time) {
+	for user, violations := range guard.violations {
+		if now.Sub(violations[0]) >= violationWindow {
+			delete(guard.violations, user)
+		}
+	}
+	for user, until := range guard.mutedUntil {
+		if !now.Before(until) {
+			delete(guard.mutedUntil, user)
+		}
+	}
+}
+
+// CheckEphemeral checks an ephemeral event of a user, e.g. typing in the room given by the key, they are dropped
+// while the user is muted and beyond one event per key per second but they never count as violations
+func (guard *Guard) CheckEphemeral(user string, key string) bool {
+	if guard == nil {
+		return true
+	}
+	guard.Lock()
+	defer guard.Unlock()
+	if until, ok := guard.mutedUntil[user]; ok && guard.now().Before(until) {
+		return false
+	}
+	return guard.ephemeral.Allow(user + "/" + key)
+}
+
+// Notice returns the text telling the user about the decision, the remaining time is the one returned with it
+func (decision Decision) Notice(remaining time.Duration) string {
+	seconds := strconv.Itoa(int(math.Ceil(remaining.Seconds()))) + "s"
+	switch decision {
+	case Warn:
+		return "You are sending messages too fast, slow down"
+	case Mute:
+		return "You are muted for " + seconds + " for flooding"
+	case Muted:
+		return "You are muted for another " + seconds
+	}
+	return ""
+}
+
+// AllowRequest checks an API request sent from an IP
+func (guard *Guard) AllowRequest(ip string) bool {
+	if guard == nil {
+		return true
+	}
+	guard.Lock()
+	requests := guard.requests
+	guard.Unlock()
+	return requests.Allow(ip)
+}
+
+// AllowConnection checks a new connection from an IP
+func (guard *Guard) AllowConnection(ip string) bool {
+	if guard == nil {
+		return true
+	}
+	guard.Lock()
+	connections := guard.connections
+	guard.Unlock()
+	return connections.Allow(ip)
+}
+
+// HostIP returns the IP of an address with a port, or the address when it has no port
+func HostIP(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
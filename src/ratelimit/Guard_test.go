@@ -0,0 +1,196 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/config"
+)
+
+func TestGuard(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Rate limit Guard unit Test Suite")
+}
+
+// clock is a fake time that the tests move forward
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func createGuard(limits config.RateLimitConfig) (*Guard, *clock) {
+	c := &clock{now: time.Date(2019, 6, 8, 17, 23, 7, 0, time.UTC)}
+	guard := NewGuard(limits)
+	for _, limiter := range []*Limiter{guard.userMessages, guard.ipMessages, guard.requests, guard.connections, guard.ephemeral} {
+		limiter.now = c.Now
+	}
+	guard.now = c.Now
+	return guard, c
+}
+
+var _ = ginkgo.Describe("Limiter", func() {
+
+	ginkgo.It("should allow bursts and refill at the rate", func() {
+		c := &clock{now: time.Now()}
+		limiter := NewLimiter(2, 3)
+		limiter.now = c.Now
+		for i := 0; i < 3; i++ {
+			gomega.Expect(limiter.Allow("alice")).To(gomega.BeTrue())
+		}
+		gomega.Expect(limiter.Allow("alice")).To(gomega.BeFalse())
+		gomega.Expect(limiter.Allow("bob")).To(gomega.BeTrue())
+
+		c.now = c.now.Add(500 * time.Millisecond)
+		gomega.Expect(limiter.Allow("alice")).To(gomega.BeTrue())
+		gomega.Expect(limiter.Allow("alice")).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should allow everything without a rate", func() {
+		limiter := NewLimiter(0, 0)
+		for i := 0; i < 100; i++ {
+			gomega.Expect(limiter.Allow("alice")).To(gomega.BeTrue())
+		}
+	})
+})
+
+var _ = ginkgo.Describe("Guard", func() {
+
+	ginkgo.It("should warn and then mute users who keep flooding", func() {
+		guard, c := createGuard(config.RateLimitConfig{MessagesPerSecond: 1, MessageBurst: 1, MuteAfter: 2, MuteDuration: "10s"})
+		decision, _ := guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+		decision, _ = guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Warn))
+		decision, remaining := guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Mute))
+		gomega.Expect(remaining).To(gomega.Equal(10 * time.Second))
+
+		c.now = c.now.Add(4 * time.Second)
+		decision, remaining = guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Muted))
+		gomega.Expect(decision.Notice(remaining)).To(gomega.Equal("You are muted for another 6s"))
+
+		c.now = c.now.Add(6 * time.Second)
+		decision, _ = guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+	})
+
+	ginkgo.It("should forget the violations out of the window", func() {
+		guard, c := createGuard(config.RateLimitConfig{MessagesPerSecond: 1, MessageBurst: 1})
+		for i := 0; i < pruneSize; i++ {
+			guard.CheckMessage("hook:"+strconv.Itoa(i), "")
+			decision, _ := guard.CheckMessage("hook:"+strconv.Itoa(i), "")
+			gomega.Expect(decision).To(gomega.Equal(Warn))
+		}
+		gomega.Expect(guard.violations).To(gomega.HaveLen(pruneSize))
+
+		c.now = c.now.Add(violationWindow)
+		guard.CheckMessage("1", "10.0.0.1")
+		decision, _ := guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Warn))
+		gomega.Expect(guard.violations).To(gomega.HaveLen(1))
+
+		c.now = c.now.Add(violationWindow)
+		decision, _ = guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+		gomega.Expect(guard.violations).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should limit the users of an IP together", func() {
+		guard, _ := createGuard(config.RateLimitConfig{IPMessagesPerSecond: 1, IPMessageBurst: 2})
+		decision, _ := guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+		decision, _ = guard.CheckMessage("2", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+		decision, _ = guard.CheckMessage("3", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Warn))
+		decision, _ = guard.CheckMessage("3", "10.0.0.2")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+	})
+
+	ginkgo.It("should limit the requests and connections per IP", func() {
+		guard, _ := createGuard(config.RateLimitConfig{APIRequestsPerSecond: 1, ConnectionsPerSecond: 1})
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeTrue())
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeFalse())
+		gomega.Expect(guard.AllowConnection("10.0.0.1")).To(gomega.BeTrue())
+		gomega.Expect(guard.AllowConnection("10.0.0.1")).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should drop the ephemeral events of muted users and limit them per key without violations", func() {
+		guard, c := createGuard(config.RateLimitConfig{MessagesPerSecond: 1, MessageBurst: 1, MuteAfter: 1, MuteDuration: "10s"})
+		gomega.Expect(guard.CheckEphemeral("1", "typing/0")).To(gomega.BeTrue())
+		gomega.Expect(guard.CheckEphemeral("1", "typing/0")).To(gomega.BeFalse())
+		gomega.Expect(guard.CheckEphemeral("1", "typing/1")).To(gomega.BeTrue())
+		decision, _ := guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow)) // the dropped ephemeral event is not a violation
+
+		c.now = c.now.Add(time.Second)
+		decision, _ = guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+		decision, _ = guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Mute))
+		gomega.Expect(guard.CheckEphemeral("1", "typing/0")).To(gomega.BeFalse())
+		gomega.Expect(guard.CheckEphemeral("2", "typing/0")).To(gomega.BeTrue())
+
+		c.now = c.now.Add(10 * time.Second)
+		gomega.Expect(guard.CheckEphemeral("1", "typing/0")).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should allow everything when it is nil", func() {
+		var guard *Guard
+		decision, _ := guard.CheckMessage("1", "10.0.0.1")
+		gomega.Expect(decision).To(gomega.Equal(Allow))
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeTrue())
+		gomega.Expect(guard.AllowConnection("10.0.0.1")).To(gomega.BeTrue())
+		gomega.Expect(guard.CheckEphemeral("1", "typing/0")).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should apply new limits only when the returned function is called", func() {
+		guard, _ := createGuard(config.RateLimitConfig{APIRequestsPerSecond: 1})
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeTrue())
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeFalse())
+
+		_, err := guard.Reconfigure(config.RateLimitConfig{MuteDuration: "forever"})
+		gomega.Expect(err).ToNot(gomega.BeNil())
+		_, err = guard.Reconfigure(config.RateLimitConfig{MuteDuration: "0s"})
+		gomega.Expect(err).To(gomega.MatchError("mute duration must be positive: 0s"))
+		_, err = guard.Reconfigure(config.RateLimitConfig{MuteDuration: "-1m"})
+		gomega.Expect(err).ToNot(gomega.BeNil())
+		apply, err := guard.Reconfigure(config.RateLimitConfig{})
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeFalse())
+		apply()
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeTrue()) // no rate allows everything
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should keep the buckets of the limits that did not change", func() {
+		limits := config.RateLimitConfig{APIRequestsPerSecond: 1, ConnectionsPerSecond: 1}
+		guard, _ := createGuard(limits)
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeTrue())
+		gomega.Expect(guard.AllowConnection("10.0.0.1")).To(gomega.BeTrue())
+
+		apply, _ := guard.Reconfigure(limits)
+		apply()
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeFalse())
+
+		limits.ConnectionsPerSecond = 2
+		apply, _ = guard.Reconfigure(limits)
+		apply()
+		gomega.Expect(guard.AllowRequest("10.0.0.1")).To(gomega.BeFalse())
+		gomega.Expect(guard.AllowConnection("10.0.0.1")).To(gomega.BeTrue()) // the new limit starts with a full bucket
+	})
+
+	ginkgo.It("should strip the port of addresses", func() {
+		gomega.Expect(HostIP("10.0.0.1:4242")).To(gomega.Equal("10.0.0.1"))
+		gomega.Expect(HostIP("[::1]:4242")).To(gomega.Equal("::1"))
+		gomega.Expect(HostIP("pipe")).To(gomega.Equal("pipe"))
+	})
+})
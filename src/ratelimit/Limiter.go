@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneSize is the number of buckets after which the full buckets are dropped
+const pruneSize = 1000
+
+// bucket is a token bucket, tokens are added at the rate of the limiter up to its burst
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key, e.g. per user or per IP
+type Limiter struct {
+	rate    float64 // tokens added per second, the limiter allows everything when it is not positive
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+	sync.Mutex
+}
+
+// NewLimiter returns a Limiter allowing rate events per second with bursts of up to burst events
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key, it returns false when the bucket is empty
+func (limiter *Limiter) Allow(key string) bool {
+	if limiter == nil || limiter.rate <= 0 {
+		return true
+	}
+	limiter.Lock()
+	defer limiter.Unlock()
+	now := limiter.now()
+	b, ok := limiter.buckets[key]
+	if !ok {
+		if len(limiter.buckets) >= pruneSize {
+			limiter.prune(now)
+		}
+		b = &bucket{tokens: limiter.burst, last: now}
+		limiter.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * limiter.rate
+	if b.tokens > limiter.burst {
+		b.tokens = limiter.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune drops the buckets that are full again, the caller must hold the lock
+func (limiter *Limiter) prune(now time.Time) {
+	for key, b := range limiter.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*limiter.rate >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
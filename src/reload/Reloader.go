@@ -0,0 +1,178 @@
+package reload
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"chatServer/src/config"
+	"chatServer/src/logging"
+)
+
+// Target prepares the live settings of a new configuration for a part of the server and returns the function applying
+// them, every target is prepared before any is applied so an invalid configuration changes nothing
+type Target func(cfg *config.Config) (func(), error)
+
+// Report lists the settings that changed with a reload
+type Report struct {
+	Applied []string // live settings, already in use
+	Restart []string // settings that only take effect after a restart
+}
+
+// setting is a setting compared between two configurations
+type setting struct {
+	name  string
+	value func(cfg *config.Config) interface{}
+}
+
+// liveSettings are applied by the targets without a restart
+var liveSettings = []setting{
+	{"logFilePath", func(cfg *config.Config) interface{} { return cfg.LogFilePath }},
+	{"webhooks", func(cfg *config.Config) interface{} { return cfg.Webhooks }},
+	{"webhookDeadLetterPath", func(cfg *config.Config) interface{} { return cfg.WebhookDeadLetterPath }},
+	{"rateLimit", func(cfg *config.Config) interface{} { return cfg.RateLimit }},
+	{"connections", func(cfg *config.Config) interface{} { return cfg.Connections }},
+	{"shutdownTimeout", func(cfg *config.Config) interface{} { return cfg.ShutdownTimeout }},
+	{"log", func(cfg *config.Config) interface{} { return cfg.Log }},
+	{"admins", func(cfg *config.Config) interface{} { return cfg.Admins }},
+	{"motdPath", func(cfg *config.Config) interface{} { return cfg.MOTDPath }},
+	{"awayAfter", func(cfg *config.Config) interface{} { return cfg.AwayAfter }},
+}
+
+// restartSettings are only read when the server starts
+var restartSettings = []setting{
+	{"host", func(cfg *config.Config) interface{} { return cfg.Host }},
+	{"port", func(cfg *config.Config) interface{} { return cfg.Port }},
+	{"connectionType", func(cfg *config.Config) interface{} { return cfg.ConnectionType }},
+	{"ircPort", func(cfg *config.Config) interface{} { return cfg.IRCPort }},
+	{"apiAddress", func(cfg *config.Config) interface{} { return cfg.APIAddress }},
+	{"adminToken", func(cfg *config.Config) interface{} { return cfg.AdminToken }},
+	{"bots", func(cfg *config.Config) interface{} { return cfg.Bots }},
+}
+
+// Reloader reloads the configuration on demand, on SIGHUP or when the config file changes
+type Reloader struct {
+	loader   *config.Loader
+	current  *config.Config
+	targets  []Target
+	logger   *logging.Logger
+	modified time.Time // of the config file when it was last read
+	sync.Mutex
+}
+
+// NewReloader returns a Reloader for the configuration the server started with, a nil logger discards the server log
+func NewReloader(loader *config.Loader, current *config.Config, logger *logging.Logger, targets ...Target) *Reloader {
+	return &Reloader{
+		loader:   loader,
+		current:  current,
+		targets:  targets,
+		logger:   logger.With("component", "reload"),
+		modified: modTime(loader.File()),
+	}
+}
+
+// Config returns the configuration in use, the settings needing a restart keep the values the server started with
+func (reloader *Reloader) Config() *config.Config {
+	reloader.Lock()
+	defer reloader.Unlock()
+	return reloader.current
+}
+
+// Reload loads the configuration again and applies its live settings, nothing changes when it is invalid
+func (reloader *Reloader) Reload() (Report, error) {
+	reloader.Lock()
+	defer reloader.Unlock()
+	reloader.modified = modTime(reloader.loader.File()) // a rejected file is not read again until it changes
+	cfg, err := reloader.loader.Load()
+	if err != nil {
+		return Report{}, err
+	}
+	applies := []func(){}
+	for _, target := range reloader.targets {
+		apply, err := target(cfg)
+		if err != nil {
+			return Report{}, err
+		}
+		applies = append(applies, apply)
+	}
+
+	report := Report{
+		Applied: changed(liveSettings, reloader.current, cfg),
+		Restart: changed(restartSettings, reloader.current, cfg),
+	}
+	for _, apply := range applies {
+		apply()
+	}
+	cfg.Host = reloader.current.Host
+	cfg.Port = reloader.current.Port
+	cfg.ConnectionType = reloader.current.ConnectionType
+	cfg.IRCPort = reloader.current.IRCPort
+	cfg.APIAddress = reloader.current.APIAddress
+	cfg.AdminToken = reloader.current.AdminToken
+	cfg.Bots = reloader.current.Bots
+	reloader.current = cfg
+	return report, nil
+}
+
+// Watch reloads when a signal arrives or when the config file has been modified, it checks the file every interval
+// and returns when stop is closed, the results are logged
+func (reloader *Reloader) Watch(signals <-chan os.Signal, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case received := <-signals:
+			reloader.reloadAndLog("received " + received.String())
+		case <-ticker.C:
+			reloader.Lock()
+			modified := !modTime(reloader.loader.File()).Equal(reloader.modified)
+			reloader.Unlock()
+			if modified {
+				reloader.reloadAndLog(reloader.loader.File() + " changed")
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// reloadAndLog reloads and logs the report
+func (reloader *Reloader) reloadAndLog(reason string) {
+	report, err := reloader.Reload()
+	if err != nil {
+		reloader.logger.Error("Configuration reload rejected", "reason", reason, "error", err)
+		return
+	}
+	if len(report.Applied) == 0 && len(report.Restart) == 0 {
+		reloader.logger.Info("Configuration reloaded, nothing changed", "reason", reason)
+		return
+	}
+	if len(report.Applied) > 0 {
+		reloader.logger.Info("Configuration reloaded", "reason", reason, "applied", strings.Join(report.Applied, ","))
+	}
+	if len(report.Restart) > 0 {
+		reloader.logger.Warn("Configuration reloaded, restart the server to apply", "reason", reason, "restart", strings.Join(report.Restart, ","))
+	}
+}
+
+// changed returns the names of the settings that differ between the configurations
+func changed(settings []setting, old *config.Config, new *config.Config) []string {
+	names := []string{}
+	for _, s := range settings {
+		if !reflect.DeepEqual(s.value(old), s.value(new)) {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// modTime returns the modification time of the file, the zero time when it cannot be read
+func modTime(file string) time.Time {
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,102 @@
+package reload
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/config"
+)
+
+func TestReloader(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Config Reloader unit Test Suite")
+}
+
+// noEnvironment is a lookup function for an empty environment
+func noEnvironment(string) (string, bool) {
+	return "", false
+}
+
+// createReloader writes the config file and returns a Reloader for it with the targets
+func createReloader(content string, targets ...Target) (*Reloader, string) {
+	dir, err := ioutil.TempDir("", "reload")
+	gomega.Expect(err).To(gomega.BeNil())
+	file := path.Join(dir, "config.json")
+	gomega.Expect(ioutil.WriteFile(file, []byte(content), 0644)).To(gomega.Succeed())
+	loader := config.NewLoader(config.NewReaderImpl(), file, []string{}, noEnvironment)
+	cfg, err := loader.Load()
+	gomega.Expect(err).To(gomega.BeNil())
+	return NewReloader(loader, cfg, nil, targets...), file
+}
+
+var _ = ginkgo.Describe("Reloader", func() {
+
+	ginkgo.It("should apply the live settings and report the ones needing a restart", func() {
+		applied := []config.RateLimitConfig{}
+		target := func(cfg *config.Config) (func(), error) {
+			return func() { applied = append(applied, cfg.RateLimit) }, nil
+		}
+		reloader, file := createReloader(`{"port": "9080"}`, target)
+		defer os.RemoveAll(path.Dir(file))
+
+		ioutil.WriteFile(file, []byte(`{"port": "9090", "rateLimit": {"messagesPerSecond": 3}}`), 0644)
+		report, err := reloader.Reload()
+		gomega.Expect(err).To(gomega.BeNil())
+		gomega.Expect(report.Applied).To(gomega.Equal([]string{"rateLimit"}))
+		gomega.Expect(report.Restart).To(gomega.Equal([]string{"port"}))
+		gomega.Expect(applied).To(gomega.HaveLen(1))
+		gomega.Expect(applied[0].MessagesPerSecond).To(gomega.Equal(3.0))
+		gomega.Expect(reloader.Config().RateLimit.MessagesPerSecond).To(gomega.Equal(3.0))
+		gomega.Expect(reloader.Config().Port).To(gomega.Equal("9080")) // still listening on the old port
+	})
+
+	ginkgo.It("should reject invalid configurations without applying anything", func() {
+		applied := 0
+		good := func(cfg *config.Config) (func(), error) { return func() { applied++ }, nil }
+		bad := func(cfg *config.Config) (func(), error) {
+			if cfg.RateLimit.MuteAfter == 2 {
+				return nil, errors.New("rejected by the target")
+			}
+			return func() { applied++ }, nil
+		}
+		reloader, file := createReloader(`{}`, good, bad)
+		defer os.RemoveAll(path.Dir(file))
+
+		ioutil.WriteFile(file, []byte(`{"rateLimit": {"muteAfter": 2}}`), 0644)
+		_, err := reloader.Reload()
+		gomega.Expect(err).To(gomega.MatchError("rejected by the target"))
+		ioutil.WriteFile(file, []byte(`{"port": "http"}`), 0644)
+		_, err = reloader.Reload()
+		gomega.Expect(err).To(gomega.BeAssignableToTypeOf(&config.ValidationError{}))
+		gomega.Expect(applied).To(gomega.Equal(0))
+		gomega.Expect(reloader.Config().RateLimit.MuteAfter).To(gomega.Equal(0))
+	})
+
+	ginkgo.It("should reload on signals and when the file changes", func() {
+		reloader, file := createReloader(`{"shutdownTimeout": "1s"}`)
+		defer os.RemoveAll(path.Dir(file))
+		signals := make(chan os.Signal, 1)
+		stop := make(chan struct{})
+		defer close(stop)
+		go reloader.Watch(signals, 10*time.Millisecond, stop)
+		timeout := func() string { return reloader.Config().ShutdownTimeout }
+
+		ioutil.WriteFile(file, []byte(`{"shutdownTimeout": "2s"}`), 0644)
+		os.Chtimes(file, time.Now(), time.Now().Add(time.Minute))
+		gomega.Eventually(timeout).Should(gomega.Equal("2s"))
+
+		modified := time.Now().Add(time.Minute)
+		ioutil.WriteFile(file, []byte(`{"shutdownTimeout": "3s"}`), 0644)
+		os.Chtimes(file, modified, modified) // same time, only the signal reloads
+		signals <- syscall.SIGHUP
+		gomega.Eventually(timeout).Should(gomega.Equal("3s"))
+	})
+})
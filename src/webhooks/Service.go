@@ -0,0 +1,11 @@
+package webhooks
+
+import "chatServer/src/config"
+
+// Service interface for the outgoing webhooks
+type Service interface {
+	Start()
+	Stop()
+	DeadLetters() []DeadLetter
+	Reconfigure(configs []config.WebhookConfig, deadLetterPath string) (func(), error)
+}
@@ -0,0 +1,382 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/src/logging"
+)
+
+// Defaults for the webhook settings that are not configured
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = time.Second
+	defaultTimeout    = 5 * time.Second
+	queueSize         = 100
+)
+
+// Headers sent with every delivery, the signature is the hex HMAC-SHA256 of the body prefixed with sha256=
+const (
+	HeaderEvent     = "X-Chat-Event"
+	HeaderDelivery  = "X-Chat-Delivery"
+	HeaderSignature = "X-Chat-Signature"
+)
+
+// Payload is the JSON body posted to a webhook
+type Payload struct {
+	DeliveryID string        `json:"deliveryId"`
+	Event      string        `json:"event"`
+	Timestamp  string        `json:"timestamp"`
+	Room       PayloadRoom   `json:"room"`
+	User       PayloadUser   `json:"user"`
+	Message    *data.Message `json:"message,omitempty"`
+}
+
+// PayloadRoom is the room an event happened in
+type PayloadRoom struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// PayloadUser is the user who caused an event
+type PayloadUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// DeadLetter records a delivery that still failed after all the retries
+type DeadLetter struct {
+	URL        string          `json:"url"`
+	Event      string          `json:"event"`
+	DeliveryID string          `json:"deliveryId"`
+	Attempts   int             `json:"attempts"`
+	Error      string          `json:"error"`
+	FailedAt   string          `json:"failedAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// ServiceImpl struct for the outgoing webhooks
+type ServiceImpl struct {
+	chatService    chatserver.Service
+	configs        []config.WebhookConfig
+	deadLetterPath string
+	logger         *logging.Logger
+	hooks          []*hook
+	deadLetters    []DeadLetter
+	nextDeliveryID int
+	started        bool
+	observing      bool
+	stopped        bool
+	stop           chan struct{}
+	wait           sync.WaitGroup
+	sync.Mutex
+}
+
+// hook is a configured webhook with the queue of its deliveries
+type hook struct {
+	config     config.WebhookConfig
+	maxRetries int
+	backoff    time.Duration
+	client     *http.Client
+	queue      chan delivery
+}
+
+// delivery is a payload waiting to be posted to a hook
+type delivery struct {
+	id    string
+	event string
+	body  []byte
+}
+
+// NewServiceImpl returns ServiceImpl, failed deliveries are appended to the dead letter file as JSON lines,
+// a nil logger discards the server log
+func NewServiceImpl(chatService chatserver.Service, configs []config.WebhookConfig, deadLetterPath string, logger *logging.Logger) *ServiceImpl {
+	return &ServiceImpl{
+		chatService:    chatService,
+		configs:        configs,
+		deadLetterPath: deadLetterPath,
+		logger:         logger.With("component", "webhooks"),
+		stop:           make(chan struct{}),
+	}
+}
+
+// Start starts a worker per webhook and observes the events of the chat server
+func (service *ServiceImpl) Start() {
+	service.Lock()
+	defer service.Unlock()
+	if service.started {
+		return
+	}
+	service.started = true
+	hooks := []*hook{}
+	for _, hookConfig := range service.configs {
+		h, err := newHook(hookConfig)
+		if err != nil {
+			service.logger.Error("Error adding a webhook", "url", hookConfig.URL, "error", err)
+			continue
+		}
+		hooks = append(hooks, h)
+	}
+	service.startHooks(hooks)
+}
+
+// Reconfigure checks new webhooks and returns the function replacing the running ones with them,
+// the deliveries queued for the old webhooks are still sent
+func (service *ServiceImpl) Reconfigure(configs []config.WebhookConfig, deadLetterPath string) (func(), error) {
+	hooks := []*hook{}
+	for _, hookConfig := range configs {
+		h, err := newHook(hookConfig)
+		if err != nil {
+			return nil, errors.New("webhook " + hookConfig.URL + ": " + err.Error())
+		}
+		hooks = append(hooks, h)
+	}
+	return func() {
+		service.Lock()
+		defer service.Unlock()
+		service.configs = configs
+		service.deadLetterPath = deadLetterPath
+		if !service.started || service.stopped {
+			return
+		}
+		for _, h := range service.hooks {
+			close(h.queue)
+		}
+		service.hooks = nil
+		service.startHooks(hooks)
+	}, nil
+}
+
+// startHooks starts a worker per hook and observes the events once there are hooks, the caller must hold the lock
+func (service *ServiceImpl) startHooks(hooks []*hook) {
+	for _, h := range hooks {
+		service.hooks = append(service.hooks, h)
+		service.wait.Add(1)
+		go service.handleDeliveries(h)
+	}
+	if len(service.hooks) > 0 && !service.observing {
+		service.observing = true
+		service.chatService.AddObserver(service.handleEvent)
+	}
+}
+
+// Stop stops accepting events and waits for the queued deliveries, which are not retried anymore
+func (service *ServiceImpl) Stop() {
+	service.Lock()
+	if service.stopped {
+		service.Unlock()
+		return
+	}
+	service.stopped = true
+	close(service.stop)
+	for _, h := range service.hooks {
+		close(h.queue)
+	}
+	service.Unlock()
+	service.wait.Wait()
+}
+
+// DeadLetters returns the deliveries that failed since the server started
+func (service *ServiceImpl) DeadLetters() []DeadLetter {
+	service.Lock()
+	defer service.Unlock()
+	deadLetters := make([]DeadLetter, len(service.deadLetters))
+	copy(deadLetters, service.deadLetters)
+	return deadLetters
+}
+
+// newHook validates the config of a webhook and fills in the defaults
+func newHook(hookConfig config.WebhookConfig) (*hook, error) {
+	if !strings.HasPrefix(hookConfig.URL, "http://") && !strings.HasPrefix(hookConfig.URL, "https://") {
+		return nil, errors.New("url must start with http:// or https://")
+	}
+	h := &hook{
+		config:     hookConfig,
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+		client:     &http.Client{Timeout: defaultTimeout},
+		queue:      make(chan delivery, queueSize),
+	}
+	if hookConfig.MaxRetries != nil {
+		h.maxRetries = *hookConfig.MaxRetries
+	}
+	var err error
+	if hookConfig.Backoff != "" {
+		if h.backoff, err = time.ParseDuration(hookConfig.Backoff); err != nil {
+			return nil, err
+		}
+	}
+	if hookConfig.Timeout != "" {
+		if h.client.Timeout, err = time.ParseDuration(hookConfig.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// matches checks if the hook wants the event, hooks without a room get the events of every room
+func (h *hook) matches(event data.Event) bool {
+	if room := strings.TrimPrefix(h.config.Room, "#"); room != "" &&
+		!strings.EqualFold(room, event.RoomName) && room != strconv.Itoa(event.RoomID) {
+		return false
+	}
+	if len(h.config.Events) == 0 {
+		return true
+	}
+	for _, eventType := range h.config.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEvent queues a delivery of the event for every matching hook
+func (service *ServiceImpl) handleEvent(event data.Event) {
+	switch event.Type {
+	case data.EventMessage, data.EventJoin, data.EventLeave, data.EventRoomCreated:
+	default:
+		return
+	}
+
+	service.Lock()
+	defer service.Unlock()
+	if service.stopped {
+		return
+	}
+	id := strconv.Itoa(service.nextDeliveryID)
+	service.nextDeliveryID++
+	payload := Payload{
+		DeliveryID: id,
+		Event:      event.Type,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Room:       PayloadRoom{ID: event.RoomID, Name: event.RoomName},
+		User:       PayloadUser{ID: event.UserID, Name: event.UserName},
+	}
+	if event.Type == data.EventMessage {
+		message := event.Message
+		payload.Message = &message
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		service.logger.Error("Error encoding a webhook payload", "delivery", id, "event", event.Type, "error", err)
+		return
+	}
+
+	for _, h := range service.hooks {
+		if !h.matches(event) {
+			continue
+		}
+		select {
+		case h.queue <- delivery{id: id, event: event.Type, body: body}:
+		default:
+			service.addDeadLetter(h, delivery{id: id, event: event.Type, body: body}, 0, errors.New("queue is full"))
+		}
+	}
+}
+
+// handleDeliveries posts the deliveries of a hook one after the other so they arrive in order
+func (service *ServiceImpl) handleDeliveries(h *hook) {
+	defer service.wait.Done()
+	for d := range h.queue {
+		service.deliver(h, d)
+	}
+}
+
+// deliver posts a delivery, retrying with an exponential backoff, and records it as dead letter when all attempts fail
+func (service *ServiceImpl) deliver(h *hook, d delivery) {
+	backoff := h.backoff
+	attempts := 1
+	err := post(h, d)
+	for err != nil && attempts <= h.maxRetries && service.sleep(backoff) {
+		service.logger.Debug("Retrying a webhook delivery", "delivery", d.id, "url", h.config.URL, "attempt", attempts+1, "error", err)
+		backoff *= 2
+		attempts++
+		err = post(h, d)
+	}
+	if err == nil {
+		return
+	}
+	service.logger.Warn("A webhook delivery failed", "delivery", d.id, "event", d.event, "url", h.config.URL, "attempts", attempts, "error", err)
+	service.Lock()
+	defer service.Unlock()
+	service.addDeadLetter(h, d, attempts, err)
+}
+
+// sleep waits for the backoff, it returns false when the service stops in the meantime
+func (service *ServiceImpl) sleep(backoff time.Duration) bool {
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-service.stop:
+		return false
+	}
+}
+
+// post sends a delivery once, any status other than 2xx is an error
+func post(h *hook, d delivery) error {
+	request, err := http.NewRequest(http.MethodPost, h.config.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, d.event)
+	request.Header.Set(HeaderDelivery, d.id)
+	if h.config.Secret != "" {
+		request.Header.Set(HeaderSignature, Sign(h.config.Secret, d.body))
+	}
+	response, err := h.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New("unexpected status " + response.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature of a body for the secret of a hook
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// addDeadLetter records a failed delivery in memory and in the dead letter file, the caller must hold the lock
+func (service *ServiceImpl) addDeadLetter(h *hook, d delivery, attempts int, err error) {
+	deadLetter := DeadLetter{
+		URL:        h.config.URL,
+		Event:      d.event,
+		DeliveryID: d.id,
+		Attempts:   attempts,
+		Error:      err.Error(),
+		FailedAt:   time.Now().UTC().Format(time.RFC3339),
+		Payload:    json.RawMessage(d.body),
+	}
+	service.deadLetters = append(service.deadLetters, deadLetter)
+	if service.deadLetterPath == "" {
+		return
+	}
+	line, _ := json.Marshal(deadLetter)
+	file, fileErr := os.OpenFile(service.deadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if fileErr != nil {
+		service.logger.Error("Error writing a dead letter", "delivery", d.id, "path", service.deadLetterPath, "error", fileErr)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
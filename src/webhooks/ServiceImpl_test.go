@@ -0,0 +1,186 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"chatServer/src/chatserver"
+	"chatServer/src/chatserver/data"
+	"chatServer/src/config"
+	"chatServer/testhelpers"
+)
+
+func TestServiceImpl(t *testing.T) {
+	gomega.RegisterFailHandler(ginkgo.Fail)
+	ginkgo.RunSpecs(t, "Webhooks ServiceImpl unit Test Suite")
+}
+
+// receiver is a webhook endpoint that records the requests and fails the first ones
+type receiver struct {
+	server   *httptest.Server
+	requests []*http.Request
+	bodies   [][]byte
+	failures int
+	sync.Mutex
+}
+
+func newReceiver(failures int) *receiver {
+	r := &receiver{failures: failures}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		r.Lock()
+		defer r.Unlock()
+		r.requests = append(r.requests, request)
+		r.bodies = append(r.bodies, body)
+		if len(r.requests) <= r.failures {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	return r
+}
+
+func (r *receiver) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.requests)
+}
+
+func (r *receiver) payload(index int) Payload {
+	r.Lock()
+	defer r.Unlock()
+	var payload Payload
+	json.Unmarshal(r.bodies[index], &payload)
+	return payload
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func createService(hooks []config.WebhookConfig, deadLetterPath string) (*ServiceImpl, chatserver.Service) {
+	chatService := chatserver.NewServiceImpl(path.Join(testhelpers.GetServerRootDir(), "/logs/messages.log"), nil)
+	chatService.Run()
+	service := NewServiceImpl(chatService, hooks, deadLetterPath, nil)
+	service.Start()
+	return service, chatService
+}
+
+var _ = ginkgo.Describe("ServiceImpl", func() {
+
+	ginkgo.It("should post signed payloads for the events of every room", func() {
+		r := newReceiver(0)
+		defer r.server.Close()
+		service, chatService := createService([]config.WebhookConfig{{URL: r.server.URL, Secret: "secret"}}, "")
+		user := chatService.CreateUser("alice")
+		chatService.Publish(data.Input{Text: "hello", Room: chatserver.DefaultRoomID}, user.ID, false)
+		chatService.CreateRoom("Tech", user.ID, "", "")
+		gomega.Eventually(r.count).Should(gomega.Equal(2))
+		service.Stop()
+
+		payload := r.payload(0)
+		gomega.Expect(payload.Event).To(gomega.Equal(data.EventMessage))
+		gomega.Expect(payload.Room).To(gomega.Equal(PayloadRoom{ID: 0, Name: "Default"}))
+		gomega.Expect(payload.User).To(gomega.Equal(PayloadUser{ID: user.ID, Name: "alice"}))
+		gomega.Expect(payload.Message.Text).To(gomega.Equal("hello"))
+		gomega.Expect(r.payload(1).Event).To(gomega.Equal(data.EventRoomCreated))
+
+		request := r.requests[0]
+		gomega.Expect(request.Header.Get("Content-Type")).To(gomega.Equal("application/json"))
+		gomega.Expect(request.Header.Get(HeaderEvent)).To(gomega.Equal(data.EventMessage))
+		gomega.Expect(request.Header.Get(HeaderSignature)).To(gomega.Equal(Sign("secret", r.bodies[0])))
+	})
+
+	ginkgo.It("should only post the configured events of the configured room", func() {
+		r := newReceiver(0)
+		defer r.server.Close()
+		service, chatService := createService([]config.WebhookConfig{
+			{URL: r.server.URL, Room: "#tech", Events: []string{data.EventJoin, data.EventLeave}}}, "")
+		alice := chatService.CreateUser("alice")
+		bob := chatService.CreateUser("bob")
+		room, _ := chatService.CreateRoom("Tech", alice.ID, "", "")
+		chatService.Publish(data.Input{Text: "hello", Room: room.ID}, alice.ID, false)
+		chatService.Subscribe(bob.ID, room.ID, "")
+		chatService.UnSubscribe(bob.ID, room.ID)
+		chatService.CreateRoom("Ops", bob.ID, "", "")
+		gomega.Eventually(r.count).Should(gomega.Equal(2))
+		service.Stop()
+
+		gomega.Expect(r.payload(0).Event).To(gomega.Equal(data.EventJoin))
+		gomega.Expect(r.payload(0).User.Name).To(gomega.Equal("bob"))
+		gomega.Expect(r.payload(1).Event).To(gomega.Equal(data.EventLeave))
+		gomega.Expect(r.requests[0].Header.Get(HeaderSignature)).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should retry failed deliveries with backoff", func() {
+		r := newReceiver(2)
+		defer r.server.Close()
+		service, chatService := createService([]config.WebhookConfig{{URL: r.server.URL, Backoff: "1ms"}}, "")
+		chatService.CreateRoom("Tech", chatserver.SystemUserID, "", "")
+		gomega.Eventually(r.count).Should(gomega.Equal(3))
+		service.Stop()
+
+		gomega.Expect(r.requests[2].Header.Get(HeaderDelivery)).To(gomega.Equal(r.requests[0].Header.Get(HeaderDelivery)))
+		gomega.Expect(service.DeadLetters()).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should record a dead letter when all the attempts fail", func() {
+		r := newReceiver(10)
+		defer r.server.Close()
+		file, _ := ioutil.TempFile("", "dead-letter")
+		file.Close()
+		defer os.Remove(file.Name())
+		service, chatService := createService([]config.WebhookConfig{
+			{URL: r.server.URL, Backoff: "1ms", MaxRetries: intPointer(1)}}, file.Name())
+		chatService.CreateRoom("Tech", chatserver.SystemUserID, "", "")
+		gomega.Eventually(service.DeadLetters).Should(gomega.HaveLen(1))
+		service.Stop()
+
+		deadLetter := service.DeadLetters()[0]
+		gomega.Expect(r.count()).To(gomega.Equal(2))
+		gomega.Expect(deadLetter.Attempts).To(gomega.Equal(2))
+		gomega.Expect(deadLetter.Event).To(gomega.Equal(data.EventRoomCreated))
+		gomega.Expect(deadLetter.Error).To(gomega.ContainSubstring("500"))
+		content, _ := ioutil.ReadFile(file.Name())
+		var recorded DeadLetter
+		gomega.Expect(json.Unmarshal(content, &recorded)).To(gomega.BeNil())
+		gomega.Expect(recorded.DeliveryID).To(gomega.Equal(deadLetter.DeliveryID))
+	})
+
+	ginkgo.It("should skip invalid webhooks", func() {
+		service, _ := createService([]config.WebhookConfig{{URL: "ftp://example.com"}, {URL: "http://example.com", Backoff: "soon"}}, "")
+		defer service.Stop()
+		gomega.Expect(service.hooks).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should replace the webhooks when reconfigured", func() {
+		first, second := newReceiver(0), newReceiver(0)
+		defer first.server.Close()
+		defer second.server.Close()
+		service, chatService := createService([]config.WebhookConfig{}, "")
+		defer service.Stop()
+
+		_, err := service.Reconfigure([]config.WebhookConfig{{URL: second.server.URL}, {URL: "ftp://example.com"}}, "")
+		gomega.Expect(err).ToNot(gomega.BeNil())
+		apply, err := service.Reconfigure([]config.WebhookConfig{{URL: first.server.URL}}, "")
+		gomega.Expect(err).To(gomega.BeNil())
+		apply()
+		chatService.CreateRoom("Tech", chatserver.SystemUserID, "", "")
+		gomega.Eventually(first.count).Should(gomega.Equal(1))
+
+		apply, _ = service.Reconfigure([]config.WebhookConfig{{URL: second.server.URL}}, "")
+		apply()
+		chatService.CreateRoom("Ops", chatserver.SystemUserID, "", "")
+		gomega.Eventually(second.count).Should(gomega.Equal(1))
+		gomega.Expect(second.payload(0).Room.Name).To(gomega.Equal("Ops"))
+		gomega.Expect(first.count()).To(gomega.Equal(1))
+	})
+})